@@ -2,20 +2,38 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/benji-bou/lugh/core/api"
+	"github.com/benji-bou/lugh/core/api/ctrl"
+	"github.com/benji-bou/lugh/core/checkpoint"
 	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
+	"github.com/benji-bou/lugh/core/metrics"
 	"github.com/benji-bou/lugh/core/plugins"
 	"github.com/benji-bou/lugh/core/plugins/grpc"
+	"github.com/benji-bou/lugh/core/record"
+	"github.com/benji-bou/lugh/core/secret"
 	"github.com/benji-bou/lugh/core/template"
+	"github.com/benji-bou/lugh/core/template/templatetest"
+	"github.com/benji-bou/lugh/core/tracing"
 	"github.com/benji-bou/lugh/helper"
 
+	"github.com/benji-bou/diwo"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/urfave/cli/v2"
 )
 
@@ -24,49 +42,198 @@ func main() {
 	app := &cli.App{
 		Name:  "lugh",
 		Usage: "lugh can be use to construct cyber security pipeline based on modules",
-		Flags: []cli.Flag{
+		Flags: append(append(append(templateFlags(), inputFlags()...), observabilityFlags()...),
 			&cli.StringFlag{
 				Name:  "draw-graph-only",
-				Usage: "Only construct pipeline graph and drow it in DOT notation. To display use `dot -Tsvg <filepath>`",
+				Usage: "Only construct pipeline graph and draw it in the file given. To display a DOT graph use `dot -Tsvg <filepath>`",
 			},
 			&cli.StringFlag{
-				Name:     "template",
-				Aliases:  []string{"t"},
-				Usage:    "Pipeline template to execute",
-				Required: true,
+				Name:  "graph-format",
+				Usage: "format of the graph drawn by draw-graph-only: dot, mermaid or json. Guessed from the file extension when not set, dot by default",
 			},
-			&cli.StringFlag{
-				Name:    "plugins-path",
-				Aliases: []string{"p"},
-				Usage:   "directory path of the plugins",
-				Value:   "~/.lugh/plugins",
-			},
-			&cli.StringFlag{
-				Name:    "raw-input",
-				Aliases: []string{"i"},
-				Usage:   "raw input to pass to the pipeline",
-			},
-			&cli.StringSliceFlag{
-				Name:    "var",
-				Aliases: []string{"v"},
-				Usage:   "variable to pass to the pipeline. in the form of Key=Value",
-			},
-		},
-		Action: func(c *cli.Context) error {
-			defer grpc.CleanupClients()
+		),
+		Before: func(_ *cli.Context) error {
 			plugins.InitLoader()
 			helper.SetLog(slog.LevelDebug, false)
+			secret.RedactLogs()
+			return nil
+		},
+		After: func(_ *cli.Context) error {
+			grpc.CleanupClients()
+			return nil
+		},
+		Action: func(c *cli.Context) error {
 			if c.IsSet("draw-graph-only") {
 				return DrawGraphOnly(c)
 			}
 			return RunTemplate(c)
 		},
+		Commands: []*cli.Command{
+			{
+				Name:  "run",
+				Usage: "run a pipeline template",
+				Flags: append(append(templateFlags(), inputFlags()...), append(observabilityFlags(),
+					&cli.StringFlag{
+						Name:  "state-dir",
+						Usage: "directory recording the progress of the run, so that it can be resumed with lugh resume. Producers and the runner stages unable to tell when they are done with an input process their inputs again on resume. The recorded messages are encrypted with the passphrase of LUGH_SECRETS_PASSPHRASE when set",
+					},
+					&cli.StringFlag{
+						Name:  "record",
+						Usage: "directory recording the items read by each stage, one JSON lines file per edge, to replay them with lugh replay",
+					},
+					&cli.BoolFlag{
+						Name:  "help-template",
+						Usage: "print the inputs declared by the template instead of running it",
+					},
+				)...),
+				Action: RunTemplate,
+			},
+			{
+				Name:      "resume",
+				Usage:     "resume an interrupted run recorded with `lugh run --state-dir`, skipping the inputs already processed",
+				ArgsUsage: "<run-id>",
+				Flags: append(observabilityFlags(),
+					&cli.StringFlag{
+						Name:     "state-dir",
+						Usage:    "directory where the run was recorded",
+						Required: true,
+					},
+				),
+				Action: ResumeRun,
+			},
+			{
+				Name:  "replay",
+				Usage: "feed the inputs of a stage recorded with `lugh run --record` into this stage alone and print its outputs",
+				Flags: append(templateFlags(),
+					&cli.StringFlag{
+						Name:     "stage",
+						Usage:    "stage to replay",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "from",
+						Usage:    "directory of the recording",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print the whole output messages in JSON instead of their payload",
+					},
+				),
+				Action: ReplayStage,
+			},
+			{
+				Name:   "validate",
+				Usage:  "check that a template loads, its plugins resolve, their configs match their schemas and its graph is well formed, without running it",
+				Flags:  templateFlags(),
+				Action: ValidateTemplate,
+			},
+			{
+				Name:  "lint",
+				Usage: "validate a template then warn about unconnected stages, unread outputs, unused variables and deprecated plugins",
+				Flags: append(templateFlags(),
+					&cli.BoolFlag{
+						Name:  "strict",
+						Usage: "fail when there are warnings",
+					},
+				),
+				Action: LintTemplate,
+			},
+			{
+				Name:      "test",
+				Usage:     "run the test cases of templates and print a diff of the outputs of the failing ones",
+				ArgsUsage: "<suite.yml>...",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "plugins-path",
+						Aliases: []string{"p"},
+						Usage:   "directory path of the plugins",
+						Value:   "~/.lugh/plugins",
+					},
+				},
+				Action: TestTemplates,
+			},
+			{
+				Name:  "secrets",
+				Usage: "manage the local secret store read by the `store` secrets of templates, encrypted with the passphrase of " + secret.PassphraseEnv,
+				Subcommands: []*cli.Command{
+					{
+						Name:      "set",
+						Usage:     "store the secret read from stdin",
+						ArgsUsage: "<name>",
+						Action:    SetSecret,
+					},
+					{
+						Name:   "list",
+						Usage:  "print the names of the stored secrets",
+						Action: ListSecrets,
+					},
+					{
+						Name:      "rm",
+						Usage:     "remove a stored secret",
+						ArgsUsage: "<name>",
+						Action:    RemoveSecret,
+					},
+				},
+			},
+		},
 	}
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// templateFlags are the flags building a template.
+func templateFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "template",
+			Aliases: []string{"t"},
+			Usage:   "Pipeline template to execute",
+		},
+		&cli.StringFlag{
+			Name:    "plugins-path",
+			Aliases: []string{"p"},
+			Usage:   "directory path of the plugins",
+			Value:   "~/.lugh/plugins",
+		},
+		&cli.StringSliceFlag{
+			Name:    "var",
+			Aliases: []string{"v"},
+			Usage:   "variable to pass to the pipeline. in the form of Key=Value",
+		},
+	}
+}
+
+// inputFlags are the flags feeding a template.
+func inputFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "raw-input",
+			Aliases: []string{"i"},
+			Usage:   "raw input to pass to the pipeline",
+		},
+	}
+}
+
+// observabilityFlags are the flags exporting the metrics and the traces of a run.
+func observabilityFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "metrics-addr",
+			Usage: "address serving the stages metrics in Prometheus format on /metrics, e.g. :9090",
+		},
+		&cli.StringFlag{
+			Name:  "trace-file",
+			Usage: "file receiving a span per item and stage, in OTLP JSON format",
+		},
+		&cli.StringFlag{
+			Name:  "trace-endpoint",
+			Usage: "OTLP/HTTP collector receiving a span per item and stage, e.g. localhost:4318",
+		},
+	}
+}
+
 func DrawGraphOnly(c *cli.Context) error {
 	tplPath := c.String("template")
 	tpl, err := template.NewFile[template.Stage](tplPath, template.WithPluginPath(c.String("plugins-path")))
@@ -77,20 +244,218 @@ func DrawGraphOnly(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	path := c.String("draw-graph-only")
+	format, err := graphFormat(c.String("graph-format"), path)
+	if err != nil {
+		return err
+	}
 	g := graph.NewIO(graph.WithVertices(vertices))
-	return g.DrawGraph(c.String("draw-graph-only"))
+	return g.DrawGraphFormat(path, format)
+}
+
+// graphFormat returns the format named `name`, or the one of the extension of `path` when `name` is empty.
+func graphFormat(name, path string) (graph.Format, error) {
+	if name != "" {
+		return graph.ParseFormat(name)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return graph.FormatJSON, nil
+	case ".mmd", ".mermaid":
+		return graph.FormatMermaid, nil
+	default:
+		return graph.FormatDOT, nil
+	}
 }
 
+// RunTemplate runs the template of the `template` flag on the raw input and stdin.
+// With `state-dir`, the inputs and the progress of each stage are recorded so that the run can be resumed.
 func RunTemplate(c *cli.Context) error {
 	tplPath := c.String("template")
-	variables := make(map[string]interface{})
+	if tplPath == "" {
+		return errors.New(`required flag "template" not set`)
+	}
+	if c.Bool("help-template") {
+		return PrintTemplateInputs(c.App.Writer, tplPath)
+	}
+	variables, err := parseVariables(c)
+	if err != nil {
+		return err
+	}
+	options := []template.TemplateOption{
+		template.WithPluginPath(c.String("plugins-path")),
+		template.WithVariables(variables),
+	}
+	var store *checkpoint.Store
+	if stateDir := c.String("state-dir"); stateDir != "" {
+		run, err := newRun(tplPath, c.String("plugins-path"), variables)
+		if err != nil {
+			return err
+		}
+		if store, err = checkpoint.Open(stateDir, run.ID, os.Getenv(secret.PassphraseEnv)); err != nil {
+			return err
+		}
+		if !store.Encrypted() {
+			slog.Warn("the run state holds the messages of the run in clear, secrets included, set "+secret.PassphraseEnv+" to encrypt it", "stateDir", stateDir)
+		}
+		if err := store.SaveRun(run); err != nil {
+			return err
+		}
+		slog.Info("recording the run, resume it with `lugh resume`", "runId", run.ID, "stateDir", stateDir)
+		options = append(options, template.WithRunID(run.ID), template.WithCheckpoints(store))
+	}
+	tpl, err := template.NewFile[template.Stage](tplPath, options...)
+	if err != nil {
+		slog.Error("failed to start template", "error", err)
+		return err
+	}
+	return RunGraph(c, tpl, func(inputC chan<- message.Message) {
+		SendRawInput(c, inputC, tpl.RunID(), store)
+	})
+}
+
+// SetSecret stores the first line of stdin as the secret named by the argument, so that it is not kept in the
+// shell history.
+func SetSecret(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return errors.New("secret name is required")
+	}
+	store, err := secret.DefaultStore()
+	if err != nil {
+		return err
+	}
+	value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("read secret: %w", err)
+	}
+	store.Set(name, strings.TrimRight(value, "\r\n"))
+	return store.Save()
+}
+
+// ListSecrets prints the names of the stored secrets.
+func ListSecrets(c *cli.Context) error {
+	store, err := secret.DefaultStore()
+	if err != nil {
+		return err
+	}
+	for _, name := range store.Names() {
+		fmt.Fprintln(c.App.Writer, name)
+	}
+	return nil
+}
+
+// RemoveSecret removes the secret named by the argument.
+func RemoveSecret(c *cli.Context) error {
+	store, err := secret.DefaultStore()
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(c.Args().First()); err != nil {
+		return err
+	}
+	return store.Save()
+}
+
+// PrintTemplateInputs prints the inputs declared by the template at `tplPath`, to be given with `--var`.
+func PrintTemplateInputs(out io.Writer, tplPath string) error {
+	inputs, err := template.ReadInputs(tplPath)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		fmt.Fprintf(out, "%s declares no inputs\n", tplPath)
+		return nil
+	}
+	fmt.Fprintf(out, "Inputs of %s, given with --var name=value:\n", tplPath)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAME\tTYPE\tREQUIRED\tDEFAULT\tDESCRIPTION")
+	for _, input := range inputs {
+		inputType, defaultValue := input.Type, ""
+		if inputType == "" {
+			inputType = string(template.InputString)
+		}
+		if input.Default != nil {
+			defaultValue = fmt.Sprint(input.Default)
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%t\t%s\t%s\n", input.Name, inputType, input.Required, defaultValue, input.Description)
+	}
+	return w.Flush()
+}
+
+// parseVariables returns the template variables of the `var` flags.
+func parseVariables(c *cli.Context) (map[string]any, error) {
+	variables := make(map[string]any)
 	for _, v := range c.StringSlice("var") {
 		parts := strings.SplitN(v, "=", 2)
 		if len(parts) != 2 {
-			return fmt.Errorf("invalid variable format: %s", v)
+			return nil, fmt.Errorf("invalid variable format: %s", v)
 		}
 		variables[parts[0]] = parts[1]
 	}
+	return variables, nil
+}
+
+func newRun(tplPath, pluginsPath string, variables map[string]any) (checkpoint.Run, error) {
+	tplPath, err := filepath.Abs(tplPath)
+	if err != nil {
+		return checkpoint.Run{}, fmt.Errorf("resolve template path: %w", err)
+	}
+	return checkpoint.Run{
+		ID:          uuid.NewString(),
+		Template:    tplPath,
+		PluginsPath: pluginsPath,
+		Variables:   variables,
+		StartedAt:   time.Now(),
+	}, nil
+}
+
+// ResumeRun rebuilds the graph of a recorded run from the same template and variables and sends its inputs again.
+// The stages skip the inputs they already processed and emit the outputs they recorded for them instead.
+func ResumeRun(c *cli.Context) error {
+	runID := c.Args().First()
+	if runID == "" {
+		return errors.New("missing the id of the run to resume")
+	}
+	store, run, err := checkpoint.Resume(c.String("state-dir"), runID, os.Getenv(secret.PassphraseEnv))
+	if err != nil {
+		return err
+	}
+	tpl, err := template.NewFile[template.Stage](run.Template,
+		template.WithPluginPath(run.PluginsPath),
+		template.WithVariables(run.Variables),
+		template.WithRunID(run.ID),
+		template.WithCheckpoints(store),
+	)
+	if err != nil {
+		slog.Error("failed to start template", "error", err)
+		return err
+	}
+	inputs := store.Inputs()
+	slog.Info("resuming run", "runId", run.ID, "template", run.Template, "inputs", len(inputs))
+	return RunGraph(c, tpl, func(inputC chan<- message.Message) {
+		defer close(inputC)
+		for _, input := range inputs {
+			inputC <- input
+		}
+	})
+}
+
+// ReplayStage feeds the inputs of the stage `stage` recorded in `from` into this stage alone. Its outputs are printed
+// on stdout, prefixed with their port when it is not the default one, the ports read by no other stage are dropped.
+func ReplayStage(c *cli.Context) error {
+	tplPath := c.String("template")
+	if tplPath == "" {
+		return errors.New(`required flag "template" not set`)
+	}
+	variables, err := parseVariables(c)
+	if err != nil {
+		return err
+	}
+	inputs, err := record.Inputs(c.String("from"), c.String("stage"))
+	if err != nil {
+		return err
+	}
 	tpl, err := template.NewFile[template.Stage](tplPath,
 		template.WithPluginPath(c.String("plugins-path")),
 		template.WithVariables(variables),
@@ -99,37 +464,267 @@ func RunTemplate(c *cli.Context) error {
 		slog.Error("failed to start template", "error", err)
 		return err
 	}
+	vertex, err := tpl.StageVertex(c.String("stage"))
+	if err != nil {
+		return err
+	}
+	vertex.SetInput(diwo.FromSlice(inputs))
+	outputs := make([]<-chan message.Message, 0)
+	for _, port := range tpl.ReadPorts(vertex.GetName()) {
+		outputs = append(outputs, diwo.Map(vertex.OutputPort(port), func(elem message.Message) message.Message {
+			elem.Headers.Port = port
+			return elem
+		}))
+	}
+	deadLetterC := vertex.DeadLetters()
+	ctx := graph.NewContext(c.Context)
+	errC := vertex.Run(ctx)
+	ctx.Synchronize()
+	slog.Info("replaying stage", "stage", vertex.GetName(), "inputs", len(inputs))
+	go func() {
+		for deadLetter := range deadLetterC {
+			slog.Warn("dead letter", "error", deadLetter.Get(message.HeaderError), "payload", string(deadLetter.Payload))
+		}
+	}()
+	go func() {
+		for err := range errC {
+			slog.Error("an error occurred in the stage", "error", err)
+		}
+	}()
+	codec := message.Codec{}
+	for output := range diwo.Merge(outputs...) {
+		if c.Bool("json") {
+			raw, err := codec.Encode(output)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(c.App.Writer, string(raw))
+		} else if output.Headers.Port != graph.PortDefault {
+			fmt.Fprintf(c.App.Writer, "[%s] %s\n", output.Headers.Port, output.Payload)
+		} else {
+			fmt.Fprintln(c.App.Writer, string(output.Payload))
+		}
+	}
+	return nil
+}
+
+// ValidateTemplate checks the template of the `template` flag as `lugh run` would, without running it.
+func ValidateTemplate(c *cli.Context) error {
+	tplPath := c.String("template")
+	if _, _, err := loadTemplate(c); err != nil {
+		return fmt.Errorf("%s is invalid: %w", tplPath, err)
+	}
+	fmt.Fprintf(c.App.Writer, "%s is valid\n", tplPath)
+	return nil
+}
+
+// LintTemplate validates the template of the `template` flag then prints its warnings. With `strict`, warnings fail.
+func LintTemplate(c *cli.Context) error {
+	tplPath := c.String("template")
+	tpl, vertices, err := loadTemplate(c)
+	if err != nil {
+		return fmt.Errorf("%s is invalid: %w", tplPath, err)
+	}
+	warnings := template.Lint(tpl, vertices)
+	for _, warning := range warnings {
+		fmt.Fprintf(c.App.Writer, "%s: warning: %s\n", tplPath, warning)
+	}
+	if len(warnings) == 0 {
+		fmt.Fprintf(c.App.Writer, "%s: no warnings\n", tplPath)
+		return nil
+	}
+	if c.Bool("strict") {
+		return fmt.Errorf("%s: %d warnings", tplPath, len(warnings))
+	}
+	return nil
+}
+
+// loadTemplate loads the template of the `template` flag, resolves and configures its plugins and builds its graph.
+func loadTemplate(c *cli.Context) (template.Template[template.Stage], []graph.IOWorkerVertex[message.Message], error) {
+	tplPath := c.String("template")
+	if tplPath == "" {
+		return template.Template[template.Stage]{}, nil, errors.New(`required flag "template" not set`)
+	}
+	variables, err := parseVariables(c)
+	if err != nil {
+		return template.Template[template.Stage]{}, nil, err
+	}
+	tpl, err := template.NewFile[template.Stage](tplPath,
+		template.WithPluginPath(c.String("plugins-path")),
+		template.WithVariables(variables),
+	)
+	if err != nil {
+		return tpl, nil, err
+	}
+	if err := tpl.Validate(); err != nil {
+		return tpl, nil, err
+	}
+	if err := template.ResolvePlugins(tpl); err != nil {
+		return tpl, nil, err
+	}
+	vertices, err := tpl.WorkerVertexIterator()
+	if err != nil {
+		return tpl, nil, err
+	}
+	if err := graph.NewIO(graph.WithVertices(vertices)).Validate(); err != nil {
+		return tpl, nil, err
+	}
+	return tpl, vertices, nil
+}
+
+// TestTemplates runs the test suites given as arguments, see `templatetest`. It fails when a case fails.
+func TestTemplates(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return errors.New("missing the test suites to run")
+	}
+	passed, failed := 0, 0
+	for _, path := range c.Args().Slice() {
+		suite, err := templatetest.Load(path)
+		if err != nil {
+			return err
+		}
+		for _, result := range suite.Run(c.Context, template.WithPluginPath(c.String("plugins-path"))) {
+			if result.Passed() {
+				passed++
+				fmt.Fprintf(c.App.Writer, "PASS  %s: %s (%s)\n", path, result.Name, result.Duration.Round(time.Millisecond))
+				continue
+			}
+			failed++
+			fmt.Fprintf(c.App.Writer, "FAIL  %s: %s (%s)\n", path, result.Name, result.Duration.Round(time.Millisecond))
+			for _, failure := range result.Failures {
+				fmt.Fprintf(c.App.Writer, "    %s\n", strings.ReplaceAll(failure, "\n", "\n    "))
+			}
+		}
+	}
+	fmt.Fprintf(c.App.Writer, "%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d test cases failed", failed)
+	}
+	return nil
+}
+
+// RunGraph runs the graph of `tpl` on the inputs sent by `sendInput`, which must close the chan once done.
+// With `record`, the inputs of each stage are recorded to be replayed with `ReplayStage`.
+func RunGraph(c *cli.Context, tpl template.Template[template.Stage], sendInput func(inputC chan<- message.Message)) error {
+	if err := tpl.Validate(); err != nil {
+		slog.Error("refusing to run invalid template", "error", err)
+		return err
+	}
 	vertices, err := tpl.WorkerVertexIterator()
 	if err != nil {
 		return err
 	}
-	g := graph.NewIO(graph.WithVertices(vertices))
-	inputC := make(chan []byte)
+	options := []graph.IOGraphOption[message.Message]{graph.WithVertices(vertices)}
+	if c.IsSet("metrics-addr") {
+		middleware, err := ServeMetrics(c.Context, c.String("metrics-addr"))
+		if err != nil {
+			return err
+		}
+		options = append(options, graph.WithMiddleware(middleware))
+	}
+	if c.IsSet("trace-file") || c.IsSet("trace-endpoint") {
+		provider, err := tracing.NewProvider(c.Context,
+			tracing.WithFile(c.String("trace-file")),
+			tracing.WithEndpoint(c.String("trace-endpoint")),
+			tracing.WithRunID(tpl.RunID()),
+		)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := provider.Shutdown(context.Background()); err != nil {
+				slog.Warn("failed to export traces", "error", err)
+			}
+		}()
+		options = append(options, graph.WithMiddleware(tracing.Middleware(provider)))
+	}
+	if c.IsSet("record") {
+		recorder, err := record.New(c.String("record"))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				slog.Warn("failed to close the recording", "error", err)
+			}
+		}()
+		slog.Info("recording the inputs of the stages, replay them with `lugh replay`", "dir", c.String("record"))
+		options = append(options, graph.WithMiddleware(recorder.Middleware()))
+	}
+	g := graph.NewIO(options...)
+	if err := g.Validate(); err != nil {
+		slog.Error("refusing to run invalid pipeline", "error", err)
+		return err
+	}
+	inputC := make(chan message.Message)
 	g.SetInput(inputC)
+	// The output of the leaf stages is drained so that they run to completion and their items are counted.
+	outputC := g.Output()
 	ctx := graph.NewContext(c.Context)
 	errC := g.Run(ctx)
 	ctx.Synchronize()
-	go SendRawInput(c, inputC)
+	go sendInput(inputC)
+	go func() {
+		for range outputC {
+		}
+	}()
 	sigc := make(chan os.Signal, 1)
 	signal.Notify(sigc, os.Interrupt)
+	defer signal.Stop(sigc)
 	for {
 		select {
 		case e, ok := <-errC:
 			if !ok {
-				return nil
+				return PrintRunResult(g.Result())
 			}
 			slog.Error("an error occurred in a stage", "error", e.Error())
 		case <-sigc:
-			return nil
+			if ctx.Err() != nil {
+				return errors.New("interrupted twice, exiting without waiting for the stages")
+			}
+			slog.Warn("interrupted, canceling the run. Interrupt again to exit right away")
+			graph.CancelRun(ctx, errors.New("interrupted"))
+		}
+	}
+}
+
+// ServeMetrics serves the metrics of the default Prometheus registry on `addr` until `ctx` is done.
+// It returns the middleware recording the stages metrics in this registry.
+func ServeMetrics(ctx context.Context, addr string) (graph.Middleware[message.Message], error) {
+	m, err := metrics.New(prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := api.Serve(ctx, addr, ctrl.NewMetrics(prometheus.DefaultGatherer)); err != nil {
+			slog.Error("metrics server stopped", "addr", addr, "error", err)
 		}
+	}()
+	return metrics.Middleware[message.Message](m), nil
+}
+
+// PrintRunResult writes the summary of the run on stderr and returns an error when the run did not succeed.
+func PrintRunResult(result graph.RunResult) error {
+	if err := result.Write(os.Stderr); err != nil {
+		slog.Warn("failed to print run result", "error", err)
 	}
+	return result.Err()
 }
 
-func SendRawInput(c *cli.Context, inputC chan []byte) {
+// SendRawInput sends the raw input and the lines of stdin, they are recorded in `store` when not nil.
+func SendRawInput(c *cli.Context, inputC chan<- message.Message, runID string, store *checkpoint.Store) {
 	defer close(inputC)
+	send := func(input message.Message) {
+		if store != nil {
+			if err := store.RecordInput(input); err != nil {
+				slog.Warn("failed to record input, it will not be sent again on resume", "error", err)
+			}
+		}
+		inputC <- input
+	}
 	if c.IsSet("raw-input") {
 		slog.Debug("sending raw input")
-		inputC <- []byte(c.String("raw-input"))
+		send(message.New([]byte(c.String("raw-input")), message.WithSource("raw-input"), message.WithRunID(runID)))
 		slog.Debug("raw input sent")
 	}
 	fi, err := os.Stdin.Stat()
@@ -147,7 +742,7 @@ func SendRawInput(c *cli.Context, inputC chan []byte) {
 		// Read each line from stdin
 		line := scanner.Text()
 		if line != "" {
-			inputC <- []byte(line)
+			send(message.New([]byte(line), message.WithSource("stdin"), message.WithRunID(runID)))
 		}
 	}
 	// Check for any errors encountered during scanning
@@ -0,0 +1,24 @@
+package ctrl
+
+import (
+	"github.com/benji-bou/lugh/core/api"
+	"github.com/benji-bou/lugh/helper"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics serves the metrics gathered by `gatherer` in the Prometheus text format on `/metrics`.
+type Metrics struct {
+	gatherer prometheus.Gatherer
+}
+
+func NewMetrics(gatherer prometheus.Gatherer) api.Ctrler {
+	return Metrics{gatherer: gatherer}
+}
+
+func (m Metrics) Route() []helper.SrvOption {
+	return []helper.SrvOption{
+		helper.WithGet("/metrics", echo.WrapHandler(promhttp.HandlerFor(m.gatherer, promhttp.HandlerOpts{}))),
+	}
+}
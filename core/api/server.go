@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+
 	"github.com/benji-bou/lugh/helper"
 	"github.com/labstack/echo/v4/middleware"
 )
@@ -10,13 +12,19 @@ type Ctrler interface {
 }
 
 func Listen(ctrl ...Ctrler) error {
-	optSrv := make([]helper.SrvOption, 0)
+	return listen(nil, ctrl...)
+}
+
+// Serve listens on `addr` until `ctx` is done.
+func Serve(ctx context.Context, addr string, ctrl ...Ctrler) error {
+	return listen([]helper.SrvOption{helper.WithContext(ctx), helper.WithAddr(addr)}, ctrl...)
+}
+
+func listen(opt []helper.SrvOption, ctrl ...Ctrler) error {
+	optSrv := append([]helper.SrvOption{helper.WithMiddleware(middleware.CORS())}, opt...)
 	for _, c := range ctrl {
 		optSrv = append(optSrv, c.Route()...)
 	}
 
-	return helper.RunServer(
-		append([]helper.SrvOption{
-			helper.WithMiddleware(middleware.CORS()),
-		}, optSrv...)...)
+	return helper.RunServer(optSrv...)
 }
@@ -0,0 +1,306 @@
+// Package checkpoint records the progress of a run in a state directory, so that an interrupted run can be resumed.
+// The state of a run lives in `<state dir>/<run id>`: `run.json` describes how to rebuild its graph, `inputs.jsonl`
+// holds the messages sent to its root stages and `stages/<stage>.jsonl` the inputs each stage fully processed,
+// keyed by message id, with the outputs they produced.
+// The journals hold the messages as they went through the stages, secrets included. They are encrypted with a key
+// derived from the passphrase of the secret store when one is given, described by `key.json`. The state is readable
+// by the user only, `run.json` and the journals of a state created without passphrase are in clear.
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
+	"github.com/benji-bou/lugh/core/secret"
+)
+
+const (
+	runFile    = "run.json"
+	inputsFile = "inputs.jsonl"
+	keyFile    = "key.json"
+	stagesDir  = "stages"
+	// checkText is encrypted in the key file to check the passphrase.
+	checkText = "lugh checkpoint"
+)
+
+var ErrRunNotFound = errors.New("run not found")
+
+// Run describes how to rebuild the graph of a run: the same template with the same variables.
+type Run struct {
+	ID          string         `json:"id"`
+	Template    string         `json:"template"`
+	PluginsPath string         `json:"pluginsPath,omitempty"`
+	Variables   map[string]any `json:"variables,omitempty"`
+	StartedAt   time.Time      `json:"startedAt"`
+}
+
+// Store is the state of a run.
+type Store struct {
+	dir    string
+	cipher *secret.Cipher
+	inputs *journal[message.Message]
+}
+
+// Open opens the state of the run `runID` in `stateDir`, it is created when missing. The journals of a new state are
+// encrypted with `passphrase` when not empty, the passphrase of an encrypted state is required to open it.
+func Open(stateDir, runID, passphrase string) (*Store, error) {
+	dir := filepath.Join(stateDir, url.PathEscape(runID))
+	if err := os.MkdirAll(filepath.Join(dir, stagesDir), 0o700); err != nil { //nolint:mnd // the state is only for the user
+		return nil, fmt.Errorf("create run state directory: %w", err)
+	}
+	cipher, err := openCipher(dir, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := openJournal[message.Message](filepath.Join(dir, inputsFile), cipher)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, cipher: cipher, inputs: inputs}, nil
+}
+
+// Encrypted tells whether the journals of the run are encrypted.
+func (s *Store) Encrypted() bool {
+	return s.cipher != nil
+}
+
+// key is the content of the key file of an encrypted state.
+type key struct {
+	Salt  []byte `json:"salt"`
+	Check []byte `json:"check"`
+}
+
+// openCipher returns the cipher of the journals in `dir`, nil when they are in clear.
+// A state is encrypted when created with a passphrase, a state started in clear stays in clear.
+func openCipher(dir, passphrase string) (*secret.Cipher, error) {
+	path := filepath.Join(dir, keyFile)
+	raw, err := os.ReadFile(path) // #nosec G304
+	if errors.Is(err, fs.ErrNotExist) {
+		if _, err := os.Stat(filepath.Join(dir, inputsFile)); passphrase == "" || err == nil {
+			return nil, nil
+		}
+		return newKey(path, passphrase)
+	} else if err != nil {
+		return nil, fmt.Errorf("read run state key: %w", err)
+	}
+	k := key{}
+	if err := json.Unmarshal(raw, &k); err != nil {
+		return nil, fmt.Errorf("decode run state key: %w", err)
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("%w: the run state is encrypted, set %s", secret.ErrNoPassphrase, secret.PassphraseEnv)
+	}
+	cipher, err := secret.NewCipher(passphrase, k.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if check, err := cipher.Open(k.Check); err != nil || string(check) != checkText {
+		return nil, fmt.Errorf("run state: %w", secret.ErrWrongPassphrase)
+	}
+	return cipher, nil
+}
+
+// newKey writes the key file of a new encrypted state.
+func newKey(path, passphrase string) (*secret.Cipher, error) {
+	salt, err := secret.NewSalt()
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := secret.NewCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	check, err := cipher.Seal([]byte(checkText))
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(key{Salt: salt, Check: check})
+	if err != nil {
+		return nil, fmt.Errorf("encode run state key: %w", err)
+	}
+	if err := os.WriteFile(path, raw, 0o600); err != nil { //nolint:mnd // the state is only for the user
+		return nil, fmt.Errorf("write run state key: %w", err)
+	}
+	return cipher, nil
+}
+
+// Resume opens the state of a run previously saved in `stateDir`, with the passphrase of its journals if encrypted.
+func Resume(stateDir, runID, passphrase string) (*Store, Run, error) {
+	raw, err := os.ReadFile(filepath.Join(stateDir, url.PathEscape(runID), runFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Run{}, fmt.Errorf("%w: %s in %s", ErrRunNotFound, runID, stateDir)
+	} else if err != nil {
+		return nil, Run{}, fmt.Errorf("read run state: %w", err)
+	}
+	run := Run{}
+	if err := json.Unmarshal(raw, &run); err != nil {
+		return nil, Run{}, fmt.Errorf("decode run state: %w", err)
+	}
+	store, err := Open(stateDir, runID, passphrase)
+	if err != nil {
+		return nil, Run{}, err
+	}
+	return store, run, nil
+}
+
+// SaveRun records how to rebuild the graph of the run.
+func (s *Store) SaveRun(run Run) error {
+	raw, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode run state: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, runFile), raw, 0o600); err != nil { //nolint:mnd // the state is only for the user
+		return fmt.Errorf("write run state: %w", err)
+	}
+	return nil
+}
+
+// RecordInput records a message sent to the root stages, it is sent again when the run is resumed.
+func (s *Store) RecordInput(input message.Message) error {
+	return s.inputs.append(input)
+}
+
+// Inputs returns the messages sent to the root stages before the store was opened, in order.
+func (s *Store) Inputs() []message.Message {
+	return s.inputs.entries()
+}
+
+// Stage opens the checkpoint of the stage `name`.
+func (s *Store) Stage(name string) (graph.Checkpoint[message.Message], error) {
+	entries, err := openJournal[entry](filepath.Join(s.dir, stagesDir, url.PathEscape(name)+".jsonl"), s.cipher)
+	if err != nil {
+		return nil, err
+	}
+	stage := &Stage{journal: entries, outputs: make(map[string][]message.Message)}
+	for _, e := range entries.entries() {
+		stage.outputs[e.Input] = e.Outputs
+	}
+	return stage, nil
+}
+
+// entry is a line of the journal of a stage.
+type entry struct {
+	Input   string            `json:"input"`
+	Outputs []message.Message `json:"outputs,omitempty"`
+}
+
+// Stage is the checkpoint of a stage, the inputs are identified by their `message.Headers.ID`.
+type Stage struct {
+	journal *journal[entry]
+	outputs map[string][]message.Message
+	mux     sync.RWMutex
+}
+
+func (st *Stage) Outputs(input message.Message) ([]message.Message, bool) {
+	if input.Headers.ID == "" {
+		return nil, false
+	}
+	st.mux.RLock()
+	defer st.mux.RUnlock()
+	outputs, ok := st.outputs[input.Headers.ID]
+	return outputs, ok
+}
+
+func (st *Stage) Record(input message.Message, outputs []message.Message) error {
+	if input.Headers.ID == "" {
+		return errors.New("message without id")
+	}
+	st.mux.Lock()
+	st.outputs[input.Headers.ID] = outputs
+	st.mux.Unlock()
+	return st.journal.append(entry{Input: input.Headers.ID, Outputs: outputs})
+}
+
+// journal is a JSON lines file only appended to. A run may be killed while writing a line,
+// its truncated last line is dropped when the journal is opened again.
+// With a cipher, each line is the encrypted JSON encoded in base64.
+type journal[T any] struct {
+	path   string
+	cipher *secret.Cipher
+	loaded []T
+	mux    sync.Mutex
+}
+
+func openJournal[T any](path string, cipher *secret.Cipher) (*journal[T], error) {
+	j := &journal[T]{path: path, cipher: cipher}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return j, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read checkpoint journal: %w", err)
+	}
+	complete := raw[:bytes.LastIndexByte(raw, '\n')+1]
+	if len(complete) < len(raw) {
+		// Drop the truncated line, the next line is appended after the last complete one.
+		if err := os.Truncate(path, int64(len(complete))); err != nil {
+			return nil, fmt.Errorf("repair checkpoint journal: %w", err)
+		}
+	}
+	for line := range bytes.Lines(complete) {
+		var elem T
+		if err := j.decode(line, &elem); err != nil {
+			slog.Warn("skip invalid checkpoint journal line", "journal", path, "error", err)
+			continue
+		}
+		j.loaded = append(j.loaded, elem)
+	}
+	return j, nil
+}
+
+func (j *journal[T]) entries() []T {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	return j.loaded
+}
+
+func (j *journal[T]) decode(line []byte, elem *T) error {
+	if j.cipher == nil {
+		return json.Unmarshal(line, elem)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSuffix(line, []byte("\n"))))
+	if err != nil {
+		return err
+	}
+	raw, err := j.cipher.Open(sealed)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, elem)
+}
+
+func (j *journal[T]) append(elem T) error {
+	raw, err := json.Marshal(elem)
+	if err != nil {
+		return fmt.Errorf("encode checkpoint: %w", err)
+	}
+	if j.cipher != nil {
+		sealed, err := j.cipher.Seal(raw)
+		if err != nil {
+			return fmt.Errorf("encrypt checkpoint: %w", err)
+		}
+		raw = []byte(base64.StdEncoding.EncodeToString(sealed))
+	}
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:mnd // the state is only for the user
+	if err != nil {
+		return fmt.Errorf("open checkpoint journal: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(raw, '\n')); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	return nil
+}
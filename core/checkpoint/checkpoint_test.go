@@ -0,0 +1,132 @@
+package checkpoint_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benji-bou/lugh/core/checkpoint"
+	"github.com/benji-bou/lugh/core/message"
+	"github.com/benji-bou/lugh/core/secret"
+)
+
+func TestResume(t *testing.T) {
+	stateDir := t.TempDir()
+	store, err := checkpoint.Open(stateDir, "run", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveRun(checkpoint.Run{ID: "run", Template: "template.yml", Variables: map[string]any{"key": "value"}}); err != nil {
+		t.Fatal(err)
+	}
+	input := message.New([]byte("input"))
+	if err := store.RecordInput(input); err != nil {
+		t.Fatal(err)
+	}
+	stage, err := store.Stage("stage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stage.Record(input, []message.Message{input.Derive([]byte("output"))}); err != nil {
+		t.Fatal(err)
+	}
+	// A run killed while writing a line leaves it truncated.
+	journal, err := os.OpenFile(filepath.Join(stateDir, "run", "stages", "stage.jsonl"), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := journal.WriteString(`{"input":"trunc`); err != nil {
+		t.Fatal(err)
+	}
+	journal.Close()
+
+	store, run, err := checkpoint.Resume(stateDir, "run", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Template != "template.yml" || run.Variables["key"] != "value" {
+		t.Errorf("unexpected run %+v", run)
+	}
+	if inputs := store.Inputs(); len(inputs) != 1 || inputs[0].Headers.ID != input.Headers.ID {
+		t.Errorf("the inputs of the run should be recorded with their id, got %+v", inputs)
+	}
+	stage, err = store.Stage("stage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs, ok := stage.Outputs(input)
+	if !ok || len(outputs) != 1 || string(outputs[0].Payload) != "output" {
+		t.Errorf("the outputs of the input should be recorded, got %v %v", outputs, ok)
+	}
+	other := message.New([]byte("other"))
+	if _, ok := stage.Outputs(other); ok {
+		t.Error("an input never processed should not be done")
+	}
+	if err := stage.Record(other, nil); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(filepath.Join(stateDir, "run", "stages", "stage.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSuffix(string(raw), "\n"), "\n"); len(lines) != 2 || strings.Contains(string(raw), "trunc") {
+		t.Errorf("the truncated line should be dropped, got %q", raw)
+	}
+	if _, _, err := checkpoint.Resume(stateDir, "unknown", ""); !errors.Is(err, checkpoint.ErrRunNotFound) {
+		t.Error("resuming an unknown run should fail")
+	}
+}
+
+func TestEncryptedResume(t *testing.T) {
+	stateDir := t.TempDir()
+	store, err := checkpoint.Open(stateDir, "run", "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveRun(checkpoint.Run{ID: "run", Template: "template.yml"}); err != nil {
+		t.Fatal(err)
+	}
+	input := message.New([]byte("token=s3cr3t"))
+	if err := store.RecordInput(input); err != nil {
+		t.Fatal(err)
+	}
+	stage, err := store.Stage("stage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stage.Record(input, []message.Message{input.Derive([]byte("s3cr3t output"))}); err != nil {
+		t.Fatal(err)
+	}
+	for _, journal := range []string{"inputs.jsonl", filepath.Join("stages", "stage.jsonl")} {
+		raw, err := os.ReadFile(filepath.Join(stateDir, "run", journal))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(raw), "s3cr3t") || strings.Contains(string(raw), input.Headers.ID) {
+			t.Errorf("journal %s should be encrypted, got %q", journal, raw)
+		}
+	}
+
+	if _, _, err := checkpoint.Resume(stateDir, "run", ""); !errors.Is(err, secret.ErrNoPassphrase) {
+		t.Errorf("resuming an encrypted run without passphrase should fail, got %v", err)
+	}
+	if _, _, err := checkpoint.Resume(stateDir, "run", "wrong"); !errors.Is(err, secret.ErrWrongPassphrase) {
+		t.Errorf("resuming an encrypted run with a wrong passphrase should fail, got %v", err)
+	}
+	store, _, err = checkpoint.Resume(stateDir, "run", "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inputs := store.Inputs(); len(inputs) != 1 || string(inputs[0].Payload) != "token=s3cr3t" {
+		t.Errorf("the inputs of the run should be decrypted, got %+v", inputs)
+	}
+	stage, err = store.Stage("stage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outputs, ok := stage.Outputs(input); !ok || len(outputs) != 1 || string(outputs[0].Payload) != "s3cr3t output" {
+		t.Errorf("the outputs of the input should be decrypted, got %v %v", outputs, ok)
+	}
+}
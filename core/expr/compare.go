@@ -0,0 +1,107 @@
+package expr
+
+import (
+	"cmp"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var comparators = map[string]func(left, right any) bool{
+	"==":       equal,
+	"!=":       func(left, right any) bool { return !equal(left, right) },
+	"<":        ordered(func(c int) bool { return c < 0 }),
+	"<=":       ordered(func(c int) bool { return c <= 0 }),
+	">":        ordered(func(c int) bool { return c > 0 }),
+	">=":       ordered(func(c int) bool { return c >= 0 }),
+	"contains": contains,
+	"in": func(left, right any) bool {
+		return contains(right, left)
+	},
+	"startsWith": stringPair(strings.HasPrefix),
+	"endsWith":   stringPair(strings.HasSuffix),
+}
+
+// numbers returns both values as numbers when one is a number and the other a number or a string holding one.
+func numbers(left, right any) (float64, float64, bool) {
+	_, leftIsNumber := left.(float64)
+	_, rightIsNumber := right.(float64)
+	if !leftIsNumber && !rightIsNumber {
+		return 0, 0, false
+	}
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	return l, r, lok && rok
+}
+
+func toNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func equal(left, right any) bool {
+	if l, r, ok := numbers(left, right); ok {
+		return l == r
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+func ordered(accept func(c int) bool) func(left, right any) bool {
+	return func(left, right any) bool {
+		if l, r, ok := numbers(left, right); ok {
+			return accept(cmp.Compare(l, r))
+		}
+		l, lok := left.(string)
+		r, rok := right.(string)
+		return lok && rok && accept(strings.Compare(l, r))
+	}
+}
+
+// contains tells whether the string `container` holds the string `elem`, or the list `container` an element equal to `elem`.
+func contains(container, elem any) bool {
+	switch c := container.(type) {
+	case string:
+		e, ok := elem.(string)
+		return ok && strings.Contains(c, e)
+	case []any:
+		return slices.ContainsFunc(c, func(item any) bool { return equal(item, elem) })
+	default:
+		return false
+	}
+}
+
+// stringPair applies `accept` when both values are strings.
+func stringPair(accept func(s, affix string) bool) func(left, right any) bool {
+	return func(left, right any) bool {
+		l, lok := left.(string)
+		r, rok := right.(string)
+		return lok && rok && accept(l, r)
+	}
+}
+
+func truthy(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
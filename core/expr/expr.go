@@ -0,0 +1,166 @@
+// Package expr evaluates small boolean expressions over the messages flowing between stages.
+//
+// The payload is decoded as JSON and read with paths: `.` is the whole payload, `.status_code` a key,
+// `.hosts[0]` an element and `.["content-type"]` a key needing quotes. A payload which is not JSON is a string.
+// The headers are read with `$` followed by a `message.Field` path, e.g. `$source` or `$values.target`.
+// The literals are numbers, strings quoted with `"` (Go escapes) or `'` (raw), `true`, `false`, `null`
+// and lists like `[200, 204]`.
+//
+// The operators, from the loosest to the tightest, are `||`, `&&`, the comparisons `==`, `!=`, `<`, `<=`, `>`, `>=`,
+// `contains`, `startsWith`, `endsWith`, `matches` (a regular expression literal) and `in` (a list or a string),
+// then `!`. Parentheses group sub-expressions.
+// Strings holding a number are compared as numbers with numbers. A missing key is `null`. The comparisons of values
+// of unrelated types are false, except `!=`. `null`, `false`, `0` and the empty string, list and object are falsy.
+package expr
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/benji-bou/lugh/core/message"
+)
+
+var ErrSyntax = errors.New("syntax error")
+
+// Expr is a compiled expression, safe for concurrent use.
+type Expr struct {
+	source string
+	root   node
+}
+
+// Compile parses `source`. The errors give the offset of the faulty token in `source`.
+func Compile(source string) (*Expr, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok)
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Eval returns the value of the expression for `m`.
+func (e *Expr) Eval(m message.Message) any {
+	return e.root.eval(&env{msg: m})
+}
+
+// Match tells whether the expression is truthy for `m`.
+func (e *Expr) Match(m message.Message) bool {
+	return truthy(e.Eval(m))
+}
+
+// env is the message an expression is evaluated on, its payload is decoded once.
+type env struct {
+	msg     message.Message
+	doc     any
+	decoded bool
+}
+
+func (e *env) payload() any {
+	if !e.decoded {
+		e.decoded = true
+		if err := json.Unmarshal(e.msg.Payload, &e.doc); err != nil {
+			e.doc = string(e.msg.Payload)
+		}
+	}
+	return e.doc
+}
+
+type node interface {
+	eval(e *env) any
+}
+
+type literal struct {
+	value any
+}
+
+func (l literal) eval(*env) any {
+	return l.value
+}
+
+type path struct {
+	keys []string
+}
+
+func (p path) eval(e *env) any {
+	value := e.payload()
+	for _, key := range p.keys {
+		switch v := value.(type) {
+		case map[string]any:
+			value = v[key]
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil
+			}
+			value = v[index]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+type field struct {
+	get func(m message.Message) string
+}
+
+func (f field) eval(e *env) any {
+	return f.get(e.msg)
+}
+
+type list struct {
+	elems []node
+}
+
+func (l list) eval(e *env) any {
+	values := make([]any, len(l.elems))
+	for index, elem := range l.elems {
+		values[index] = elem.eval(e)
+	}
+	return values
+}
+
+type not struct {
+	operand node
+}
+
+func (n not) eval(e *env) any {
+	return !truthy(n.operand.eval(e))
+}
+
+type and struct {
+	left, right node
+}
+
+func (a and) eval(e *env) any {
+	return truthy(a.left.eval(e)) && truthy(a.right.eval(e))
+}
+
+type or struct {
+	left, right node
+}
+
+func (o or) eval(e *env) any {
+	return truthy(o.left.eval(e)) || truthy(o.right.eval(e))
+}
+
+type comparison struct {
+	left, right node
+	compare     func(left, right any) bool
+}
+
+func (c comparison) eval(e *env) any {
+	return c.compare(c.left.eval(e), c.right.eval(e))
+}
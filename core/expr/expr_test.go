@@ -0,0 +1,63 @@
+package expr_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/benji-bou/lugh/core/expr"
+	"github.com/benji-bou/lugh/core/message"
+)
+
+func TestMatch(t *testing.T) {
+	item := message.New(
+		[]byte(`{"status_code": 404, "host": "api.example.com", "tags": ["web", "prod"], "content-type": "json", "ok": false}`),
+		message.WithSource("probe"), message.WithValue("port", "8080"),
+	)
+	testCases := map[string]bool{
+		`.status_code >= 400 && .host endsWith ".example.com"`: true,
+		`.status_code >= 500 || .host startsWith "www."`:       false,
+		`!(.status_code == 404)`:                               false,
+		`.status_code in [200, 404]`:                           true,
+		`.tags contains "prod" && .tags[0] == "web"`:           true,
+		`.["content-type"] == 'json'`:                          true,
+		`.missing == null && !.missing && .missing != 1`:       true,
+		`.missing < 1 || .host > 1`:                            false,
+		`.host matches "^api\\.[a-z]+\\.com$"`:                 true,
+		`$source == "probe" && $values.port >= 8000`:           true,
+		`.ok || .tags`:       true,
+		`"example" in .host`: true,
+	}
+	for source, expected := range testCases {
+		e, err := expr.Compile(source)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", source, err)
+			continue
+		}
+		if got := e.Match(item); got != expected {
+			t.Errorf("%s: got %v, expected %v", source, got, expected)
+		}
+	}
+	text, err := expr.Compile(`. == "plain text"`)
+	if err != nil || !text.Match(message.New([]byte("plain text"))) {
+		t.Errorf("a payload which is not JSON should be a string, got %v", err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, source := range []string{
+		`.status_code >=`,
+		`.status_code >= 400 &&`,
+		`(.a == 1`,
+		`.a == "unterminated`,
+		`.a matches .b`,
+		`.a matches "("`,
+		`.a..b`,
+		`$unknown == 1`,
+		`.a == 1 2`,
+		`.a ~ 1`,
+	} {
+		if _, err := expr.Compile(source); !errors.Is(err, expr.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", source, err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenPath
+	tokenField
+	tokenIdent
+	tokenOperator
+)
+
+// token is a lexeme of an expression, `pos` is its offset in the source.
+// A path token holds its keys, a string token its unquoted value.
+type token struct {
+	kind  tokenKind
+	text  string
+	keys  []string
+	value string
+	pos   int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators are sorted so that the longest ones are matched first.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ","}
+
+type lexer struct {
+	source string
+	pos    int
+}
+
+func tokenize(source string) ([]token, error) {
+	l := &lexer{source: source}
+	tokens := []token{}
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.kind == tokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) errorf(pos int, format string, args ...any) error {
+	return fmt.Errorf("%w at %d: %s", ErrSyntax, pos, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.source) && unicode.IsSpace(rune(l.source[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.source) {
+		return token{kind: tokenEOF, pos: start}, nil
+	}
+	c := l.source[l.pos]
+	switch {
+	case c == '.':
+		return l.path()
+	case c == '$':
+		l.pos++
+		for l.pos < len(l.source) && (isIdentChar(l.source[l.pos]) || l.source[l.pos] == '.') {
+			l.pos++
+		}
+		return token{kind: tokenField, text: l.source[start:l.pos], value: l.source[start+1 : l.pos], pos: start}, nil
+	case c == '"' || c == '\'':
+		value, err := l.quoted()
+		if err != nil {
+			return token{}, err
+		}
+		return token{kind: tokenString, text: l.source[start:l.pos], value: value, pos: start}, nil
+	case c == '-' || isDigit(c):
+		l.pos++
+		for l.pos < len(l.source) && (isDigit(l.source[l.pos]) || strings.ContainsRune(".eE+-", rune(l.source[l.pos]))) {
+			l.pos++
+		}
+		return token{kind: tokenNumber, text: l.source[start:l.pos], pos: start}, nil
+	case isIdentChar(c):
+		for l.pos < len(l.source) && isIdentChar(l.source[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokenIdent, text: l.source[start:l.pos], pos: start}, nil
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(l.source[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokenOperator, text: op, pos: start}, nil
+		}
+	}
+	return token{}, l.errorf(start, "unexpected character %q", c)
+}
+
+// path reads `.`, `.key`, `.key.key`, `.key[0]` or `.["key"]`.
+func (l *lexer) path() (token, error) {
+	start := l.pos
+	keys := []string{}
+	for l.pos < len(l.source) {
+		switch l.source[l.pos] {
+		case '.':
+			l.pos++
+			keyStart := l.pos
+			for l.pos < len(l.source) && isIdentChar(l.source[l.pos]) {
+				l.pos++
+			}
+			if l.pos > keyStart {
+				keys = append(keys, l.source[keyStart:l.pos])
+			} else if l.pos > start+1 {
+				return token{}, l.errorf(keyStart, "missing key after %q", l.source[start:keyStart])
+			}
+		case '[':
+			key, err := l.index()
+			if err != nil {
+				return token{}, err
+			}
+			keys = append(keys, key)
+		default:
+			return token{kind: tokenPath, text: l.source[start:l.pos], keys: keys, pos: start}, nil
+		}
+	}
+	return token{kind: tokenPath, text: l.source[start:l.pos], keys: keys, pos: start}, nil
+}
+
+// index reads `[0]` or `["key"]`.
+func (l *lexer) index() (string, error) {
+	start := l.pos
+	l.pos++
+	var key string
+	if l.pos < len(l.source) && (l.source[l.pos] == '"' || l.source[l.pos] == '\'') {
+		value, err := l.quoted()
+		if err != nil {
+			return "", err
+		}
+		key = value
+	} else {
+		keyStart := l.pos
+		for l.pos < len(l.source) && isDigit(l.source[l.pos]) {
+			l.pos++
+		}
+		key = l.source[keyStart:l.pos]
+		if key == "" {
+			return "", l.errorf(keyStart, "index must be a number or a string")
+		}
+	}
+	if l.pos >= len(l.source) || l.source[l.pos] != ']' {
+		return "", l.errorf(start, "unclosed index")
+	}
+	l.pos++
+	return key, nil
+}
+
+// quoted reads a string. Double quoted strings support the Go escapes, single quoted ones are raw.
+func (l *lexer) quoted() (string, error) {
+	start := l.pos
+	quote := l.source[l.pos]
+	l.pos++
+	for l.pos < len(l.source) && l.source[l.pos] != quote {
+		if quote == '"' && l.source[l.pos] == '\\' {
+			l.pos++
+		}
+		l.pos++
+	}
+	if l.pos >= len(l.source) {
+		return "", l.errorf(start, "unterminated string")
+	}
+	l.pos++
+	raw := l.source[start:l.pos]
+	if quote == '\'' {
+		return raw[1 : len(raw)-1], nil
+	}
+	value, err := strconv.Unquote(raw)
+	if err != nil {
+		return "", l.errorf(start, "invalid string %s", raw)
+	}
+	return value, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '-' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
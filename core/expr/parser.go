@@ -0,0 +1,195 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/benji-bou/lugh/core/message"
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token when it is the operator or keyword `text`.
+func (p *parser) accept(text string) bool {
+	tok := p.peek()
+	if (tok.kind == tokenOperator || tok.kind == tokenIdent) && tok.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		tok := p.peek()
+		return p.errorf(tok, "expected %q, got %s", text, tok)
+	}
+	return nil
+}
+
+func (*parser) errorf(tok token, format string, args ...any) error {
+	return fmt.Errorf("%w at %d: %s", ErrSyntax, tok.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left: left, right: right}
+	}
+	return left, nil
+}
+
+// comparison parses an operand optionally compared to another one. Comparisons do not chain.
+func (p *parser) comparison() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	if tok.kind != tokenOperator && tok.kind != tokenIdent {
+		return left, nil
+	}
+	if tok.text == "matches" {
+		p.advance()
+		return p.matches(left)
+	}
+	compare, ok := comparators[tok.text]
+	if !ok {
+		return left, nil
+	}
+	p.advance()
+	right, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return comparison{left: left, right: right, compare: compare}, nil
+}
+
+// matches compiles the regular expression literal on the right of `matches`.
+func (p *parser) matches(left node) (node, error) {
+	tok := p.advance()
+	if tok.kind != tokenString {
+		return nil, p.errorf(tok, "matches expects a string literal, got %s", tok)
+	}
+	re, err := regexp.Compile(tok.value)
+	if err != nil {
+		return nil, p.errorf(tok, "invalid regular expression: %v", err)
+	}
+	return comparison{left: left, right: literal{}, compare: func(left, _ any) bool {
+		str, ok := left.(string)
+		return ok && re.MatchString(str)
+	}}, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.accept("!") {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return not{operand: operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	tok := p.advance()
+	switch tok.kind {
+	case tokenNumber:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid number %s", tok)
+		}
+		return literal{value: value}, nil
+	case tokenString:
+		return literal{value: tok.value}, nil
+	case tokenPath:
+		return path{keys: tok.keys}, nil
+	case tokenField:
+		get, err := message.Field(tok.value)
+		if err != nil {
+			return nil, p.errorf(tok, "%v", err)
+		}
+		return field{get: get}, nil
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return literal{value: true}, nil
+		case "false":
+			return literal{value: false}, nil
+		case "null":
+			return literal{}, nil
+		}
+	case tokenOperator:
+		switch tok.text {
+		case "(":
+			inner, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		case "[":
+			return p.list()
+		}
+	case tokenEOF:
+	}
+	return nil, p.errorf(tok, "unexpected %s", tok)
+}
+
+func (p *parser) list() (node, error) {
+	l := list{}
+	if p.accept("]") {
+		return l, nil
+	}
+	for {
+		elem, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		l.elems = append(l.elems, elem)
+		if p.accept("]") {
+			return l, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
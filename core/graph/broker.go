@@ -0,0 +1,371 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// BufferPolicy decides what a subscriber does with a new item when its buffer is full.
+type BufferPolicy string
+
+const (
+	// BufferBlock waits for the subscriber to make room. A slow subscriber slows down the whole fan-out.
+	BufferBlock BufferPolicy = "block"
+	// BufferDropOldest discards the oldest buffered item to make room for the new one.
+	BufferDropOldest BufferPolicy = "dropOldest"
+	// BufferDropNewest discards the new item.
+	BufferDropNewest BufferPolicy = "dropNewest"
+	// BufferSpill writes the overflow to a disk queue read back once the subscriber catches up.
+	BufferSpill BufferPolicy = "spill"
+	// BufferDisk writes every item to a disk queue, the producer never waits and the memory used stays bounded
+	// whatever the lag of the subscriber. `Size` items are read ahead from the disk, unless the queue is persistent.
+	BufferDisk BufferPolicy = "disk"
+)
+
+var ErrUnknownBufferPolicy = errors.New("unknown buffer policy")
+
+func ParseBufferPolicy(policy string) (BufferPolicy, error) {
+	switch p := BufferPolicy(policy); p {
+	case BufferBlock, BufferDropOldest, BufferDropNewest, BufferSpill, BufferDisk:
+		return p, nil
+	case "":
+		return BufferBlock, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownBufferPolicy, policy)
+	}
+}
+
+// Codec encodes and decodes items. It is required to spill items of any other type than []byte to disk.
+type Codec[K any] interface {
+	Encode(elem K) ([]byte, error)
+	Decode(raw []byte) (K, error)
+}
+
+type BytesCodec struct{}
+
+func (BytesCodec) Encode(elem []byte) ([]byte, error) {
+	return elem, nil
+}
+
+func (BytesCodec) Decode(raw []byte) ([]byte, error) {
+	return raw, nil
+}
+
+// BufferConfig configures the queue of each subscriber of a `BroadcasterIOWorker`.
+// A zero `Size` keeps the default unbounded buffering, except with the `BufferDisk` policy.
+// The disk queues of the `BufferSpill` and `BufferDisk` policies are stored in `SpillDir`, the system temporary
+// directory if empty, in segment files of `SegmentSize` bytes, `DefaultSegmentSize` if zero.
+// When `QueueDir` is set, a directory of `SpillDir`, each subscriber keeps its disk queue in a stable directory of
+// `QueueDir`, named after the port and the subscriber, which is reopened by the next run with the same `QueueDir`:
+// the items left by a crashed run are delivered first. An item is removed from such a queue once delivered, an item
+// delivered just before the crash is delivered again. The directories are removed once the subscribers are drained.
+type BufferConfig[K any] struct {
+	Size        int
+	Policy      BufferPolicy
+	SpillDir    string
+	QueueDir    string
+	SegmentSize int64
+	Codec       Codec[K]
+}
+
+// persistent tells whether the disk queues are kept across runs.
+func (bc BufferConfig[K]) persistent() bool {
+	return bc.QueueDir != ""
+}
+
+// sub returns the config of the queues stored in the `name` directory of `QueueDir`.
+func (bc BufferConfig[K]) sub(name string) BufferConfig[K] {
+	if bc.persistent() {
+		bc.QueueDir = filepath.Join(bc.QueueDir, name)
+	}
+	return bc
+}
+
+// bounded tells whether the subscribers need their own queue.
+func (bc BufferConfig[K]) bounded() bool {
+	return bc.Size > 0 || bc.Policy == BufferDisk
+}
+
+// BufferStats counts the items that did not go straight through a subscriber buffer.
+type BufferStats struct {
+	Dropped uint64
+	Spilled uint64
+}
+
+func (bs BufferStats) Add(other BufferStats) BufferStats {
+	return BufferStats{Dropped: bs.Dropped + other.Dropped, Spilled: bs.Spilled + other.Spilled}
+}
+
+// boundedBroker broadcasts the items received on src to every subscriber.
+// Each subscriber owns a bounded queue applying the configured `BufferPolicy` when full.
+type boundedBroker[K any] struct {
+	src         <-chan K
+	config      BufferConfig[K]
+	subscribers []*subscriber[K]
+	isSrcClosed bool
+	mux         sync.Mutex
+}
+
+func newBoundedBroker[K any](src <-chan K, config BufferConfig[K]) *boundedBroker[K] {
+	if config.Codec == nil {
+		if codec, ok := any(BytesCodec{}).(Codec[K]); ok {
+			config.Codec = codec
+		}
+	}
+	b := &boundedBroker[K]{src: src, config: config}
+	go b.run()
+	return b
+}
+
+func (b *boundedBroker[K]) run() {
+	for elem := range b.src {
+		b.mux.Lock()
+		for _, s := range b.subscribers {
+			s.push(elem)
+		}
+		b.mux.Unlock()
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.isSrcClosed = true
+	for _, s := range b.subscribers {
+		s.close()
+	}
+	if stats := b.stats(); stats.Dropped > 0 || (stats.Spilled > 0 && b.config.Policy != BufferDisk) {
+		slog.Warn("broadcast buffers overflowed", "policy", b.config.Policy, "size", b.config.Size, "dropped", stats.Dropped, "spilled", stats.Spilled)
+	}
+}
+
+func (b *boundedBroker[K]) Subscribe() <-chan K {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.isSrcClosed {
+		c := make(chan K)
+		close(c)
+		return c
+	}
+	s := newSubscriber(b.config.sub(strconv.Itoa(len(b.subscribers))))
+	b.subscribers = append(b.subscribers, s)
+	go s.drain()
+	return s.outputC
+}
+
+func (b *boundedBroker[K]) Stats() BufferStats {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.stats()
+}
+
+func (b *boundedBroker[K]) stats() BufferStats {
+	res := BufferStats{}
+	for _, s := range b.subscribers {
+		res = res.Add(s.stats())
+	}
+	return res
+}
+
+type subscriber[K any] struct {
+	config  BufferConfig[K]
+	queue   []K
+	spill   *diskQueue[K]
+	closed  bool
+	outputC chan K
+	dropped atomic.Uint64
+	spilled atomic.Uint64
+	mux     sync.Mutex
+	cond    *sync.Cond
+}
+
+func newSubscriber[K any](config BufferConfig[K]) *subscriber[K] {
+	s := &subscriber[K]{config: config, queue: make([]K, 0, config.Size), outputC: make(chan K)}
+	s.cond = sync.NewCond(&s.mux)
+	if _, err := os.Stat(config.QueueDir); config.persistent() && err == nil {
+		// The items left by the previous run are delivered before the new ones.
+		spill, err := newSpill(config)
+		if err != nil {
+			slog.Error("subscriber buffer can't reopen its disk queue, the items left by the previous run are lost", "dir", config.QueueDir, "error", err)
+		} else {
+			s.spill = spill
+		}
+	}
+	return s
+}
+
+func (s *subscriber[K]) stats() BufferStats {
+	return BufferStats{Dropped: s.dropped.Load(), Spilled: s.spilled.Load()}
+}
+
+// len returns how many items wait in the queue of the subscriber, spilled ones included.
+func (s *subscriber[K]) len() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.queue) + s.spill.Len()
+}
+
+func (s *subscriber[K]) push(elem K) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	defer s.cond.Broadcast()
+	// Once items are spilled, the new ones are spilled too to keep the order.
+	if s.spill.Len() > 0 || s.config.Policy == BufferDisk {
+		s.spillElem(elem)
+		return
+	}
+	if len(s.queue) < s.config.Size {
+		s.queue = append(s.queue, elem)
+		return
+	}
+	switch s.config.Policy {
+	case BufferDropNewest:
+		s.dropped.Add(1)
+	case BufferDropOldest:
+		s.queue = append(s.queue[1:], elem)
+		s.dropped.Add(1)
+	case BufferSpill:
+		s.spillElem(elem)
+	default:
+		for len(s.queue) >= s.config.Size {
+			s.cond.Wait()
+		}
+		s.queue = append(s.queue, elem)
+	}
+}
+
+func (s *subscriber[K]) spillElem(elem K) {
+	if s.spill == nil {
+		spill, err := newSpill(s.config)
+		if err != nil {
+			slog.Error("subscriber buffer can't spill to disk, dropping item", "error", err)
+			s.dropped.Add(1)
+			return
+		}
+		s.spill = spill
+	}
+	if err := s.spill.Write(elem); err != nil {
+		slog.Error("subscriber buffer failed to spill item, dropping it", "error", err)
+		s.dropped.Add(1)
+		return
+	}
+	s.spilled.Add(1)
+}
+
+func (s *subscriber[K]) close() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.closed = true
+	s.cond.Broadcast()
+}
+
+// drain forwards the buffered items to the output chan and refills the queue from the disk queue.
+// A persistent disk queue is not read ahead, each item is removed from the disk once delivered.
+func (s *subscriber[K]) drain() {
+	defer func() {
+		if err := s.spill.Remove(); err != nil {
+			slog.Warn("subscriber buffer failed to remove disk queue", "error", err)
+		}
+		if s.config.persistent() {
+			removeEmptyDirs(filepath.Dir(s.config.QueueDir), s.config.SpillDir)
+		}
+		close(s.outputC)
+	}()
+	for {
+		s.mux.Lock()
+		for len(s.queue) == 0 && s.spill.Len() == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if len(s.queue) == 0 && s.spill.Len() == 0 {
+			s.mux.Unlock()
+			return
+		}
+		if len(s.queue) == 0 && s.config.persistent() {
+			s.drainPersistent()
+			continue
+		}
+		if len(s.queue) == 0 {
+			if s.refill(); len(s.queue) == 0 {
+				// The spilled items could not be read back.
+				s.mux.Unlock()
+				continue
+			}
+		}
+		elem := s.queue[0]
+		s.queue = s.queue[1:]
+		s.refill()
+		s.cond.Broadcast()
+		s.mux.Unlock()
+		s.outputC <- elem
+	}
+}
+
+// drainPersistent delivers the first item of the persistent disk queue and then removes it.
+// It is called with the lock held and releases it.
+func (s *subscriber[K]) drainPersistent() {
+	queued := s.spill.Len()
+	elem, err := s.spill.Peek()
+	if err != nil {
+		s.dropUnreadable(queued, err)
+		s.mux.Unlock()
+		return
+	}
+	s.mux.Unlock()
+	s.outputC <- elem
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.spill.Pop()
+	s.cond.Broadcast()
+}
+
+func (s *subscriber[K]) refill() {
+	if s.config.persistent() {
+		return
+	}
+	for len(s.queue) < max(s.config.Size, 1) && s.spill.Len() > 0 {
+		queued := s.spill.Len()
+		elem, err := s.spill.Read()
+		if err != nil {
+			s.dropUnreadable(queued, err)
+			continue
+		}
+		s.queue = append(s.queue, elem)
+	}
+}
+
+// dropUnreadable counts the items removed by a failed read of the disk queue, which held `queued` items.
+// The loops reading the queue end once it is empty.
+func (s *subscriber[K]) dropUnreadable(queued int, err error) {
+	lost := max(queued-s.spill.Len(), 1)
+	slog.Error("subscriber buffer failed to read spilled items, dropping them", "error", err, "dropped", lost)
+	s.dropped.Add(uint64(lost)) //nolint:gosec // lost is positive
+}
+
+// newSpill opens the disk queue of `config.QueueDir`, or of a new directory of `config.SpillDir` when not persistent.
+func newSpill[K any](config BufferConfig[K]) (*diskQueue[K], error) {
+	if config.Codec == nil {
+		return nil, errors.New("no codec to encode spilled items")
+	}
+	if config.persistent() {
+		return openDiskQueue(config.QueueDir, config.SegmentSize, config.Codec)
+	}
+	dir, err := os.MkdirTemp(config.SpillDir, "lugh-queue-*")
+	if err != nil {
+		return nil, fmt.Errorf("create disk queue: %w", err)
+	}
+	return openDiskQueue(dir, config.SegmentSize, config.Codec)
+}
+
+// removeEmptyDirs removes `dir` and its parents up to `root`, excluded, as long as they are empty.
+func removeEmptyDirs(dir, root string) {
+	for root != "" && dir != root && strings.HasPrefix(dir, root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,73 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+var ErrCheckpointNotSupported = errors.New("checkpoint not supported")
+
+// Checkpoint records the inputs a worker fully processed with the outputs they produced.
+// A resumed run emits the recorded outputs again instead of processing the input twice,
+// so that the children which did not process them yet still receive them.
+type Checkpoint[K any] interface {
+	// Outputs returns the outputs recorded for `input`, false when it was not fully processed yet.
+	Outputs(input K) ([]K, bool)
+	// Record is called once `input` is fully processed without error.
+	Record(input K, outputs []K) error
+}
+
+// CheckpointConfigurer is implemented by the IOWorker able to tell when they are done with an input.
+// It must be called before `Run`. Runners read their inputs ahead or hold them, like batches, so only those tracking
+// each input themselves implement it, like the gRPC plugins whose server knows when the remote worker is done.
+// The inputs of the others are all processed again on resume.
+type CheckpointConfigurer[K any] interface {
+	SetCheckpoint(checkpoint Checkpoint[K]) error
+}
+
+// SetCheckpoint configures the checkpoint of the input when it implements `CheckpointConfigurer`.
+func SetCheckpoint[K any](v any, checkpoint Checkpoint[K]) error {
+	if configurer, ok := v.(CheckpointConfigurer[K]); ok {
+		return configurer.SetCheckpoint(checkpoint)
+	}
+	return fmt.Errorf("%w by %T", ErrCheckpointNotSupported, v)
+}
+
+// SetCheckpoint records each input once `Worker.Work` returned without error.
+func (s *syncWorker[K]) SetCheckpoint(checkpoint Checkpoint[K]) error {
+	s.checkpoint = checkpoint
+	return nil
+}
+
+// SetCheckpoint records each input once `Consumer.Consume` returned without error.
+func (c *consumerWorker[K]) SetCheckpoint(checkpoint Checkpoint[K]) error {
+	c.checkpoint = checkpoint
+	return nil
+}
+
+// SetCheckpoint is forwarded to the runner, see `CheckpointConfigurer`.
+func (v *runWorker[K]) SetCheckpoint(checkpoint Checkpoint[K]) error {
+	return SetCheckpoint(v.runner, checkpoint)
+}
+
+func (v *BroadcasterIOWorker[K]) SetCheckpoint(checkpoint Checkpoint[K]) error {
+	return SetCheckpoint(v.IOWorker, checkpoint)
+}
+
+// replay returns the outputs recorded for `input` by a previous run.
+func (v *ioWorker[K]) replay(input K) ([]K, bool) {
+	if v.checkpoint == nil {
+		return nil, false
+	}
+	return v.checkpoint.Outputs(input)
+}
+
+func (v *ioWorker[K]) record(input K, outputs []K) {
+	if v.checkpoint == nil {
+		return
+	}
+	if err := v.checkpoint.Record(input, outputs); err != nil {
+		slog.Warn("failed to checkpoint input, it will be processed again on resume", "error", err)
+	}
+}
@@ -0,0 +1,126 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+)
+
+var ErrConcurrencyNotSupported = errors.New("concurrency not supported")
+
+// ConcurrencyConfigurer is implemented by the IOWorker able to process several inputs in parallel.
+// `ordered` asks the IOWorker to emit the outputs in the order of their inputs.
+// It must be called before `Run`.
+type ConcurrencyConfigurer interface {
+	SetConcurrency(concurrency int, ordered bool) error
+}
+
+// SetConcurrency configures the concurrency of the input when it implements `ConcurrencyConfigurer`.
+// A concurrency of 1 without order is the default of every IOWorker and is always accepted.
+func SetConcurrency(v any, concurrency int, ordered bool) error {
+	if configurer, ok := v.(ConcurrencyConfigurer); ok {
+		return configurer.SetConcurrency(concurrency, ordered)
+	}
+	if concurrency <= 1 && !ordered {
+		return nil
+	}
+	return fmt.Errorf("%w by %T", ErrConcurrencyNotSupported, v)
+}
+
+// SetConcurrency runs `concurrency` calls of `Worker.Work` in parallel on the same input channel.
+// The worker must be safe for concurrent use. With `ordered` the outputs of an input are held
+// until the outputs of all the previous inputs are emitted.
+func (s *syncWorker[K]) SetConcurrency(concurrency int, ordered bool) error {
+	if concurrency < 1 {
+		return fmt.Errorf("invalid concurrency %d: must be at least 1", concurrency)
+	}
+	s.concurrency = concurrency
+	s.ordered = ordered
+	return nil
+}
+
+func (v *runWorker[K]) SetConcurrency(concurrency int, ordered bool) error {
+	return SetConcurrency(v.runner, concurrency, ordered)
+}
+
+func (v *BroadcasterIOWorker[K]) SetConcurrency(concurrency int, ordered bool) error {
+	return SetConcurrency(v.IOWorker, concurrency, ordered)
+}
+
+// runUnordered starts `concurrency` goroutines reading the same input channel and writing directly to the output.
+func (s *syncWorker[K]) runUnordered(ctx SyncContext, workerCtx context.Context, errC chan<- error) {
+	typeWorker := reflect.TypeOf(s.worker).String()
+	wg := sync.WaitGroup{}
+	for range s.concurrency {
+		wg.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case data, ok := <-s.inputC:
+					slog.Debug("Worker Received", "worker", typeWorker)
+					if !ok {
+						return
+					}
+					s.done(data, s.work(workerCtx, data, errC, s.SendOutput))
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+type orderedJob[K any] struct {
+	input   K
+	outputs []K
+	err     error
+	done    chan struct{}
+}
+
+// runOrdered dispatches the inputs to `concurrency` goroutines collecting their outputs per input.
+// The outputs are emitted following the inputs order. At most `concurrency` inputs wait for their turn.
+func (s *syncWorker[K]) runOrdered(ctx SyncContext, workerCtx context.Context, errC chan<- error) {
+	typeWorker := reflect.TypeOf(s.worker).String()
+	jobs := make(chan *orderedJob[K])
+	pending := make(chan *orderedJob[K], s.concurrency)
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case data, ok := <-s.inputC:
+				slog.Debug("Worker Received", "worker", typeWorker)
+				if !ok {
+					return
+				}
+				job := &orderedJob[K]{input: data, done: make(chan struct{})}
+				pending <- job
+				jobs <- job
+			}
+		}
+	}()
+	wg := sync.WaitGroup{}
+	for range s.concurrency {
+		wg.Go(func() {
+			for job := range jobs {
+				job.err = s.work(workerCtx, job.input, errC, func(elem K) {
+					job.outputs = append(job.outputs, elem)
+				})
+				close(job.done)
+			}
+		})
+	}
+	for job := range pending {
+		<-job.done
+		for _, output := range job.outputs {
+			s.SendOutput(output)
+		}
+		s.done(job.input, job.err)
+	}
+	wg.Wait()
+}
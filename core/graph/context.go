@@ -18,12 +18,14 @@ type SyncContext interface {
 
 type WaitGroupContext struct {
 	context.Context
+	cancel   context.CancelCauseFunc
 	wg       sync.WaitGroup
 	barrierC chan struct{}
 }
 
 func NewContext(parent context.Context) SyncContext {
-	return &WaitGroupContext{Context: parent, wg: sync.WaitGroup{}, barrierC: make(chan struct{})}
+	ctx, cancel := context.WithCancelCause(parent)
+	return &WaitGroupContext{Context: ctx, cancel: cancel, wg: sync.WaitGroup{}, barrierC: make(chan struct{})}
 }
 
 func (c *WaitGroupContext) Initializing() {
@@ -39,3 +41,23 @@ func (c *WaitGroupContext) Synchronize() {
 	c.wg.Wait()
 	close(c.barrierC)
 }
+
+type cancelRunKey struct{}
+
+// Value exposes the cancel function of the run to the contexts derived from it. See `CancelRun`.
+func (c *WaitGroupContext) Value(key any) any {
+	if _, ok := key.(cancelRunKey); ok {
+		return c.cancel
+	}
+	return c.Context.Value(key)
+}
+
+// CancelRun cancels the closest run context `ctx` derives from, stopping every worker of the run.
+// It returns false when `ctx` does not derive from a context created by `NewContext`.
+func CancelRun(ctx context.Context, cause error) bool {
+	cancel, ok := ctx.Value(cancelRunKey{}).(context.CancelCauseFunc)
+	if ok {
+		cancel(cause)
+	}
+	return ok
+}
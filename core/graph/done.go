@@ -0,0 +1,50 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrDoneNotSupported = errors.New("input done notification not supported")
+
+// DoneNotifier is implemented by the IOWorker able to tell when they are done with an input, like the checkpointed ones.
+// `fn` is called once per input, once its outputs are emitted or with the error it failed with after the retries.
+// It must be called before `Run`. See `CheckpointConfigurer` for the runners.
+type DoneNotifier[K any] interface {
+	NotifyDone(fn func(input K, err error)) error
+}
+
+// NotifyDone registers `fn` on the input when it implements `DoneNotifier`.
+func NotifyDone[K any](v any, fn func(input K, err error)) error {
+	if notifier, ok := v.(DoneNotifier[K]); ok {
+		return notifier.NotifyDone(fn)
+	}
+	return fmt.Errorf("%w by %T", ErrDoneNotSupported, v)
+}
+
+// NotifyDone calls `fn` once `Worker.Work` returned and the outputs of the input are sent.
+func (s *syncWorker[K]) NotifyDone(fn func(input K, err error)) error {
+	s.onDone = append(s.onDone, fn)
+	return nil
+}
+
+// NotifyDone calls `fn` once `Consumer.Consume` returned.
+func (c *consumerWorker[K]) NotifyDone(fn func(input K, err error)) error {
+	c.onDone = append(c.onDone, fn)
+	return nil
+}
+
+// NotifyDone is forwarded to the runner, see `DoneNotifier`.
+func (v *runWorker[K]) NotifyDone(fn func(input K, err error)) error {
+	return NotifyDone(v.runner, fn)
+}
+
+func (v *BroadcasterIOWorker[K]) NotifyDone(fn func(input K, err error)) error {
+	return NotifyDone(v.IOWorker, fn)
+}
+
+func (v *ioWorker[K]) done(input K, err error) {
+	for _, fn := range v.onDone {
+		fn(input, err)
+	}
+}
@@ -0,0 +1,249 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/dominikbraun/graph"
+)
+
+// ErrorAction decides what a worker does with an input it failed to process, once the retries are exhausted.
+type ErrorAction string
+
+const (
+	// ErrorReport sends the error to the error channel of the worker and drops the input. It is the default.
+	ErrorReport ErrorAction = "report"
+	// ErrorSkip drops the input and only logs the error.
+	ErrorSkip ErrorAction = "skip"
+	// ErrorFail reports the error and cancels the whole run. See `CancelRun`.
+	ErrorFail ErrorAction = "fail"
+	// ErrorDeadLetter sends the failing input and its error to the dead letter vertex.
+	ErrorDeadLetter ErrorAction = "deadLetter"
+)
+
+var (
+	ErrUnknownErrorAction = errors.New("unknown error action")
+	ErrRetryNotSupported  = errors.New("retry not supported")
+	ErrStageFailed        = errors.New("stage failed, run canceled")
+)
+
+func ParseErrorAction(action string) (ErrorAction, error) {
+	switch a := ErrorAction(action); a {
+	case ErrorReport, ErrorSkip, ErrorFail, ErrorDeadLetter:
+		return a, nil
+	case "":
+		return ErrorReport, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownErrorAction, action)
+	}
+}
+
+// RetryPolicy retries a failing input `Retry` times. The delay between two attempts starts at `Backoff`
+// and doubles after each attempt, up to `MaxBackoff` when set.
+// The outputs yielded by a failed attempt are not withdrawn, they may be emitted again by the next attempt.
+type RetryPolicy struct {
+	Retry      int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// backoff returns the delay before the retry following `attempt`. The delay stops doubling before it overflows.
+func (rp RetryPolicy) backoff(attempt int) time.Duration {
+	delay := rp.Backoff
+	for range attempt {
+		if delay > math.MaxInt64/2 {
+			break
+		}
+		delay *= 2
+	}
+	if rp.MaxBackoff > 0 && delay > rp.MaxBackoff {
+		return rp.MaxBackoff
+	}
+	return delay
+}
+
+// Do calls `fn` until it succeeds or the retries are exhausted. It returns the last error.
+func (rp RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	err := fn()
+	for attempt := 0; err != nil && attempt < rp.Retry; attempt++ {
+		delay := rp.backoff(attempt)
+		slog.Warn("worker failed, retrying", "error", err, "attempt", attempt+1, "retry", rp.Retry, "backoff", delay)
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+		err = fn()
+	}
+	return err
+}
+
+// ErrorPolicy configures how a worker handles its errors.
+// `DeadLetter` is the name of the vertex receiving the failing inputs with the `ErrorDeadLetter` action,
+// `DeadLetterItem` builds the item it receives from the failing input and its error.
+type ErrorPolicy[K any] struct {
+	RetryPolicy
+	Action         ErrorAction
+	DeadLetter     string
+	DeadLetterItem func(input K, err error) K
+}
+
+// ItemError is an error raised while processing `Input`.
+// Runners yield it to tell which input failed, it is then available to the dead letter vertex.
+type ItemError[K any] struct {
+	Input K
+	Err   error
+}
+
+func (ie ItemError[K]) Error() string {
+	return ie.Err.Error()
+}
+
+func (ie ItemError[K]) Unwrap() error {
+	return ie.Err
+}
+
+// RetryConfigurer is implemented by the workers able to retry a failing input themselves.
+type RetryConfigurer interface {
+	SetRetry(policy RetryPolicy) error
+}
+
+// SetRetry configures the retries of the input when it implements `RetryConfigurer`.
+// A policy without retry is always accepted.
+func SetRetry(v any, policy RetryPolicy) error {
+	if configurer, ok := v.(RetryConfigurer); ok {
+		return configurer.SetRetry(policy)
+	}
+	if policy.Retry <= 0 {
+		return nil
+	}
+	return fmt.Errorf("%w by %T", ErrRetryNotSupported, v)
+}
+
+// ErrorPolicyConfigurer is implemented by the IOWorker handling their errors according to an `ErrorPolicy`.
+// It must be called before `Run`.
+type ErrorPolicyConfigurer[K any] interface {
+	SetErrorPolicy(policy ErrorPolicy[K]) error
+}
+
+// SetErrorPolicy configures the error policy of the input when it implements `ErrorPolicyConfigurer`.
+// The default policy, reporting every error without retry, is always accepted.
+func SetErrorPolicy[K any](v any, policy ErrorPolicy[K]) error {
+	if configurer, ok := v.(ErrorPolicyConfigurer[K]); ok {
+		return configurer.SetErrorPolicy(policy)
+	}
+	if policy.Retry <= 0 && (policy.Action == "" || policy.Action == ErrorReport) {
+		return nil
+	}
+	return fmt.Errorf("error policy not supported by %T", v)
+}
+
+func (s *syncWorker[K]) SetRetry(policy RetryPolicy) error {
+	s.errorPolicy.RetryPolicy = policy
+	return nil
+}
+
+func (s *syncWorker[K]) SetErrorPolicy(policy ErrorPolicy[K]) error {
+	s.errorPolicy = policy
+	return nil
+}
+
+func (c *consumerWorker[K]) SetRetry(policy RetryPolicy) error {
+	c.errorPolicy.RetryPolicy = policy
+	return nil
+}
+
+func (c *consumerWorker[K]) SetErrorPolicy(policy ErrorPolicy[K]) error {
+	c.errorPolicy = policy
+	return nil
+}
+
+// SetErrorPolicy handles the errors yielded by the runner. The retries are delegated to the runner, see `RetryConfigurer`.
+func (v *runWorker[K]) SetErrorPolicy(policy ErrorPolicy[K]) error {
+	if policy.Retry > 0 {
+		if err := SetRetry(v.runner, policy.RetryPolicy); err != nil {
+			return err
+		}
+	}
+	v.errorPolicy = policy
+	return nil
+}
+
+func (v *BroadcasterIOWorker[K]) SetErrorPolicy(policy ErrorPolicy[K]) error {
+	return SetErrorPolicy(v.IOWorker, policy)
+}
+
+// DeadLetterEmitter is implemented by the IOWorker emitting the inputs they failed to process.
+type DeadLetterEmitter[K any] interface {
+	DeadLetters() <-chan K
+}
+
+// DeadLetters returns the channel of the failing inputs. Without call to `DeadLetters` errors are reported instead.
+func (v *ioWorker[K]) DeadLetters() <-chan K {
+	if v.deadLetterC == nil {
+		v.deadLetterC = make(chan K)
+	}
+	return v.deadLetterC
+}
+
+func (v *BroadcasterIOWorker[K]) DeadLetters() <-chan K {
+	if emitter, ok := v.IOWorker.(DeadLetterEmitter[K]); ok {
+		return emitter.DeadLetters()
+	}
+	return nil
+}
+
+// handleError applies the error policy to the error raised while processing `input`.
+func (v *ioWorker[K]) handleError(ctx context.Context, input K, err error, errC chan<- error) {
+	switch v.errorPolicy.Action {
+	case ErrorSkip:
+		slog.Warn("worker failed, skipping input", "error", err)
+	case ErrorFail:
+		err = fmt.Errorf("%w: %w", ErrStageFailed, err)
+		errC <- err
+		CancelRun(ctx, err)
+	case ErrorDeadLetter:
+		if v.deadLetterC == nil || v.errorPolicy.DeadLetterItem == nil {
+			errC <- ItemError[K]{Input: input, Err: err}
+			return
+		}
+		select {
+		case <-ctx.Done():
+		case v.deadLetterC <- v.errorPolicy.DeadLetterItem(input, err):
+		}
+	default:
+		errC <- ItemError[K]{Input: input, Err: err}
+	}
+}
+
+// DeadLetterDescriber is implemented by the vertices routing their failing inputs to another vertex.
+// An edge carrying the `PortDeadLetter` attribute links them.
+type DeadLetterDescriber[K comparable] interface {
+	GetDeadLetter() K
+}
+
+const (
+	// EdgePortAttribute is the edge attribute naming the output of the source vertex read by the target vertex.
+	EdgePortAttribute = "port"
+	// PortDeadLetter is the output emitting the inputs a vertex failed to process.
+	PortDeadLetter = "deadLetter"
+)
+
+func isDeadLetterEdge[K comparable](edge graph.Edge[K]) bool {
+	return edge.Properties.Attributes[EdgePortAttribute] == PortDeadLetter
+}
+
+// dataEdges filters out the dead letter edges.
+func dataEdges[K comparable](edges map[K]graph.Edge[K]) map[K]graph.Edge[K] {
+	res := make(map[K]graph.Edge[K], len(edges))
+	for hash, edge := range edges {
+		if !isDeadLetterEdge(edge) {
+			res[hash] = edge
+		}
+	}
+	return res
+}
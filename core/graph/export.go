@@ -0,0 +1,333 @@
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Format is the notation a graph is exported in, see `IO.Export`.
+type Format string
+
+const (
+	FormatDOT     Format = "dot"
+	FormatMermaid Format = "mermaid"
+	FormatJSON    Format = "json"
+)
+
+var ErrUnknownFormat = errors.New("unknown graph format")
+
+// ParseFormat returns the format named `format`.
+func ParseFormat(format string) (Format, error) {
+	switch f := Format(strings.ToLower(format)); f {
+	case FormatDOT, FormatMermaid, FormatJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("%w %q, expected dot, mermaid or json", ErrUnknownFormat, format)
+	}
+}
+
+// DescriptionVersion is the version of the JSON form of `GraphDescription`. It changes when a field is removed or
+// changes meaning, new fields are added without changing it.
+const DescriptionVersion = 1
+
+// GraphDescription describes the vertices and the edges of a graph, sorted by name.
+type GraphDescription struct {
+	Vertices []VertexDescription `json:"vertices"`
+	Edges    []EdgeDescription   `json:"edges"`
+}
+
+// VertexDescription describes a vertex. `Config` summarizes the config of its plugin.
+// `Subgraph` is the graph the vertex runs, like an included template.
+type VertexDescription struct {
+	Name       string            `json:"name"`
+	Plugin     string            `json:"plugin,omitempty"`
+	Kind       string            `json:"kind"`
+	PluginPath string            `json:"pluginPath,omitempty"`
+	Config     string            `json:"config,omitempty"`
+	Subgraph   *GraphDescription `json:"subgraph,omitempty"`
+}
+
+// EdgeDescription describes an edge. `Ports` are the ports of the source read by the target when not only the
+// default one. A dead letter edge carries the failing inputs of the source.
+type EdgeDescription struct {
+	Source     string   `json:"source"`
+	Target     string   `json:"target"`
+	Ports      []string `json:"ports,omitempty"`
+	DeadLetter bool     `json:"deadLetter,omitempty"`
+}
+
+// GraphDescriber is implemented by the IOWorker running a graph, so that the vertices decorating them describe it.
+type GraphDescriber interface {
+	Describe() (GraphDescription, error)
+}
+
+// Describe describes the vertex.
+func (dwv IOWorkerVertex[K]) Describe() (VertexDescription, error) {
+	desc := VertexDescription{
+		Name:       dwv.name,
+		Plugin:     dwv.plugin,
+		Kind:       dwv.kind.String(),
+		PluginPath: dwv.pluginPath,
+		Config:     dwv.config,
+	}
+	if dwv.subgraph != nil {
+		subgraph, err := dwv.subgraph.Describe()
+		if err != nil {
+			return VertexDescription{}, fmt.Errorf("describe subgraph of %s: %w", dwv.name, err)
+		}
+		desc.Subgraph = &subgraph
+	}
+	return desc, nil
+}
+
+// Describe describes the vertices of the graph and its edges.
+func (sg *IO[K]) Describe() (GraphDescription, error) {
+	adjacencies, err := sg.AdjacencyMap()
+	if err != nil {
+		return GraphDescription{}, fmt.Errorf("describe graph: %w", err)
+	}
+	desc := GraphDescription{Vertices: []VertexDescription{}, Edges: []EdgeDescription{}}
+	for _, hash := range slices.Sorted(maps.Keys(adjacencies)) {
+		vertex, err := sg.Vertex(hash)
+		if err != nil {
+			return GraphDescription{}, fmt.Errorf("describe graph: %w", err)
+		}
+		vertexDesc, err := vertex.Describe()
+		if err != nil {
+			return GraphDescription{}, err
+		}
+		desc.Vertices = append(desc.Vertices, vertexDesc)
+		for _, target := range slices.Sorted(maps.Keys(adjacencies[hash])) {
+			edge := adjacencies[hash][target]
+			edgeDesc := EdgeDescription{Source: hash, Target: target, DeadLetter: isDeadLetterEdge(edge)}
+			if ports := edgePorts(edge); !edgeDesc.DeadLetter && !slices.Equal(ports, []string{PortDefault}) {
+				edgeDesc.Ports = ports
+			}
+			desc.Edges = append(desc.Edges, edgeDesc)
+		}
+	}
+	return desc, nil
+}
+
+// Export writes the description of the graph in `format`.
+// In DOT and Mermaid, the subgraph of a vertex is drawn as a cluster named after the vertex, the edges of the vertex
+// are drawn to the roots of its subgraph and from its leaves.
+func (sg *IO[K]) Export(w io.Writer, format Format) error {
+	desc, err := sg.Describe()
+	if err != nil {
+		return err
+	}
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			Version int `json:"version"`
+			GraphDescription
+		}{Version: DescriptionVersion, GraphDescription: desc})
+	case FormatDOT:
+		return writeDOT(w, desc)
+	case FormatMermaid:
+		return writeMermaid(w, desc)
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+}
+
+// DrawGraphFormat writes the description of the graph in `format` in the file `path`.
+func (sg *IO[K]) DrawGraphFormat(path string, format Format) error {
+	file, err := os.Create(path) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("failed to draw graph: %w", err)
+	}
+	defer file.Close()
+	if err := sg.Export(file, format); err != nil {
+		return fmt.Errorf("failed to draw graph: %w", err)
+	}
+	return file.Close()
+}
+
+// drawnGraph flattens a description for the notations without nested graphs: the vertices of a subgraph are
+// prefixed with the name of their vertex, `in` and `out` give the vertices drawn for each end of an edge.
+type drawnGraph struct {
+	in, out map[string][]string
+}
+
+func newDrawnGraph(desc GraphDescription, prefix string) drawnGraph {
+	d := drawnGraph{in: map[string][]string{}, out: map[string][]string{}}
+	d.add(desc, prefix)
+	return d
+}
+
+func (d drawnGraph) add(desc GraphDescription, prefix string) {
+	for _, vertex := range desc.Vertices {
+		id := prefix + vertex.Name
+		if vertex.Subgraph == nil {
+			d.in[id], d.out[id] = []string{id}, []string{id}
+			continue
+		}
+		d.add(*vertex.Subgraph, id+"/")
+		for _, root := range vertex.Subgraph.roots() {
+			d.in[id] = append(d.in[id], d.in[id+"/"+root]...)
+		}
+		for _, leaf := range vertex.Subgraph.leaves() {
+			d.out[id] = append(d.out[id], d.out[id+"/"+leaf]...)
+		}
+	}
+}
+
+// roots returns the vertices without parent.
+func (desc GraphDescription) roots() []string {
+	return desc.without(func(edge EdgeDescription) string { return edge.Target })
+}
+
+// leaves returns the vertices whose output is read by no other vertex, dead letter edges are ignored.
+func (desc GraphDescription) leaves() []string {
+	return desc.without(func(edge EdgeDescription) string {
+		if edge.DeadLetter {
+			return ""
+		}
+		return edge.Source
+	})
+}
+
+func (desc GraphDescription) without(end func(edge EdgeDescription) string) []string {
+	linked := map[string]bool{}
+	for _, edge := range desc.Edges {
+		linked[end(edge)] = true
+	}
+	res := []string{}
+	for _, vertex := range desc.Vertices {
+		if !linked[vertex.Name] {
+			res = append(res, vertex.Name)
+		}
+	}
+	return res
+}
+
+// label returns the lines describing a vertex.
+func (vertex VertexDescription) label() []string {
+	lines := []string{vertex.Name}
+	plugin := vertex.Kind
+	if vertex.Plugin != "" {
+		plugin = fmt.Sprintf("%s (%s)", vertex.Plugin, vertex.Kind)
+	}
+	lines = append(lines, plugin)
+	if vertex.PluginPath != "" {
+		lines = append(lines, vertex.PluginPath)
+	}
+	if vertex.Config != "" {
+		lines = append(lines, vertex.Config)
+	}
+	return lines
+}
+
+func (edge EdgeDescription) label() string {
+	if edge.DeadLetter {
+		return "dead letter"
+	}
+	return strings.Join(edge.Ports, ", ")
+}
+
+func writeDOT(w io.Writer, desc GraphDescription) error {
+	b := &strings.Builder{}
+	b.WriteString("digraph lugh {\n  rankdir=LR;\n  node [shape=box];\n")
+	writeDOTVertices(b, desc, "", "  ")
+	writeEdges(desc, newDrawnGraph(desc, ""), "", func(source, target string, edge EdgeDescription) {
+		attributes := []string{}
+		if label := edge.label(); label != "" {
+			attributes = append(attributes, "label="+dotQuote(label))
+		}
+		if edge.DeadLetter {
+			attributes = append(attributes, "style=dashed")
+		}
+		fmt.Fprintf(b, "  %s -> %s", dotQuote(source), dotQuote(target))
+		if len(attributes) > 0 {
+			fmt.Fprintf(b, " [%s]", strings.Join(attributes, ", "))
+		}
+		b.WriteString(";\n")
+	})
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeDOTVertices(b *strings.Builder, desc GraphDescription, prefix, indent string) {
+	for _, vertex := range desc.Vertices {
+		id := prefix + vertex.Name
+		label := dotQuote(strings.Join(vertex.label(), "\n"))
+		if vertex.Subgraph == nil {
+			fmt.Fprintf(b, "%s%s [label=%s];\n", indent, dotQuote(id), label)
+			continue
+		}
+		fmt.Fprintf(b, "%ssubgraph %s {\n%s  label=%s;\n", indent, dotQuote("cluster_"+id), indent, label)
+		writeDOTVertices(b, *vertex.Subgraph, id+"/", indent+"  ")
+		fmt.Fprintf(b, "%s}\n", indent)
+	}
+}
+
+// writeEdges calls `draw` for each drawn edge of `desc` and of its subgraphs.
+func writeEdges(desc GraphDescription, drawn drawnGraph, prefix string, draw func(source, target string, edge EdgeDescription)) {
+	for _, edge := range desc.Edges {
+		for _, source := range drawn.out[prefix+edge.Source] {
+			for _, target := range drawn.in[prefix+edge.Target] {
+				draw(source, target, edge)
+			}
+		}
+	}
+	for _, vertex := range desc.Vertices {
+		if vertex.Subgraph != nil {
+			writeEdges(*vertex.Subgraph, drawn, prefix+vertex.Name+"/", draw)
+		}
+	}
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func writeMermaid(w io.Writer, desc GraphDescription) error {
+	b := &strings.Builder{}
+	b.WriteString("flowchart LR\n")
+	ids := map[string]string{}
+	writeMermaidVertices(b, desc, "", "  ", ids)
+	writeEdges(desc, newDrawnGraph(desc, ""), "", func(source, target string, edge EdgeDescription) {
+		arrow := "-->"
+		if edge.DeadLetter {
+			arrow = "-.->"
+		}
+		if label := edge.label(); label != "" {
+			arrow += "|" + mermaidQuote(label) + "|"
+		}
+		fmt.Fprintf(b, "  %s %s %s\n", ids[source], arrow, ids[target])
+	})
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMermaidVertices declares the vertices, their ids are numbered since Mermaid ids can't hold any character.
+func writeMermaidVertices(b *strings.Builder, desc GraphDescription, prefix, indent string, ids map[string]string) {
+	for _, vertex := range desc.Vertices {
+		name := prefix + vertex.Name
+		id := fmt.Sprintf("v%d", len(ids))
+		ids[name] = id
+		label := mermaidQuote(strings.Join(vertex.label(), "<br/>"))
+		if vertex.Subgraph == nil {
+			fmt.Fprintf(b, "%s%s[%s]\n", indent, id, label)
+			continue
+		}
+		fmt.Fprintf(b, "%ssubgraph %s[%s]\n", indent, id, label)
+		writeMermaidVertices(b, *vertex.Subgraph, name+"/", indent+"  ", ids)
+		fmt.Fprintf(b, "%send\n", indent)
+	}
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
@@ -0,0 +1,173 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// FanoutMode decides which children of a vertex receive an item it emitted.
+type FanoutMode string
+
+const (
+	// FanoutBroadcast sends every item to every child.
+	FanoutBroadcast FanoutMode = "broadcast"
+	// FanoutRoundRobin sends each item to the next child in turn.
+	FanoutRoundRobin FanoutMode = "roundRobin"
+	// FanoutHash sends the items with the same key to the same child. See `FanoutConfig.Key`.
+	FanoutHash FanoutMode = "hash"
+	// FanoutFirstAvailable sends each item to the first child ready to read it, or with the shortest buffer.
+	FanoutFirstAvailable FanoutMode = "firstAvailable"
+)
+
+var ErrUnknownFanoutMode = errors.New("unknown fanout mode")
+
+func ParseFanoutMode(mode string) (FanoutMode, error) {
+	switch m := FanoutMode(mode); m {
+	case FanoutBroadcast, FanoutRoundRobin, FanoutHash, FanoutFirstAvailable:
+		return m, nil
+	case "first-available":
+		return FanoutFirstAvailable, nil
+	case "":
+		return FanoutBroadcast, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownFanoutMode, mode)
+	}
+}
+
+// FanoutConfig configures how the items emitted by a `BroadcasterIOWorker` are shared between its subscribers.
+// `Key` extracts the partition key of an item for the `hash` mode.
+// A zero config broadcasts the items.
+type FanoutConfig[K any] struct {
+	Mode FanoutMode
+	Key  func(elem K) string
+}
+
+func (fc FanoutConfig[K]) distributes() bool {
+	return fc.Mode != "" && fc.Mode != FanoutBroadcast
+}
+
+// distributor sends each item received on src to a single subscriber, chosen by the fanout mode.
+// The subscribers read from an unbuffered chan, or from a bounded queue when a buffer is configured.
+type distributor[K any] struct {
+	src         <-chan K
+	config      FanoutConfig[K]
+	buffer      BufferConfig[K]
+	outputs     []chan K
+	subscribers []*subscriber[K]
+	next        int
+	isSrcClosed bool
+	mux         sync.Mutex
+}
+
+func newDistributor[K any](src <-chan K, config FanoutConfig[K], buffer BufferConfig[K]) *distributor[K] {
+	if config.Mode == FanoutHash && config.Key == nil {
+		config.Key = func(elem K) string {
+			return fmt.Sprint(elem)
+		}
+	}
+	if buffer.bounded() && buffer.Codec == nil {
+		if codec, ok := any(BytesCodec{}).(Codec[K]); ok {
+			buffer.Codec = codec
+		}
+	}
+	d := &distributor[K]{src: src, config: config, buffer: buffer}
+	go d.run()
+	return d
+}
+
+func (d *distributor[K]) run() {
+	for elem := range d.src {
+		d.mux.Lock()
+		d.dispatch(elem)
+		d.mux.Unlock()
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.isSrcClosed = true
+	for _, output := range d.outputs {
+		close(output)
+	}
+	for _, s := range d.subscribers {
+		s.close()
+	}
+}
+
+// dispatch sends `elem` to the chosen subscriber. Items emitted while nobody subscribed are lost, as when broadcast.
+func (d *distributor[K]) dispatch(elem K) {
+	count := max(len(d.outputs), len(d.subscribers))
+	if count == 0 {
+		return
+	}
+	switch d.config.Mode {
+	case FanoutHash:
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(d.config.Key(elem)))
+		d.send(int(hash.Sum32()%uint32(count)), elem) //nolint:gosec // count is a number of children
+	case FanoutFirstAvailable:
+		d.sendFirstAvailable(elem)
+	default:
+		d.send(d.next%count, elem)
+		d.next++
+	}
+}
+
+func (d *distributor[K]) send(index int, elem K) {
+	if d.buffer.bounded() {
+		d.subscribers[index].push(elem)
+		return
+	}
+	d.outputs[index] <- elem
+}
+
+func (d *distributor[K]) sendFirstAvailable(elem K) {
+	if d.buffer.bounded() {
+		shortest := 0
+		for index, s := range d.subscribers {
+			if s.len() < d.subscribers[shortest].len() {
+				shortest = index
+			}
+		}
+		d.subscribers[shortest].push(elem)
+		return
+	}
+	cases := make([]reflect.SelectCase, len(d.outputs))
+	for index, output := range d.outputs {
+		cases[index] = reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(output), Send: reflect.ValueOf(elem)}
+	}
+	reflect.Select(cases)
+}
+
+func (d *distributor[K]) Subscribe() <-chan K {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.buffer.bounded() {
+		s := newSubscriber(d.buffer.sub(strconv.Itoa(len(d.subscribers))))
+		if d.isSrcClosed {
+			s.close()
+		}
+		d.subscribers = append(d.subscribers, s)
+		go s.drain()
+		return s.outputC
+	}
+	output := make(chan K)
+	if d.isSrcClosed {
+		close(output)
+		return output
+	}
+	d.outputs = append(d.outputs, output)
+	return output
+}
+
+func (d *distributor[K]) Stats() BufferStats {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	res := BufferStats{}
+	for _, s := range d.subscribers {
+		res = res.Add(s.stats())
+	}
+	return res
+}
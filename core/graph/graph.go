@@ -220,8 +220,24 @@ func (eae ErrAddEdge[K]) Unwrap() error {
 	return eae.GraphErr
 }
 
+type ErrAddVertex[K comparable] struct {
+	GraphErr error
+	Vertex   K
+}
+
+func (eav ErrAddVertex[K]) Error() string {
+	return fmt.Sprintf("failed to add vertex %v: %s", eav.Vertex, eav.GraphErr.Error())
+}
+
+func (eav ErrAddVertex[K]) Unwrap() error {
+	return eav.GraphErr
+}
+
 type GraphSelfDescribe[K comparable, T VertexSelfDescribe[K]] struct {
 	Graph[K, T]
+	// duplicates keeps track of the vertices rejected because their name was already taken.
+	// It is reported by `Validate`.
+	duplicates []K
 }
 
 func NewSelfDescribed[K comparable, T VertexSelfDescribe[K]](
@@ -237,11 +253,13 @@ func (g *GraphSelfDescribe[K, T]) AddSelfDescribeVertex(newVertex T) error {
 	err := g.AddVertex(newVertex)
 	if err != nil {
 		slog.Error("Error adding vertex", "vertex", newVertex.GetName(), "error", err)
-
+		if errors.Is(err, graph.ErrVertexAlreadyExists) {
+			g.duplicates = append(g.duplicates, newVertex.GetName())
+		}
 		return err
 	}
 	for _, p := range newVertex.GetParents() {
-		err := g.AddEdge(p, newVertex.GetName())
+		err := g.AddEdge(p, newVertex.GetName(), edgePortAttribute(newVertex, p))
 		if err != nil {
 			slog.Error("Error adding edge", "from", p, "to", newVertex.GetName(), "error", err)
 			return err
@@ -250,18 +268,28 @@ func (g *GraphSelfDescribe[K, T]) AddSelfDescribeVertex(newVertex T) error {
 	return nil
 }
 
+// AddVertices inserts all the vertices then links them to their parents.
+// Duplicated vertices and edges to unknown parents do not stop the insertion, they are joined in the returned error
+// and reported by `Validate`.
 func (g *GraphSelfDescribe[K, T]) AddVertices(vertices []T) error {
+	var errs error
+	added := make([]T, 0, len(vertices))
 	for _, newVertex := range vertices {
 		err := g.AddVertex(newVertex)
-		if err != nil {
+		if errors.Is(err, graph.ErrVertexAlreadyExists) {
+			slog.Error("Error adding vertex", "vertex", newVertex.GetName(), "error", err)
+			g.duplicates = append(g.duplicates, newVertex.GetName())
+			errs = errors.Join(errs, ErrAddVertex[K]{GraphErr: err, Vertex: newVertex.GetName()})
+			continue
+		} else if err != nil {
 			slog.Error("Error adding vertex", "vertex", newVertex.GetName(), "error", err)
 			return err
 		}
+		added = append(added, newVertex)
 	}
-	var errs error
-	for _, newVertex := range vertices {
+	for _, newVertex := range added {
 		for _, p := range newVertex.GetParents() {
-			err := g.AddEdge(p, newVertex.GetName())
+			err := g.AddEdge(p, newVertex.GetName(), edgePortAttribute(newVertex, p))
 			if errors.Is(err, graph.ErrVertexNotFound) {
 				errs = errors.Join(errs, ErrAddEdge[K]{GraphErr: err, Edge: graph.Edge[K]{Source: p, Target: newVertex.GetName()}})
 			} else if err != nil {
@@ -269,9 +297,31 @@ func (g *GraphSelfDescribe[K, T]) AddVertices(vertices []T) error {
 			}
 		}
 	}
+	for _, newVertex := range added {
+		deadLetter, ok := deadLetterOf[K](newVertex)
+		if !ok {
+			continue
+		}
+		err := g.AddEdge(newVertex.GetName(), deadLetter, graph.EdgeAttribute(EdgePortAttribute, PortDeadLetter))
+		if errors.Is(err, graph.ErrVertexNotFound) || errors.Is(err, graph.ErrEdgeAlreadyExists) {
+			errs = errors.Join(errs, ErrAddEdge[K]{GraphErr: err, Edge: graph.Edge[K]{Source: newVertex.GetName(), Target: deadLetter}})
+		} else if err != nil {
+			return fmt.Errorf("failed to add dead letter edge: %w", err)
+		}
+	}
 	return errs
 }
 
+// deadLetterOf returns the dead letter vertex of `vertex` if it has one.
+func deadLetterOf[K comparable](vertex any) (K, bool) {
+	var zero K
+	describer, ok := vertex.(DeadLetterDescriber[K])
+	if !ok || describer.GetDeadLetter() == zero {
+		return zero, false
+	}
+	return describer.GetDeadLetter(), true
+}
+
 func (g *GraphSelfDescribe[K, T]) CloneFromEdge(edge ...graph.Edge[K]) (*GraphSelfDescribe[K, T], error) {
 	mewG, err := g.Graph.CloneFromEdge(edge...)
 	if err != nil {
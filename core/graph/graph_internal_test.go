@@ -3,7 +3,11 @@ package graph
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 )
 
 func ForwardWorker[K any]() IOWorker[K] {
@@ -67,3 +71,150 @@ func TestInitialize(t *testing.T) {
 		}
 	})
 }
+
+func TestDiskQueue(t *testing.T) {
+	dir := t.TempDir()
+	// Each record is 8 bytes of header and 1 byte of payload, a segment holds 2 of them.
+	q, err := openDiskQueue[[]byte](dir, 20, BytesCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, elem := range "abcdef" {
+		if err := q.Write([]byte(string(elem))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(q.segments) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(q.segments))
+	}
+	for _, expected := range "abc" {
+		elem, err := q.Read()
+		if err != nil || string(elem) != string(expected) {
+			t.Fatalf("expected %c, got %q: %v", expected, elem, err)
+		}
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if slices.ContainsFunc(entries, func(entry os.DirEntry) bool { return entry.Name() == segment{id: 0}.name() }) {
+		t.Errorf("the fully read segment should be removed")
+	}
+	// A crash tore the last record written.
+	last, err := os.OpenFile(filepath.Join(dir, segment{id: 2}.name()), os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := last.Write([]byte{0, 0, 0, 4, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	last.Close()
+
+	q, err = openDiskQueue[[]byte](dir, 20, BytesCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Remove()
+	if q.Len() != 3 {
+		t.Fatalf("expected 3 unread items after reopening, got %d", q.Len())
+	}
+	if err := q.Write([]byte("g")); err != nil {
+		t.Fatal(err)
+	}
+	received := ""
+	for q.Len() > 0 {
+		elem, err := q.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		received += string(elem)
+	}
+	if received != "defg" {
+		t.Errorf("expected the queue to resume at the committed offset, got %q", received)
+	}
+}
+
+func TestDiskQueueUnreadableSegment(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openDiskQueue[[]byte](dir, 20, BytesCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Remove()
+	for _, elem := range "abcde" {
+		if err := q.Write([]byte(string(elem))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elem, err := q.Read(); err != nil || string(elem) != "a" {
+		t.Fatalf("expected a, got %q: %v", elem, err)
+	}
+	if elem, err := q.Read(); err != nil || string(elem) != "b" {
+		t.Fatalf("expected b, got %q: %v", elem, err)
+	}
+	if err := os.Remove(filepath.Join(dir, segment{id: 1}.name())); err != nil {
+		t.Fatal(err)
+	}
+	// The items of the missing segment are lost, the next reads go on with the following segment.
+	if _, err := q.Read(); err == nil || q.Len() != 1 {
+		t.Fatalf("expected the missing segment to be dropped, got %d items left: %v", q.Len(), err)
+	}
+	if elem, err := q.Read(); err != nil || string(elem) != "e" || q.Len() != 0 {
+		t.Errorf("expected e, got %q with %d items left: %v", elem, q.Len(), err)
+	}
+}
+
+func TestPersistentSubscriber(t *testing.T) {
+	root := t.TempDir()
+	config := BufferConfig[[]byte]{Size: 1, Policy: BufferDisk, SpillDir: root, QueueDir: filepath.Join(root, "run", "stage"), Codec: BytesCodec{}}
+	crashed := newSubscriber(config.sub("0"))
+	for _, elem := range "abc" {
+		crashed.push([]byte(string(elem)))
+	}
+	go crashed.drain()
+	if elem := <-crashed.outputC; string(elem) != "a" {
+		t.Fatalf("expected a, got %q", elem)
+	}
+	// The delivered item is removed from the disk once delivered, b is read but not delivered when the run crashes.
+	for crashed.len() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	crashed.mux.Lock()
+	if err := crashed.spill.Close(); err != nil {
+		t.Fatal(err)
+	}
+	crashed.mux.Unlock()
+
+	resumed := newSubscriber(config.sub("0"))
+	resumed.push([]byte("d"))
+	resumed.close()
+	go resumed.drain()
+	delivered := ""
+	for elem := range resumed.outputC {
+		delivered += string(elem)
+	}
+	if delivered != "bcd" {
+		t.Errorf("expected the items left by the crashed run to be delivered first, got %q", delivered)
+	}
+	if entries, err := os.ReadDir(root); err != nil || len(entries) != 0 {
+		t.Errorf("expected the queue directories to be removed, got %v: %v", entries, err)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		policy   RetryPolicy
+		attempt  int
+		expected time.Duration
+	}{
+		{policy: RetryPolicy{Backoff: time.Second}, attempt: 3, expected: 8 * time.Second},
+		{policy: RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}, attempt: 3, expected: 5 * time.Second},
+		{policy: RetryPolicy{Backoff: time.Second}, attempt: 100, expected: time.Second << 33},
+		{policy: RetryPolicy{Backoff: 3}, attempt: 70, expected: 3 << 61},
+	}
+	for _, test := range tests {
+		if delay := test.policy.backoff(test.attempt); delay != test.expected {
+			t.Errorf("%+v attempt %d: expected %s, got %s", test.policy, test.attempt, test.expected, delay)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package graph_test
 
 import (
+	"errors"
 	"slices"
 	"testing"
 
+	"github.com/benji-bou/lugh/core/graph"
 	"github.com/benji-bou/lugh/core/graph/graphtest"
 	gr "github.com/dominikbraun/graph"
 	"github.com/google/go-cmp/cmp"
@@ -94,3 +96,98 @@ func TestSplit(t *testing.T) {
 		})
 	}
 }
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		vertices []graphtest.TestVertex
+		expected []error
+	}{
+		{
+			name: "valid graph",
+			vertices: []graphtest.TestVertex{
+				{Name: "a", Parents: []string{}},
+				{Name: "b", Parents: []string{"a"}},
+				{Name: "c", Parents: []string{"a", "b"}},
+			},
+		},
+		{
+			name: "unknown parent",
+			vertices: []graphtest.TestVertex{
+				{Name: "a", Parents: []string{}},
+				{Name: "b", Parents: []string{"missing"}},
+			},
+			expected: []error{graph.ErrUnknownParent},
+		},
+		{
+			name: "self loop",
+			vertices: []graphtest.TestVertex{
+				{Name: "a", Parents: []string{}},
+				{Name: "b", Parents: []string{"a", "b"}},
+			},
+			expected: []error{graph.ErrSelfLoop},
+		},
+		{
+			name: "duplicate vertex",
+			vertices: []graphtest.TestVertex{
+				{Name: "a", Parents: []string{}},
+				{Name: "a", Parents: []string{}},
+			},
+			expected: []error{graph.ErrDuplicateVertex},
+		},
+		{
+			name: "cycle with entry point",
+			vertices: []graphtest.TestVertex{
+				{Name: "a", Parents: []string{}},
+				{Name: "b", Parents: []string{"a", "c"}},
+				{Name: "c", Parents: []string{"b"}},
+			},
+			expected: []error{graph.ErrCycle},
+		},
+		{
+			name: "orphan cycle",
+			vertices: []graphtest.TestVertex{
+				{Name: "a", Parents: []string{}},
+				{Name: "b", Parents: []string{"c"}},
+				{Name: "c", Parents: []string{"b"}},
+			},
+			expected: []error{graph.ErrCycle, graph.ErrOrphanVertex},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := graphtest.NewGraphTest()
+			_ = g.AddVertices(tc.vertices)
+			err := g.Validate()
+			if len(tc.expected) == 0 && err != nil {
+				t.Errorf("Validate returned an unexpected error: %v", err)
+			}
+			for _, expected := range tc.expected {
+				if !errors.Is(err, expected) {
+					t.Errorf("Validate error should contain %v, got %v", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateCyclePath(t *testing.T) {
+	g := graphtest.NewGraphTest()
+	_ = g.AddVertices([]graphtest.TestVertex{
+		{Name: "root", Parents: []string{}},
+		{Name: "a", Parents: []string{"root", "c"}},
+		{Name: "b", Parents: []string{"a"}},
+		{Name: "c", Parents: []string{"b"}},
+	})
+	var validationErr graph.ValidationError[string]
+	if !errors.As(g.Validate(), &validationErr) {
+		t.Fatalf("Validate should return a ValidationError")
+	}
+	cycle := validationErr.Cycle
+	if len(cycle) != 4 || cycle[0] != cycle[len(cycle)-1] {
+		t.Fatalf("cycle path should loop on its first vertex, got %v", cycle)
+	}
+	if got := slices.Sorted(slices.Values(cycle[:3])); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("cycle path should contain a, b and c, got %v", cycle)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"log/slog"
 	"maps"
 	"slices"
+	"sync"
+	"time"
 
 	"github.com/benji-bou/diwo"
 	"github.com/benji-bou/lugh/helper"
@@ -19,6 +21,12 @@ type IO[K any] struct {
 	innerCancelFn context.CancelFunc
 	inputC        *diwo.Broker[K]
 	outputC       <-chan K
+	started       time.Time
+	runs          map[string]*stageRun
+	runsMux       sync.RWMutex
+	middleware    []Middleware[K]
+	useOnce       sync.Once
+	useErr        error
 }
 
 func WithVertices[K any](it []IOWorkerVertex[K]) IOGraphOption[K] {
@@ -30,11 +38,18 @@ func WithVertices[K any](it []IOWorkerVertex[K]) IOGraphOption[K] {
 	}
 }
 
+// WithMiddleware decorates the IOWorker of every vertex with `middleware` when the graph runs. See `Middleware`.
+func WithMiddleware[K any](middleware ...Middleware[K]) IOGraphOption[K] {
+	return func(configure *IO[K]) {
+		configure.middleware = append(configure.middleware, middleware...)
+	}
+}
+
 type IOGraphOption[K any] func(*IO[K])
 
 func NewIO[K any](opt ...IOGraphOption[K]) *IO[K] {
 	sg := &IO[K]{}
-	sg.GraphSelfDescribe = GraphSelfDescribe[string, IOWorkerVertex[K]]{New(func(spp IOWorkerVertex[K]) string {
+	sg.GraphSelfDescribe = GraphSelfDescribe[string, IOWorkerVertex[K]]{Graph: New(func(spp IOWorkerVertex[K]) string {
 		return spp.GetName()
 	}, graph.Directed())}
 	for _, o := range opt {
@@ -54,43 +69,38 @@ func (*IO[K]) MergeVertexOutput(vertices iter.Seq[IOWorker[K]]) <-chan K {
 
 func (sg *IO[K]) piping() error {
 	slog.Debug("piping: graph")
-	parentsMap, err := sg.PredecessorVertices()
+	parentsMap, err := sg.PredecessorMap()
 	if err != nil {
 		return fmt.Errorf("error while piping graph: %w", err)
 	}
-	for currentVertexHash, parentVertices := range parentsMap {
+	for currentVertexHash, parentEdges := range parentsMap {
 		slog.Debug("piping: vertex", "vertex", currentVertexHash)
 		currentVertex, err := sg.Vertex(currentVertexHash)
 		if err != nil {
 			slog.Error("Error while piping graph: ", "error", err, "vertexHash", currentVertexHash)
 			return fmt.Errorf("error while piping graph: %w", err)
 		}
-		if len(parentVertices) == 0 && sg.inputC != nil {
+		if len(parentEdges) == 0 && sg.inputC != nil {
 			slog.Debug("piping: vertex set input", "vertex", currentVertexHash, "input", "inputC")
 			currentVertex.SetInput(sg.inputC.Subscribe())
-
-		} else {
-			parentsMapValuesIterator := maps.Values(parentVertices)
-
-			slog.Debug("piping: vertex set input",
-				"vertex", currentVertexHash,
-				"parentsOutput", slices.Collect(
-					helper.IterMap(parentsMapValuesIterator,
-						func(elem IOWorkerVertex[K]) string {
-							return elem.GetName()
-						},
-					),
-				))
-			currentVertex.SetInput(
-				sg.MergeVertexOutput(
-					helper.IterMap(parentsMapValuesIterator,
-						func(elem IOWorkerVertex[K]) IOWorker[K] {
-							return elem
-						},
-					),
-				),
-			)
+			continue
+		}
+		slog.Debug("piping: vertex set input", "vertex", currentVertexHash, "parentsOutput", slices.Sorted(maps.Keys(parentEdges)))
+		inputs := make([]<-chan K, 0, len(parentEdges))
+		for parentHash, edge := range parentEdges {
+			parent, err := sg.Vertex(parentHash)
+			if err != nil {
+				return fmt.Errorf("error while piping graph: %w", err)
+			}
+			if isDeadLetterEdge(edge) {
+				inputs = append(inputs, parent.DeadLetters())
+				continue
+			}
+			for _, port := range edgePorts(edge) {
+				inputs = append(inputs, parent.OutputPort(port))
+			}
 		}
+		currentVertex.SetInput(diwo.Merge(inputs...))
 	}
 	slog.Debug("piping: End of piping graph")
 	return nil
@@ -104,6 +114,10 @@ func (sg *IO[K]) start(ctx SyncContext) <-chan error {
 		return diwo.Once(errors.New("empty graph. No stage loaded"))
 	}
 	errorsOutputC := make([]<-chan error, 0, len(vertexMap))
+	sg.runsMux.Lock()
+	sg.started = time.Now()
+	sg.runs = make(map[string]*stageRun, len(vertexMap))
+	sg.runsMux.Unlock()
 	for vertexHash := range vertexMap {
 		vertex, err := sg.Vertex(vertexHash)
 		if err != nil {
@@ -111,7 +125,7 @@ func (sg *IO[K]) start(ctx SyncContext) <-chan error {
 			continue
 		}
 		slog.Debug("start: Starting run vertex", "vertex", vertexHash)
-		errorsOutputC = append(errorsOutputC, vertex.Run(ctx))
+		errorsOutputC = append(errorsOutputC, sg.track(ctx, vertexHash, vertex.Run(ctx)))
 	}
 	slog.Debug("start: Wait for  worker synchronization")
 
@@ -119,8 +133,63 @@ func (sg *IO[K]) start(ctx SyncContext) <-chan error {
 	return diwo.Merge(errorsOutputC...)
 }
 
+// track records the run of the vertex `name`: its errors and when its error channel is closed, meaning it exited.
+func (sg *IO[K]) track(ctx context.Context, name string, errC <-chan error) <-chan error {
+	run := &stageRun{started: time.Now()}
+	sg.runsMux.Lock()
+	sg.runs[name] = run
+	sg.runsMux.Unlock()
+	return diwo.New(func(c chan<- error) {
+		for err := range errC {
+			run.errors.Add(1)
+			c <- err
+		}
+		run.end(ctx.Err() != nil)
+		slog.Debug("vertex exited", "vertex", name, "errors", run.errors.Load())
+	})
+}
+
+// Result summarizes the run of the graph. It is final once the error channel returned by `Run` is closed:
+// every vertex exited after the end of its input propagated through every edge, or after the run was canceled.
+func (sg *IO[K]) Result() RunResult {
+	sg.runsMux.RLock()
+	defer sg.runsMux.RUnlock()
+	stages := make([]StageResult, 0, len(sg.runs))
+	for name, run := range sg.runs {
+		vertex, err := sg.Vertex(name)
+		if err != nil {
+			slog.Warn("run result failed to get vertex", "vertex", name, "error", err)
+			continue
+		}
+		status, duration := run.status()
+		stages = append(stages, StageResult{
+			Name:     name,
+			Plugin:   vertex.GetPlugin(),
+			Kind:     vertex.Kind(),
+			Items:    vertex.ItemStats(),
+			Errors:   run.errors.Load(),
+			Buffer:   vertex.BufferStats(),
+			Duration: duration,
+			Status:   status,
+		})
+	}
+	sortStages(stages)
+	res := RunResult{Status: runStatus(stages), Stages: stages}
+	if res.Status == StatusRunning {
+		res.Duration = time.Since(sg.started)
+		return res
+	}
+	for _, run := range sg.runs {
+		res.Duration = max(res.Duration, run.endedAt().Sub(sg.started))
+	}
+	return res
+}
+
 func (sg *IO[K]) Run(ctx SyncContext) <-chan error {
 	slog.Debug("run: Io Graph")
+	if err := sg.use(); err != nil {
+		return diwo.Once(err)
+	}
 	err := sg.piping()
 	if err != nil {
 		return diwo.Once(err)
@@ -128,6 +197,24 @@ func (sg *IO[K]) Run(ctx SyncContext) <-chan error {
 	return sg.start(ctx)
 }
 
+// use applies the middleware on every vertex, once, before the first call to `Output` or `Run`.
+func (sg *IO[K]) use() error {
+	sg.useOnce.Do(func() {
+		if len(sg.middleware) == 0 {
+			return
+		}
+		vertices, err := sg.Vertices()
+		if err != nil {
+			sg.useErr = fmt.Errorf("error while applying middleware: %w", err)
+			return
+		}
+		for _, vertex := range vertices {
+			vertex.Use(sg.middleware...)
+		}
+	})
+	return sg.useErr
+}
+
 // IOWorker Implementation
 func (sg *IO[K]) SetInput(inputC <-chan K) {
 	slog.Debug("IOGraphWorker start SetInput")
@@ -139,20 +226,45 @@ func (sg *IO[K]) SetInput(inputC <-chan K) {
 }
 
 func (sg *IO[K]) Output() <-chan K {
+	if err := sg.use(); err != nil {
+		slog.Error("output of the graph not instrumented", "error", err)
+	}
 	if sg.outputC == nil {
-		iterChildless := slices.Collect(
+		sg.outputC = diwo.Merge(slices.Collect(
 			helper.IterMap(
-				sg.IterChildlessVertex(),
+				sg.iterOutputlessVertex(),
 				func(vertexHash IOWorkerVertex[K]) <-chan K {
 					return vertexHash.Output()
 				},
 			),
-		)
-		sg.outputC = diwo.Merge(iterChildless...)
+		)...)
 	}
 	return sg.outputC
 }
 
+// iterOutputlessVertex yields the vertices whose output is read by no other vertex. Dead letter edges are ignored.
+func (sg *IO[K]) iterOutputlessVertex() iter.Seq[IOWorkerVertex[K]] {
+	return sg.iterOrientedNeighborlessVertex(func() (map[string]map[string]graph.Edge[string], error) {
+		adjacencies, err := sg.AdjacencyMap()
+		if err != nil {
+			return nil, err
+		}
+		for hash, children := range adjacencies {
+			adjacencies[hash] = dataEdges(children)
+		}
+		return adjacencies, nil
+	})
+}
+
+func (*IO[K]) Kind() Kind {
+	return KindGraph
+}
+
+// Validate runs the structural validation of the graph then checks the wiring of each vertex against its kind.
+func (sg *IO[K]) Validate() error {
+	return errors.Join(sg.GraphSelfDescribe.Validate(), sg.ValidateKinds())
+}
+
 func (sg *IO[K]) CloneFromEdge(edge ...graph.Edge[string]) (*IO[K], error) {
 	mewG, err := sg.GraphSelfDescribe.CloneFromEdge(edge...)
 	if err != nil {
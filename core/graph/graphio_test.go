@@ -2,9 +2,15 @@ package graph_test
 
 import (
 	"context"
+	"errors"
 	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/benji-bou/diwo"
 	"github.com/benji-bou/lugh/core/graph"
 	"github.com/benji-bou/lugh/core/graph/graphtest"
 	"github.com/benji-bou/lugh/helper"
@@ -66,3 +72,280 @@ func TestGraphAsWorker(t *testing.T) {
 		graphtest.TestWorkerChain(t, ttc)
 	}
 }
+
+func TestValidateKinds(t *testing.T) {
+	consumer := func() graph.IOWorker[int] {
+		return graph.NewIOWorkerFromConsumer(graph.ConsumerFunc[int](func(_ context.Context, _ int) error { return nil }))
+	}
+	testCases := []struct {
+		name     string
+		vertices []graph.IOWorkerVertex[int]
+		expected error
+	}{
+		{
+			name:     "worker chain",
+			vertices: graphtest.LinearWorkerChainMult2,
+		},
+		{
+			name: "consumer leaf",
+			vertices: []graph.IOWorkerVertex[int]{
+				graph.NewIOWorkerVertex("forward", []string{}, graphtest.ForwardWorker[int]()),
+				graph.NewIOWorkerVertex("consumer", []string{"forward"}, consumer()),
+			},
+		},
+		{
+			name: "consumer with children",
+			vertices: []graph.IOWorkerVertex[int]{
+				graph.NewIOWorkerVertex("consumer", []string{}, consumer(), graph.WithPluginName[int]("sink")),
+				graph.NewIOWorkerVertex("forward", []string{"consumer"}, graphtest.ForwardWorker[int]()),
+			},
+			expected: graph.ErrConsumerWithChildren,
+		},
+		{
+			name: "producer with parents",
+			vertices: []graph.IOWorkerVertex[int]{
+				graph.NewIOWorkerVertex("forward", []string{}, graphtest.ForwardWorker[int]()),
+				graph.NewIOWorkerVertex("producer", []string{"forward"}, graphtest.SliceProducer(1, 2)),
+			},
+			expected: graph.ErrProducerWithParents,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := graph.NewIO(graph.WithVertices(tc.vertices))
+			err := g.ValidateKinds()
+			if tc.expected == nil && err != nil {
+				t.Errorf("ValidateKinds returned an unexpected error: %v", err)
+			}
+			if tc.expected != nil && !errors.Is(err, tc.expected) {
+				t.Errorf("ValidateKinds error should be %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestErrorPolicy(t *testing.T) {
+	input := []int{1, 2, 3, 4, 5, 6}
+	// failingEven fails the even inputs `failures` times. The inputs consumed by the consumer are added to `consumed`.
+	failingEven := func(kind graph.Kind, failures int, consumed *[]int) graph.IOWorker[int] {
+		attempts := sync.Map{}
+		mux := sync.Mutex{}
+		fail := func(input int) error {
+			attempt, _ := attempts.LoadOrStore(input, new(atomic.Int32))
+			if input%2 == 0 && int(attempt.(*atomic.Int32).Add(1)) <= failures {
+				return errors.New("even input")
+			}
+			return nil
+		}
+		if kind == graph.KindConsumer {
+			return graph.NewIOWorkerFromConsumer(graph.ConsumerFunc[int](func(_ context.Context, input int) error {
+				if err := fail(input); err != nil {
+					return err
+				}
+				mux.Lock()
+				defer mux.Unlock()
+				*consumed = append(*consumed, input)
+				return nil
+			}))
+		}
+		return graph.NewIOWorkerFromWorker(graph.WorkerFunc[int](func(_ context.Context, input int, yield func(elem int) error) error {
+			if err := fail(input); err != nil {
+				return err
+			}
+			return yield(input)
+		}))
+	}
+	testCases := []struct {
+		name     string
+		failures int
+		policy   graph.ErrorPolicy[int]
+		output   []int
+		errors   int
+		expected error
+	}{
+		{name: "report", failures: 1, policy: graph.ErrorPolicy[int]{}, output: []int{1, 3, 5}, errors: 3},
+		{name: "retry", failures: 2, policy: graph.ErrorPolicy[int]{RetryPolicy: graph.RetryPolicy{Retry: 2, Backoff: time.Millisecond}}, output: input},
+		{name: "skip", failures: 1, policy: graph.ErrorPolicy[int]{Action: graph.ErrorSkip}, output: []int{1, 3, 5}},
+		{
+			name: "dead letter", failures: 1, output: []int{-6, -4, -2, 1, 3, 5},
+			policy: graph.ErrorPolicy[int]{Action: graph.ErrorDeadLetter, DeadLetter: "dlq", DeadLetterItem: func(input int, _ error) int { return -input }},
+		},
+		{name: "fail", failures: 1, policy: graph.ErrorPolicy[int]{Action: graph.ErrorFail}, expected: graph.ErrStageFailed},
+	}
+	for _, kind := range []graph.Kind{graph.KindWorker, graph.KindConsumer} {
+		for _, tc := range testCases {
+			t.Run(kind.String()+" "+tc.name, func(t *testing.T) {
+				consumed := []int{}
+				worker := failingEven(kind, tc.failures, &consumed)
+				if err := graph.SetErrorPolicy(worker, tc.policy); err != nil {
+					t.Fatal(err)
+				}
+				g := graph.NewIO(graph.WithVertices([]graph.IOWorkerVertex[int]{
+					graph.NewIOWorkerVertex("worker", []string{}, worker, graph.WithDeadLetter[int](tc.policy.DeadLetter)),
+					graph.NewIOWorkerVertex("dlq", []string{}, graphtest.ForwardWorker[int]()),
+				}))
+				if tc.policy.DeadLetter == "" {
+					g = graph.NewIO(graph.WithVertices([]graph.IOWorkerVertex[int]{graph.NewIOWorkerVertex("worker", []string{}, worker)}))
+				}
+				if err := g.Validate(); err != nil {
+					t.Fatal(err)
+				}
+				g.SetInput(diwo.FromSlice(input))
+				outputC := g.Output()
+				ctx := graph.NewContext(context.Background())
+				errC := g.Run(ctx)
+				ctx.Synchronize()
+				errs := make(chan []error)
+				go func() {
+					res := []error{}
+					for err := range errC {
+						res = append(res, err)
+					}
+					errs <- res
+				}()
+				output := slices.Collect(diwo.Seq(outputC))
+				receivedErrs := <-errs
+				output = slices.Sorted(slices.Values(append(output, consumed...)))
+				if tc.expected != nil {
+					if len(receivedErrs) == 0 || !errors.Is(receivedErrs[0], tc.expected) || ctx.Err() == nil || g.Result().Status != graph.StatusFailed {
+						t.Errorf("expected %v and a canceled run, got %v", tc.expected, receivedErrs)
+					}
+					return
+				}
+				if !slices.Equal(output, tc.output) || len(receivedErrs) != tc.errors {
+					t.Errorf("got output %v with errors %v, expected %v with %d errors", output, receivedErrs, tc.output, tc.errors)
+				}
+				if status := g.Result().Status; (tc.errors > 0) != (status == graph.StatusFailed) {
+					t.Errorf("run status is %s with %d errors", status, tc.errors)
+				}
+			})
+		}
+	}
+}
+
+func TestRunResult(t *testing.T) {
+	g := graph.NewIO(graph.WithVertices([]graph.IOWorkerVertex[int]{
+		graph.NewIOWorkerVertex("forward", []string{}, graphtest.ForwardWorker[int](), graph.WithPluginName[int]("forward")),
+		graph.NewIOWorkerVertex("mult11", []string{"forward"}, graphtest.MultWorker[int](11)),
+		graph.NewIOWorkerVertex("odd", []string{"mult11"}, graphtest.OddWorker[int]()),
+	}))
+	g.SetInput(diwo.FromSlice([]int{1, 2, 3, 4, 5, 6}))
+	outputC := g.Output()
+	ctx := graph.NewContext(context.Background())
+	errC := g.Run(ctx)
+	ctx.Synchronize()
+	go func() {
+		for range outputC {
+		}
+	}()
+	for err := range errC {
+		t.Errorf("unexpected error %v", err)
+	}
+	result := g.Result()
+	if result.Status != graph.StatusSucceeded || result.Err() != nil {
+		t.Fatalf("run should succeed, got %s: %v", result.Status, result.Err())
+	}
+	expected := map[string]graph.ItemStats{
+		"forward": {In: 6, Out: 6},
+		"mult11":  {In: 6, Out: 6},
+		"odd":     {In: 6, Out: 3},
+	}
+	for _, stage := range result.Stages {
+		if stage.Items != expected[stage.Name] || stage.Status != graph.StatusSucceeded || stage.Kind != graph.KindWorker {
+			t.Errorf("stage %s: got %+v, expected items %+v", stage.Name, stage, expected[stage.Name])
+		}
+	}
+	if len(result.Stages) != len(expected) || result.Stages[0].Plugin != "forward" {
+		t.Errorf("unexpected stages %+v", result.Stages)
+	}
+}
+
+func TestPorts(t *testing.T) {
+	parity := func(elem int) string {
+		if elem%2 == 0 {
+			return "even"
+		}
+		return "odd"
+	}
+	g := graph.NewIO(graph.WithVertices([]graph.IOWorkerVertex[int]{
+		graph.NewIOWorkerVertex("split", []string{}, graphtest.ForwardWorker[int](), graph.WithVertexPorts(parity)),
+		graph.NewIOWorkerVertex("even", []string{"split.even"}, graphtest.MultWorker[int](10)),
+		graph.NewIOWorkerVertex("all", []string{"split.even", "split.odd"}, graphtest.MultWorker[int](100)),
+	}))
+	if err := g.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	g.SetInput(diwo.FromSlice([]int{1, 2, 3, 4}))
+	outputC := g.Output()
+	ctx := graph.NewContext(context.Background())
+	errC := g.Run(ctx)
+	ctx.Synchronize()
+	go func() {
+		for err := range errC {
+			t.Errorf("unexpected error %v", err)
+		}
+	}()
+	output := slices.Sorted(diwo.Seq(outputC))
+	if expected := []int{20, 40, 100, 200, 300, 400}; !slices.Equal(output, expected) {
+		t.Errorf("each child should read the ports it names, got %v, expected %v", output, expected)
+	}
+}
+
+func TestExport(t *testing.T) {
+	sub := graph.NewIO(graph.WithVertices([]graph.IOWorkerVertex[int]{
+		graph.NewIOWorkerVertex("first", []string{}, graphtest.ForwardWorker[int]()),
+		graph.NewIOWorkerVertex("last", []string{"first"}, graphtest.ForwardWorker[int]()),
+	}))
+	g := graph.NewIO(graph.WithVertices([]graph.IOWorkerVertex[int]{
+		graph.NewIOWorkerVertex("split", []string{}, graphtest.ForwardWorker[int](),
+			graph.WithPluginName[int]("split"), graph.WithConfigSummary[int](`{"sep":","}`), graph.WithDeadLetter[int]("dlq")),
+		graph.NewIOWorkerVertex("sub", []string{"split.even"}, sub, graph.WithPluginName[int]("include")),
+		graph.NewIOWorkerVertex("dlq", []string{}, graphtest.ForwardWorker[int]()),
+	}))
+	desc, err := g.Describe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, vertex := range desc.Vertices {
+		names = append(names, vertex.Name)
+	}
+	if !slices.Equal(names, []string{"dlq", "split", "sub"}) {
+		t.Errorf("expected the vertices sorted by name, got %v", names)
+	}
+	if split := desc.Vertices[1]; split.Plugin != "split" || split.Kind != "worker" || split.Config != `{"sep":","}` {
+		t.Errorf("unexpected description of split %+v", split)
+	}
+	if subgraph := desc.Vertices[2].Subgraph; desc.Vertices[2].Kind != "graph" || subgraph == nil || len(subgraph.Vertices) != 2 || len(subgraph.Edges) != 1 {
+		t.Errorf("expected sub to describe its subgraph, got %+v", desc.Vertices[2])
+	}
+	expectedEdges := []graph.EdgeDescription{
+		{Source: "split", Target: "dlq", DeadLetter: true},
+		{Source: "split", Target: "sub", Ports: []string{"even"}},
+	}
+	if !slices.EqualFunc(desc.Edges, expectedEdges, func(a, b graph.EdgeDescription) bool {
+		return a.Source == b.Source && a.Target == b.Target && a.DeadLetter == b.DeadLetter && slices.Equal(a.Ports, b.Ports)
+	}) {
+		t.Errorf("unexpected edges %+v", desc.Edges)
+	}
+
+	dot := &strings.Builder{}
+	if err := g.Export(dot, graph.FormatDOT); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`subgraph "cluster_sub"`, `"split" -> "sub/first" [label="even"];`, `"sub/first" -> "sub/last";`, `style=dashed`} {
+		if !strings.Contains(dot.String(), expected) {
+			t.Errorf("expected %q in the DOT graph:\n%s", expected, dot)
+		}
+	}
+	mermaid := &strings.Builder{}
+	if err := g.Export(mermaid, graph.FormatMermaid); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(mermaid.String(), "flowchart LR") || !strings.Contains(mermaid.String(), `-.->|"dead letter"|`) {
+		t.Errorf("unexpected Mermaid graph:\n%s", mermaid)
+	}
+	if _, err := graph.ParseFormat("svg"); !errors.Is(err, graph.ErrUnknownFormat) {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+}
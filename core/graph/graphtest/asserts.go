@@ -2,14 +2,20 @@ package graphtest
 
 import (
 	"bytes"
-	"testing"
 	"time"
 )
 
+// T is the part of `testing.TB` used by the assertions, so that they also report outside of `go test`.
+type T interface {
+	Helper()
+	Error(args ...any)
+	Errorf(format string, args ...any)
+}
+
 // assertDefaultEqual is an assert helper function that listen the outputChan
 // and ensure that the output is equal to the dataTest in the correct order
 // it err is received on the chan it will fail the test and return
-func AssertDefaultEqual[K comparable](t *testing.T, dataTest []K, outputC <-chan K, errC <-chan error) {
+func AssertDefaultEqual[K comparable](t T, dataTest []K, outputC <-chan K, errC <-chan error) {
 	t.Helper()
 	i := 0
 	for {
@@ -38,7 +44,7 @@ func AssertDefaultEqual[K comparable](t *testing.T, dataTest []K, outputC <-chan
 	}
 }
 
-func AssertDefaultEqualByteSlices(t *testing.T, dataTest [][]byte, outputC <-chan []byte, errC <-chan error) {
+func AssertDefaultEqualByteSlices(t T, dataTest [][]byte, outputC <-chan []byte, errC <-chan error) {
 	t.Helper()
 	i := 0
 	for {
@@ -63,7 +69,7 @@ func AssertDefaultEqualByteSlices(t *testing.T, dataTest [][]byte, outputC <-cha
 
 // assertShouldNotReceiveData is an assert helper function that listen the outputChan
 // the assert failed if data is received on the chan within 3 seconds.
-func AssertShouldNotReceiveData[K any](t *testing.T, _ []K, outputC <-chan K, errC <-chan error) {
+func AssertShouldNotReceiveData[K any](t T, _ []K, outputC <-chan K, errC <-chan error) {
 	t.Helper()
 	tC := time.After(3 * time.Second)
 
@@ -1,12 +1,8 @@
 package graphtest
 
-import (
-	"testing"
-)
-
 type (
 	IOFunc[K any]     func() (chan<- K, <-chan K, <-chan error)
-	AssertFunc[K any] func(t *testing.T, dataTest []K, outputC <-chan K, errC <-chan error)
+	AssertFunc[K any] func(t T, dataTest []K, outputC <-chan K, errC <-chan error)
 )
 
 // WorkerConfigTest is a struct that contains the configuration for a worker test.
@@ -0,0 +1,149 @@
+package graphtest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Matcher tells whether an output is an expected one. `String` describes the expectation in the failure reports.
+type Matcher[K any] interface {
+	Match(elem K) bool
+	String() string
+}
+
+type equal[K comparable] struct {
+	want K
+}
+
+// Equal matches the outputs equal to `want`.
+func Equal[K comparable](want K) Matcher[K] {
+	return equal[K]{want: want}
+}
+
+func (e equal[K]) Match(elem K) bool {
+	return elem == e.want
+}
+
+func (e equal[K]) String() string {
+	return fmt.Sprintf("equal %q", fmt.Sprint(e.want))
+}
+
+type contains struct {
+	substr string
+}
+
+// Contains matches the outputs containing `substr`.
+func Contains(substr string) Matcher[string] {
+	return contains{substr: substr}
+}
+
+func (c contains) Match(elem string) bool {
+	return strings.Contains(elem, c.substr)
+}
+
+func (c contains) String() string {
+	return fmt.Sprintf("contains %q", c.substr)
+}
+
+type matches struct {
+	re *regexp.Regexp
+}
+
+// Regex matches the outputs matching the regular expression `pattern`.
+func Regex(pattern string) (Matcher[string], error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+	}
+	return matches{re: re}, nil
+}
+
+func (m matches) Match(elem string) bool {
+	return m.re.MatchString(elem)
+}
+
+func (m matches) String() string {
+	return fmt.Sprintf("regex %q", m.re.String())
+}
+
+// AssertMatch fails unless each output matches its own matcher and each matcher an output.
+// When `ordered`, the output and the matcher at the same index must match. The failure is reported as a diff:
+// `-` the expectations no output matched, `+` the outputs matching no expectation.
+func AssertMatch[K any](t T, matchers []Matcher[K], outputs []K, ordered bool) {
+	t.Helper()
+	var pairs []int
+	if ordered {
+		pairs = matchOrdered(matchers, outputs)
+	} else {
+		pairs = matchUnordered(matchers, outputs)
+	}
+	lines, failed := []string{}, false
+	matched := make([]bool, len(outputs))
+	for index, matcher := range matchers {
+		if output := pairs[index]; output >= 0 {
+			matched[output] = true
+			lines = append(lines, fmt.Sprintf("  %v", outputs[output]))
+			continue
+		}
+		failed = true
+		lines = append(lines, fmt.Sprintf("- %s", matcher))
+		if ordered && index < len(outputs) {
+			matched[index] = true
+			lines = append(lines, fmt.Sprintf("+ %v", outputs[index]))
+		}
+	}
+	for index, output := range outputs {
+		if !matched[index] {
+			failed = true
+			lines = append(lines, fmt.Sprintf("+ %v", output))
+		}
+	}
+	if failed {
+		t.Errorf("outputs do not match, %d expected, %d received:\n%s", len(matchers), len(outputs), strings.Join(lines, "\n"))
+	}
+}
+
+// matchOrdered returns the output matched by each matcher, -1 when none.
+func matchOrdered[K any](matchers []Matcher[K], outputs []K) []int {
+	pairs := make([]int, len(matchers))
+	for index, matcher := range matchers {
+		pairs[index] = -1
+		if index < len(outputs) && matcher.Match(outputs[index]) {
+			pairs[index] = index
+		}
+	}
+	return pairs
+}
+
+// matchUnordered pairs as many matchers and outputs as possible, an output matching several matchers goes to the one
+// no other output matches. It returns the output matched by each matcher, -1 when none.
+func matchUnordered[K any](matchers []Matcher[K], outputs []K) []int {
+	pairs := make([]int, len(matchers))
+	owners := make([]int, len(outputs))
+	for index := range pairs {
+		pairs[index] = -1
+	}
+	for index := range owners {
+		owners[index] = -1
+	}
+	// Augmenting paths: a matcher takes a free output, or one whose owner can move to another output.
+	var assign func(matcher int, visited []bool) bool
+	assign = func(matcher int, visited []bool) bool {
+		for output := range outputs {
+			if visited[output] || !matchers[matcher].Match(outputs[output]) {
+				continue
+			}
+			visited[output] = true
+			if owners[output] < 0 || assign(owners[output], visited) {
+				owners[output], pairs[matcher] = matcher, output
+				return true
+			}
+		}
+		return false
+	}
+	for matcher := range matchers {
+		assign(matcher, make([]bool, len(outputs)))
+	}
+	return pairs
+}
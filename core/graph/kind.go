@@ -0,0 +1,124 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Kind describes how a IOWorker interacts with its input and output.
+// It is used to detect impossible wiring between stages, like children under a Consumer.
+type Kind int
+
+const (
+	KindUnknown Kind = iota
+	KindProducer
+	KindConsumer
+	KindWorker
+	KindRunner
+	KindGraph
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindProducer:
+		return "producer"
+	case KindConsumer:
+		return "consumer"
+	case KindWorker:
+		return "worker"
+	case KindRunner:
+		return "runner"
+	case KindGraph:
+		return "graph"
+	default:
+		return "unknown"
+	}
+}
+
+// HasInput returns false when the kind never reads its input.
+func (k Kind) HasInput() bool {
+	return k != KindProducer
+}
+
+// HasOutput returns false when the kind never writes on its output.
+func (k Kind) HasOutput() bool {
+	return k != KindConsumer
+}
+
+// KindDescriber is implemented by the IOWorker (and plugins) able to tell their Kind.
+type KindDescriber interface {
+	Kind() Kind
+}
+
+// KindOf returns the kind of the input if it implements `KindDescriber`, `KindUnknown` otherwise.
+func KindOf(v any) Kind {
+	if describer, ok := v.(KindDescriber); ok {
+		return describer.Kind()
+	}
+	return KindUnknown
+}
+
+var (
+	ErrConsumerWithChildren = errors.New("consumer can't have children, it never emits any output")
+	ErrProducerWithParents  = errors.New("producer can't have parents, it never reads its input")
+)
+
+// KindError reports a stage wired in a way its kind can't handle.
+type KindError struct {
+	Err       error
+	Vertex    string
+	Plugin    string
+	Kind      Kind
+	Neighbors []string
+}
+
+func (ke KindError) Error() string {
+	return fmt.Sprintf("stage %s (plugin %s, %s): %s: %s", ke.Vertex, ke.Plugin, ke.Kind, ke.Err.Error(), strings.Join(ke.Neighbors, ", "))
+}
+
+func (ke KindError) Unwrap() error {
+	return ke.Err
+}
+
+// ValidateKinds checks that every vertex is wired according to its kind.
+// A Consumer with children (other than its dead letter vertex) or a Producer with parents is an error.
+// A vertex with an unknown kind can't be checked, a warning is logged.
+func (sg *IO[K]) ValidateKinds() error {
+	childrenMap, err := sg.AdjacencyMap()
+	if err != nil {
+		return fmt.Errorf("validate kinds failed to get adjacencies: %w", err)
+	}
+	parentsMap, err := sg.PredecessorMap()
+	if err != nil {
+		return fmt.Errorf("validate kinds failed to get predecessors: %w", err)
+	}
+	var errs error
+	for vertexHash, children := range childrenMap {
+		vertex, err := sg.Vertex(vertexHash)
+		if err != nil {
+			return fmt.Errorf("validate kinds failed to get vertex %s: %w", vertexHash, err)
+		}
+		kind := vertex.Kind()
+		if kind == KindUnknown {
+			slog.Warn("stage kind is unknown, its wiring can't be checked", "stage", vertexHash, "plugin", vertex.GetPlugin())
+			continue
+		}
+		if outputChildren := dataEdges(children); !kind.HasOutput() && len(outputChildren) > 0 {
+			errs = errors.Join(errs, KindError{
+				Err: ErrConsumerWithChildren, Vertex: vertexHash, Plugin: vertex.GetPlugin(), Kind: kind,
+				Neighbors: slices.Sorted(maps.Keys(outputChildren)),
+			})
+		}
+		if !kind.HasInput() && len(parentsMap[vertexHash]) > 0 {
+			errs = errors.Join(errs, KindError{
+				Err: ErrProducerWithParents, Vertex: vertexHash, Plugin: vertex.GetPlugin(), Kind: kind,
+				Neighbors: slices.Sorted(maps.Keys(parentsMap[vertexHash])),
+			})
+		}
+	}
+	return errs
+}
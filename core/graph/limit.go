@@ -0,0 +1,194 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benji-bou/diwo"
+	"golang.org/x/time/rate"
+)
+
+var (
+	ErrRateLimitNotSupported = errors.New("rate limit not supported")
+	ErrTimeoutNotSupported   = errors.New("timeout not supported")
+	ErrItemTimeout           = errors.New("item timed out")
+)
+
+// RateLimit bounds how many inputs a worker processes per second. `Burst` inputs may be processed at once
+// after an idle period, it defaults to 1. A zero `PerSecond` does not limit the worker.
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+func (rl RateLimit) limiter() *rate.Limiter {
+	if rl.PerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(rl.PerSecond), max(rl.Burst, 1))
+}
+
+// RateLimitConfigurer is implemented by the IOWorker able to throttle their inputs.
+// It must be called before `Run`.
+type RateLimitConfigurer interface {
+	SetRateLimit(limit RateLimit) error
+}
+
+// SetRateLimit configures the rate limit of the input when it implements `RateLimitConfigurer`.
+// A zero rate limit is always accepted.
+func SetRateLimit(v any, limit RateLimit) error {
+	if configurer, ok := v.(RateLimitConfigurer); ok {
+		return configurer.SetRateLimit(limit)
+	}
+	if limit.PerSecond <= 0 {
+		return nil
+	}
+	return fmt.Errorf("%w by %T", ErrRateLimitNotSupported, v)
+}
+
+// TimeoutConfigurer is implemented by the IOWorker able to bound the time spent on each input.
+// An input taking longer fails with `ErrItemTimeout` and goes through the error policy.
+// It must be called before `Run`.
+type TimeoutConfigurer interface {
+	SetTimeout(timeout time.Duration) error
+}
+
+// SetTimeout configures the per input timeout of the input when it implements `TimeoutConfigurer`.
+// A zero timeout is always accepted.
+func SetTimeout(v any, timeout time.Duration) error {
+	if configurer, ok := v.(TimeoutConfigurer); ok {
+		return configurer.SetTimeout(timeout)
+	}
+	if timeout <= 0 {
+		return nil
+	}
+	return fmt.Errorf("%w by %T", ErrTimeoutNotSupported, v)
+}
+
+func (v *ioWorker[K]) SetRateLimit(limit RateLimit) error {
+	if limit.PerSecond < 0 || limit.Burst < 0 {
+		return fmt.Errorf("invalid rate limit %v per second with a burst of %d", limit.PerSecond, limit.Burst)
+	}
+	v.limiter = limit.limiter()
+	return nil
+}
+
+func (v *ioWorker[K]) SetTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("invalid timeout %s", timeout)
+	}
+	v.timeout = timeout
+	return nil
+}
+
+// SetTimeout is delegated to the runner when it implements `TimeoutConfigurer`, like the gRPC plugins forwarding it
+// to the plugin server. Runners read their inputs ahead or hold them, so nothing outside tells when one is done.
+func (v *runWorker[K]) SetTimeout(timeout time.Duration) error {
+	return SetTimeout(v.runner, timeout)
+}
+
+// SetRateLimit is not supported by producers, they have no input to throttle.
+func (p *producerWorker[K]) SetRateLimit(limit RateLimit) error {
+	if limit.PerSecond <= 0 {
+		return nil
+	}
+	return fmt.Errorf("%w by %T", ErrRateLimitNotSupported, p.producer)
+}
+
+// SetTimeout is not supported by producers, they have no input to bound.
+func (p *producerWorker[K]) SetTimeout(timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+	return fmt.Errorf("%w by %T", ErrTimeoutNotSupported, p.producer)
+}
+
+func (v *BroadcasterIOWorker[K]) SetRateLimit(limit RateLimit) error {
+	return SetRateLimit(v.IOWorker, limit)
+}
+
+func (v *BroadcasterIOWorker[K]) SetTimeout(timeout time.Duration) error {
+	return SetTimeout(v.IOWorker, timeout)
+}
+
+// wait blocks until the rate limit allows processing an input.
+func (v *ioWorker[K]) wait(ctx context.Context) error {
+	if v.limiter == nil {
+		return nil
+	}
+	return v.limiter.Wait(ctx)
+}
+
+// limitInputs forwards the inputs once the rate limit allows it. Runners read their inputs ahead, the limit applies
+// when they are handed over.
+func (v *ioWorker[K]) limitInputs(ctx context.Context, input <-chan K) <-chan K {
+	return diwo.New(func(c chan<- K) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case elem, ok := <-input:
+				if !ok || v.wait(ctx) != nil {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case c <- elem:
+				}
+			}
+		}
+	})
+}
+
+// withTimeout calls `call` with a context canceled once the timeout expires.
+// A call still running at the deadline is abandoned and `ErrItemTimeout` is returned right away.
+// `guard` runs a side effect of the call, typically sending an output, unless the call was abandoned:
+// an abandoned call can't emit anything anymore.
+func (v *ioWorker[K]) withTimeout(ctx context.Context, call func(ctx context.Context, guard func(fn func()) bool) error) error {
+	if v.timeout <= 0 {
+		return call(ctx, func(fn func()) bool {
+			fn()
+			return true
+		})
+	}
+	callCtx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+	mux := sync.Mutex{}
+	abandoned := false
+	guard := func(fn func()) bool {
+		mux.Lock()
+		defer mux.Unlock()
+		if abandoned {
+			return false
+		}
+		fn()
+		return true
+	}
+	errC := make(chan error, 1)
+	go func() {
+		errC <- call(callCtx, guard)
+	}()
+	select {
+	case err := <-errC:
+		if err != nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return errors.Join(v.timeoutError(), err)
+		}
+		return err
+	case <-callCtx.Done():
+		mux.Lock()
+		abandoned = true
+		mux.Unlock()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return v.timeoutError()
+	}
+}
+
+func (v *ioWorker[K]) timeoutError() error {
+	return fmt.Errorf("%w after %s", ErrItemTimeout, v.timeout)
+}
@@ -0,0 +1,19 @@
+package graph
+
+// Middleware decorates the IOWorker of a vertex, for instance to instrument it.
+// It is applied by `IO.Run` on every vertex before they are piped, so it sees the inputs and outputs of
+// in-process and gRPC plugins alike. The decorator should forward `Kind`, `DeadLetters`, `Restarts` and `NotifyDone` to `worker`.
+type Middleware[K any] func(vertex IOWorkerVertex[K], worker IOWorker[K]) IOWorker[K]
+
+// RestartReporter is implemented by the IOWorker and Runner restarting their plugin after a failure, like a crashed plugin process.
+type RestartReporter interface {
+	Restarts() uint64
+}
+
+// RestartsOf returns how many times the input restarted its plugin if it implements `RestartReporter`, 0 otherwise.
+func RestartsOf(v any) uint64 {
+	if reporter, ok := v.(RestartReporter); ok {
+		return reporter.Restarts()
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package graph
+
+import (
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/benji-bou/diwo"
+	"github.com/dominikbraun/graph"
+)
+
+// PortDefault is the output of a vertex read by the children naming it without port.
+const PortDefault = ""
+
+// ParseParent splits a parent reference `vertex.port` in the name of the parent vertex and the port read from it.
+// A reference without dot reads the default port, vertex names can't contain dots.
+func ParseParent(ref string) (vertex, port string) {
+	index := strings.LastIndex(ref, ".")
+	if index < 0 {
+		return ref, PortDefault
+	}
+	return ref[:index], ref[index+1:]
+}
+
+// PortsDescriber is implemented by the vertices reading other ports than the default one of their parents.
+type PortsDescriber[K comparable] interface {
+	// GetParentPorts returns the ports read from `parent`.
+	GetParentPorts(parent K) []string
+}
+
+// edgePortAttribute returns the attribute of the edge from `parent` to `vertex`, naming the ports it reads.
+func edgePortAttribute[K comparable](vertex any, parent K) func(*graph.EdgeProperties) {
+	describer, ok := vertex.(PortsDescriber[K])
+	if !ok {
+		return func(*graph.EdgeProperties) {}
+	}
+	ports := describer.GetParentPorts(parent)
+	if len(ports) == 0 || (len(ports) == 1 && ports[0] == PortDefault) {
+		return func(*graph.EdgeProperties) {}
+	}
+	return graph.EdgeAttribute(EdgePortAttribute, strings.Join(ports, ","))
+}
+
+// edgePorts returns the ports of the source vertex read by the target vertex of a data edge.
+func edgePorts[K comparable](edge graph.Edge[K]) []string {
+	attribute, ok := edge.Properties.Attributes[EdgePortAttribute]
+	if !ok {
+		return []string{PortDefault}
+	}
+	return strings.Split(attribute, ",")
+}
+
+// parseParents splits the parent references of a vertex in the parent vertices, each listed once, and their ports.
+func parseParents(refs []string) ([]string, map[string][]string) {
+	parents := make([]string, 0, len(refs))
+	ports := make(map[string][]string, len(refs))
+	for _, ref := range refs {
+		parent, port := ParseParent(ref)
+		if _, ok := ports[parent]; !ok {
+			parents = append(parents, parent)
+		}
+		ports[parent] = append(ports[parent], port)
+	}
+	return parents, ports
+}
+
+// portDir returns the name of the directory of the disk queues of `port`.
+func portDir(port string) string {
+	if port == PortDefault {
+		return "_default"
+	}
+	return "port-" + url.PathEscape(port)
+}
+
+// outputPort broadcasts, or distributes, the items a vertex emits on one of its ports.
+type outputPort[K any] struct {
+	srcC    chan K
+	broker  broker[K]
+	emitted atomic.Uint64
+	queue   queueCounter
+}
+
+// newOutputPort returns the output `port`, its disk queues are stored in a directory named after it.
+func newOutputPort[K any](port string, buffer BufferConfig[K], fanout FanoutConfig[K]) *outputPort[K] {
+	out := &outputPort[K]{srcC: make(chan K)}
+	buffer = buffer.sub(portDir(port))
+	switch {
+	case fanout.distributes():
+		out.broker = newDistributor(out.srcC, fanout, buffer)
+	case buffer.bounded():
+		out.broker = newBoundedBroker(out.srcC, buffer)
+	default:
+		out.broker = diwo.NewBroker(out.srcC)
+	}
+	return out
+}
+
+func (out *outputPort[K]) send(elem K) {
+	out.emitted.Add(1)
+	out.srcC <- elem
+}
+
+func (out *outputPort[K]) close() {
+	close(out.srcC)
+}
+
+func (out *outputPort[K]) subscribe() <-chan K {
+	out.queue.subscribe(out.emitted.Load())
+	return diwo.Map(out.broker.Subscribe(), func(elem K) K {
+		out.queue.delivered.Add(1)
+		return elem
+	})
+}
+
+func (out *outputPort[K]) stats() BufferStats {
+	if b, ok := out.broker.(interface{ Stats() BufferStats }); ok {
+		return b.Stats()
+	}
+	return BufferStats{}
+}
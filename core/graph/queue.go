@@ -0,0 +1,357 @@
+package graph
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultSegmentSize is the size of the segment files of a disk queue when `BufferConfig.SegmentSize` is not set.
+const DefaultSegmentSize = 64 << 20
+
+const (
+	segmentExt   = ".seg"
+	offsetFile   = "offset"
+	recordHeader = 8
+)
+
+// diskQueue is a FIFO of items stored in segment files of about `segmentSize` bytes in `dir`.
+// Each record is its length, its CRC32 and the encoded item. A segment is removed once fully read.
+// The position of the next item to read is committed in the `offset` file, replaced atomically after each read,
+// so that a queue reopened after a crash resumes at the first unread item. A record torn by the crash is dropped.
+type diskQueue[K any] struct {
+	dir         string
+	segmentSize int64
+	codec       Codec[K]
+	segments    []segment
+	writer      *os.File
+	reader      *os.File
+	readOffset  int64
+	// peeked is the size of the record returned by `Peek`, removed by `Pop`.
+	peeked int64
+	count  int
+}
+
+// segment is a file of the queue, `count` is the number of its records left to read.
+type segment struct {
+	id    uint64
+	size  int64
+	count int
+}
+
+func (s segment) name() string {
+	return fmt.Sprintf("%020d%s", s.id, segmentExt)
+}
+
+// openDiskQueue opens the queue stored in `dir`, it is created when missing.
+func openDiskQueue[K any](dir string, segmentSize int64, codec Codec[K]) (*diskQueue[K], error) {
+	if codec == nil {
+		return nil, errors.New("no codec to encode queued items")
+	}
+	if segmentSize <= 0 {
+		segmentSize = DefaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil { //nolint:mnd // the queue is private to the run
+		return nil, fmt.Errorf("create disk queue: %w", err)
+	}
+	q := &diskQueue[K]{dir: dir, segmentSize: segmentSize, codec: codec}
+	if err := q.recover(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// recover loads the segments from the committed offset, counting their valid records.
+func (q *diskQueue[K]) recover() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return fmt.Errorf("read disk queue: %w", err)
+	}
+	ids := []uint64{}
+	for _, entry := range entries {
+		if id, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(), segmentExt), 10, 64); err == nil && strings.HasSuffix(entry.Name(), segmentExt) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	firstID, offset := q.committed()
+	for _, id := range ids {
+		if id < firstID {
+			if err := os.Remove(filepath.Join(q.dir, segment{id: id}.name())); err != nil {
+				return fmt.Errorf("remove read segment: %w", err)
+			}
+			continue
+		}
+		start := int64(0)
+		if id == firstID {
+			start = offset
+		}
+		size, count, err := q.scan(segment{id: id}, start, id == ids[len(ids)-1])
+		if err != nil {
+			return err
+		}
+		q.segments = append(q.segments, segment{id: id, size: size, count: count})
+		q.count += count
+		if len(q.segments) == 1 {
+			q.readOffset = min(start, size)
+		}
+	}
+	if len(q.segments) == 0 {
+		q.segments = []segment{{id: firstID}}
+	}
+	return q.open()
+}
+
+// committed returns the segment and the offset of the next item to read.
+func (q *diskQueue[K]) committed() (uint64, int64) {
+	raw, err := os.ReadFile(filepath.Join(q.dir, offsetFile))
+	if err != nil {
+		return 0, 0
+	}
+	var id uint64
+	var offset int64
+	if _, err := fmt.Sscanf(string(raw), "%d %d", &id, &offset); err != nil {
+		slog.Warn("disk queue offset is invalid, reading from the first segment", "dir", q.dir, "error", err)
+		return 0, 0
+	}
+	return id, offset
+}
+
+// scan counts the valid records of `seg` from `start` and returns the end of the last one.
+// The invalid tail of the last segment is truncated, the next items are written after the last valid one.
+func (q *diskQueue[K]) scan(seg segment, start int64, last bool) (int64, int, error) {
+	path := filepath.Join(q.dir, seg.name())
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("open segment: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("stat segment: %w", err)
+	}
+	offset, count := start, 0
+	for offset < info.Size() {
+		size, _, err := readRecord(file, offset)
+		if err != nil {
+			slog.Warn("disk queue dropped the torn records of a segment", "segment", path, "offset", offset, "error", err)
+			break
+		}
+		offset += size
+		count++
+	}
+	if last && offset < info.Size() {
+		if err := os.Truncate(path, offset); err != nil {
+			return 0, 0, fmt.Errorf("truncate torn segment: %w", err)
+		}
+	}
+	return offset, count, nil
+}
+
+func (q *diskQueue[K]) open() error {
+	last := q.segments[len(q.segments)-1]
+	writer, err := os.OpenFile(filepath.Join(q.dir, last.name()), os.O_CREATE|os.O_WRONLY, 0o600) //nolint:mnd // the queue is private to the run
+	if err != nil {
+		return fmt.Errorf("open segment: %w", err)
+	}
+	reader, err := os.Open(filepath.Join(q.dir, q.segments[0].name()))
+	if err != nil {
+		writer.Close()
+		return fmt.Errorf("open segment: %w", err)
+	}
+	q.writer, q.reader = writer, reader
+	return nil
+}
+
+func (q *diskQueue[K]) Len() int {
+	if q == nil {
+		return 0
+	}
+	return q.count
+}
+
+// Write appends `elem`, in a new segment when the last one is full.
+func (q *diskQueue[K]) Write(elem K) error {
+	raw, err := q.codec.Encode(elem)
+	if err != nil {
+		return fmt.Errorf("encode queued item: %w", err)
+	}
+	record := make([]byte, recordHeader, recordHeader+len(raw))
+	binary.BigEndian.PutUint32(record, uint32(len(raw))) //nolint:gosec // items are far below 4GB
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(raw))
+	record = append(record, raw...)
+	last := &q.segments[len(q.segments)-1]
+	if last.size > 0 && last.size+int64(len(record)) > q.segmentSize {
+		if err := q.roll(); err != nil {
+			return err
+		}
+		last = &q.segments[len(q.segments)-1]
+	}
+	n, err := q.writer.WriteAt(record, last.size)
+	if err != nil {
+		return fmt.Errorf("write queued item: %w", err)
+	}
+	last.size += int64(n)
+	last.count++
+	q.count++
+	return nil
+}
+
+// roll starts a new segment.
+func (q *diskQueue[K]) roll() error {
+	next := segment{id: q.segments[len(q.segments)-1].id + 1}
+	writer, err := os.OpenFile(filepath.Join(q.dir, next.name()), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) //nolint:mnd // the queue is private to the run
+	if err != nil {
+		return fmt.Errorf("create segment: %w", err)
+	}
+	// The segments are read with their own handle.
+	if err := q.writer.Close(); err != nil {
+		slog.Warn("disk queue failed to close segment", "error", err)
+	}
+	q.writer = writer
+	q.segments = append(q.segments, next)
+	return nil
+}
+
+// Read removes and returns the first item.
+func (q *diskQueue[K]) Read() (K, error) {
+	elem, err := q.Peek()
+	if err != nil {
+		return elem, err
+	}
+	q.Pop()
+	return elem, nil
+}
+
+// Peek returns the first item without removing it, see `Pop`. A corrupted item, or the items of a segment that can't
+// be read anymore, are removed and returned as an error.
+func (q *diskQueue[K]) Peek() (K, error) {
+	var zero K
+	if q.count == 0 {
+		return zero, errors.New("disk queue is empty")
+	}
+	for q.segments[0].count == 0 && len(q.segments) > 1 {
+		if err := q.next(); err != nil {
+			// The next segment can't be opened, its items are lost.
+			q.count -= q.segments[0].count
+			q.segments[0].count = 0
+			return zero, err
+		}
+	}
+	first := &q.segments[0]
+	size, raw, err := readRecord(q.reader, q.readOffset)
+	if err != nil {
+		// The record can't be delimited, skip what is left of the segment.
+		q.count -= first.count
+		first.count, q.readOffset = 0, first.size
+		if err := q.commit(); err != nil {
+			slog.Warn("disk queue failed to commit its offset", "error", err)
+		}
+		return zero, err
+	}
+	q.peeked = size
+	elem, err := q.codec.Decode(raw)
+	if err != nil {
+		q.Pop()
+		return zero, err
+	}
+	return elem, nil
+}
+
+// Pop removes the item returned by the last `Peek` and commits the new offset.
+func (q *diskQueue[K]) Pop() {
+	if q.peeked == 0 {
+		return
+	}
+	first := &q.segments[0]
+	q.readOffset += q.peeked
+	q.peeked = 0
+	first.count--
+	q.count--
+	if q.count == 0 && len(q.segments) == 1 {
+		// The queue is empty, the segment is reused from its start.
+		q.readOffset, first.size = 0, 0
+		if err := q.writer.Truncate(0); err != nil {
+			slog.Warn("disk queue failed to truncate segment", "error", err)
+		}
+	}
+	if err := q.commit(); err != nil {
+		slog.Warn("disk queue failed to commit its offset", "error", err)
+	}
+}
+
+// next removes the first segment, fully read, and opens the following one.
+func (q *diskQueue[K]) next() error {
+	if q.reader != nil {
+		if err := q.reader.Close(); err != nil {
+			slog.Warn("disk queue failed to close segment", "error", err)
+		}
+		q.reader = nil
+	}
+	read := q.segments[0]
+	q.segments = q.segments[1:]
+	q.readOffset = 0
+	if err := os.Remove(filepath.Join(q.dir, read.name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("disk queue failed to remove read segment", "error", err)
+	}
+	reader, err := os.Open(filepath.Join(q.dir, q.segments[0].name()))
+	if err != nil {
+		return fmt.Errorf("open segment: %w", err)
+	}
+	q.reader = reader
+	return nil
+}
+
+// commit replaces the offset file, a crash leaves either the previous offset or the new one.
+func (q *diskQueue[K]) commit() error {
+	tmp := filepath.Join(q.dir, offsetFile+".tmp")
+	content := fmt.Sprintf("%d %d\n", q.segments[0].id, q.readOffset)
+	if err := os.WriteFile(tmp, []byte(content), 0o600); err != nil { //nolint:mnd // the queue is private to the run
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(q.dir, offsetFile))
+}
+
+// Close closes the segments, they stay on disk to be reopened.
+func (q *diskQueue[K]) Close() error {
+	if q == nil {
+		return nil
+	}
+	errs := []error{q.writer.Close()}
+	if q.reader != nil {
+		errs = append(errs, q.reader.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Remove closes the queue and deletes its directory.
+func (q *diskQueue[K]) Remove() error {
+	if q == nil {
+		return nil
+	}
+	return errors.Join(q.Close(), os.RemoveAll(q.dir))
+}
+
+// readRecord returns the size of the record at `offset` and its payload.
+func readRecord(file io.ReaderAt, offset int64) (int64, []byte, error) {
+	header := make([]byte, recordHeader)
+	if _, err := file.ReadAt(header, offset); err != nil {
+		return 0, nil, fmt.Errorf("read queued item header: %w", err)
+	}
+	raw := make([]byte, binary.BigEndian.Uint32(header))
+	if _, err := file.ReadAt(raw, offset+recordHeader); err != nil {
+		return 0, nil, fmt.Errorf("read queued item: %w", err)
+	}
+	if crc32.ChecksumIEEE(raw) != binary.BigEndian.Uint32(header[4:]) {
+		return 0, nil, errors.New("queued item is corrupted")
+	}
+	return int64(recordHeader + len(raw)), raw, nil
+}
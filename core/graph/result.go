@@ -0,0 +1,183 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// Status is the state of a stage or of a whole run.
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+// ItemStats counts the items read and emitted by a vertex.
+type ItemStats struct {
+	In  uint64
+	Out uint64
+}
+
+// StageResult summarizes the run of a vertex.
+// A stage which reported at least one error is failed, a stage interrupted by the cancellation of the run is canceled.
+type StageResult struct {
+	Name     string
+	Plugin   string
+	Kind     Kind
+	Items    ItemStats
+	Errors   uint64
+	Buffer   BufferStats
+	Duration time.Duration
+	Status   Status
+}
+
+// RunResult summarizes the run of an IO graph. It is final once the error channel returned by `Run` is closed.
+type RunResult struct {
+	Status   Status
+	Duration time.Duration
+	Stages   []StageResult
+}
+
+var ErrRunFailed = errors.New("run failed")
+
+// Err returns nil when the run succeeded, an error wrapping `ErrRunFailed` naming the failed stages otherwise.
+func (rr RunResult) Err() error {
+	if rr.Status == StatusSucceeded {
+		return nil
+	}
+	failed := []string{}
+	for _, stage := range rr.Stages {
+		if stage.Status != StatusSucceeded {
+			failed = append(failed, fmt.Sprintf("%s (%s)", stage.Name, stage.Status))
+		}
+	}
+	return fmt.Errorf("%w: %s: %s", ErrRunFailed, rr.Status, strings.Join(failed, ", "))
+}
+
+// Write prints the result as a table, one line per stage.
+func (rr RunResult) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) //nolint:mnd // column padding
+	fmt.Fprintln(tw, "STAGE\tPLUGIN\tKIND\tIN\tOUT\tERRORS\tDROPPED\tSPILLED\tDURATION\tSTATUS")
+	for _, stage := range rr.Stages {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n",
+			stage.Name, stage.Plugin, stage.Kind, stage.Items.In, stage.Items.Out, stage.Errors,
+			stage.Buffer.Dropped, stage.Buffer.Spilled, stage.Duration.Round(time.Millisecond), stage.Status)
+	}
+	fmt.Fprintf(tw, "run\t\t\t\t\t\t\t\t%s\t%s\n", rr.Duration.Round(time.Millisecond), rr.Status)
+	return tw.Flush()
+}
+
+// itemCounter counts the items going through the input and output of a vertex.
+type itemCounter struct {
+	in  atomic.Uint64
+	out atomic.Uint64
+}
+
+func (ic *itemCounter) stats() ItemStats {
+	return ItemStats{In: ic.in.Load(), Out: ic.out.Load()}
+}
+
+// queueCounter counts the items delivered to the subscribers of a broadcaster.
+// Each subscriber expects the items emitted after it subscribed.
+type queueCounter struct {
+	subscribers atomic.Uint64
+	skipped     atomic.Uint64
+	delivered   atomic.Uint64
+}
+
+func (qc *queueCounter) subscribe(emitted uint64) {
+	qc.skipped.Add(emitted)
+	qc.subscribers.Add(1)
+}
+
+func (qc *queueCounter) depth(emitted, dropped uint64) uint64 {
+	expected := qc.subscribers.Load()*emitted - qc.skipped.Load()
+	gone := qc.delivered.Load() + dropped
+	if gone > expected {
+		return 0
+	}
+	return expected - gone
+}
+
+// sharedDepth is the depth of subscribers sharing the items, each item is expected by a single subscriber.
+func (qc *queueCounter) sharedDepth(emitted, dropped uint64) uint64 {
+	if qc.subscribers.Load() == 0 {
+		return 0
+	}
+	gone := qc.delivered.Load() + dropped
+	if gone > emitted {
+		return 0
+	}
+	return emitted - gone
+}
+
+// stageRun tracks the run of a vertex from the call to `Run` until its error channel is closed.
+type stageRun struct {
+	started  time.Time
+	ended    time.Time
+	errors   atomic.Uint64
+	canceled bool
+	mux      sync.Mutex
+}
+
+func (sr *stageRun) end(canceled bool) {
+	sr.mux.Lock()
+	defer sr.mux.Unlock()
+	sr.ended = time.Now()
+	sr.canceled = canceled
+}
+
+func (sr *stageRun) endedAt() time.Time {
+	sr.mux.Lock()
+	defer sr.mux.Unlock()
+	return sr.ended
+}
+
+func (sr *stageRun) status() (Status, time.Duration) {
+	sr.mux.Lock()
+	defer sr.mux.Unlock()
+	switch {
+	case sr.ended.IsZero():
+		return StatusRunning, time.Since(sr.started)
+	case sr.errors.Load() > 0:
+		return StatusFailed, sr.ended.Sub(sr.started)
+	case sr.canceled:
+		return StatusCanceled, sr.ended.Sub(sr.started)
+	default:
+		return StatusSucceeded, sr.ended.Sub(sr.started)
+	}
+}
+
+// runStatus aggregates the stages status: failed wins over canceled, canceled over running.
+// A run without any stage failed.
+func runStatus(stages []StageResult) Status {
+	if len(stages) == 0 {
+		return StatusFailed
+	}
+	status := StatusSucceeded
+	for _, stage := range stages {
+		switch {
+		case stage.Status == StatusFailed:
+			return StatusFailed
+		case stage.Status == StatusCanceled:
+			status = StatusCanceled
+		case stage.Status == StatusRunning && status == StatusSucceeded:
+			status = StatusRunning
+		}
+	}
+	return status
+}
+
+func sortStages(stages []StageResult) {
+	slices.SortFunc(stages, func(a, b StageResult) int { return strings.Compare(a.Name, b.Name) })
+}
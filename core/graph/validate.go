@@ -0,0 +1,182 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/benji-bou/lugh/helper"
+	"github.com/dominikbraun/graph"
+)
+
+var (
+	ErrCycle             = errors.New("cycle detected")
+	ErrUnknownParent     = errors.New("unknown parent")
+	ErrSelfLoop          = errors.New("vertex is its own parent")
+	ErrDuplicateVertex   = errors.New("duplicate vertex name")
+	ErrOrphanVertex      = errors.New("vertex unreachable from any root vertex")
+	ErrUnknownDeadLetter = errors.New("unknown dead letter vertex")
+	ErrDeadLetterIsChild = errors.New("dead letter vertex already reads the output of the vertex")
+)
+
+// ValidationError describes a single static error found by `Validate`.
+// `Err` is one of the `Err*` sentinel above so callers can use `errors.Is` to filter them.
+// `Parent` is only set for `ErrUnknownParent`, `DeadLetter` for the dead letter errors and `Cycle` only for `ErrCycle`.
+type ValidationError[K comparable] struct {
+	Err        error
+	Vertex     K
+	Parent     K
+	DeadLetter K
+	Cycle      []K
+}
+
+func (ve ValidationError[K]) Error() string {
+	switch {
+	case errors.Is(ve.Err, ErrUnknownParent):
+		return fmt.Sprintf("vertex %v: %s %v", ve.Vertex, ve.Err.Error(), ve.Parent)
+	case errors.Is(ve.Err, ErrUnknownDeadLetter), errors.Is(ve.Err, ErrDeadLetterIsChild):
+		return fmt.Sprintf("vertex %v: %s %v", ve.Vertex, ve.Err.Error(), ve.DeadLetter)
+	case errors.Is(ve.Err, ErrCycle):
+		path := helper.Map(ve.Cycle, func(elem K) string { return fmt.Sprint(elem) })
+		return fmt.Sprintf("%s: %s", ve.Err.Error(), strings.Join(path, " -> "))
+	default:
+		return fmt.Sprintf("vertex %v: %s", ve.Vertex, ve.Err.Error())
+	}
+}
+
+func (ve ValidationError[K]) Unwrap() error {
+	return ve.Err
+}
+
+// Validate statically checks the graph before running it.
+// It reports duplicated vertex names, parents and dead letters that do not exist, vertices being their own parent,
+// cycles (with the full path of the cycle) and vertices that can't be reached from a parentless vertex.
+// All the problems found are joined in the returned error as `ValidationError`.
+func (g *GraphSelfDescribe[K, T]) Validate() error {
+	var errs error
+	for _, duplicate := range g.duplicates {
+		errs = errors.Join(errs, ValidationError[K]{Err: ErrDuplicateVertex, Vertex: duplicate})
+	}
+	vertices, err := g.Vertices()
+	if err != nil {
+		return fmt.Errorf("validate failed to get vertices: %w", err)
+	}
+	for name, vertex := range vertices {
+		for _, parent := range vertex.GetParents() {
+			if parent == name {
+				errs = errors.Join(errs, ValidationError[K]{Err: ErrSelfLoop, Vertex: name})
+			} else if _, ok := vertices[parent]; !ok {
+				errs = errors.Join(errs, ValidationError[K]{Err: ErrUnknownParent, Vertex: name, Parent: parent})
+			}
+		}
+		errs = errors.Join(errs, validateDeadLetter(name, vertex, vertices))
+	}
+	adjacencies, err := g.AdjacencyMap()
+	if err != nil {
+		return fmt.Errorf("validate failed to get adjacencies: %w", err)
+	}
+	for _, cycle := range findCycles(adjacencies) {
+		errs = errors.Join(errs, ValidationError[K]{Err: ErrCycle, Vertex: cycle[0], Cycle: cycle})
+	}
+	predecessors, err := g.PredecessorMap()
+	if err != nil {
+		return fmt.Errorf("validate failed to get predecessors: %w", err)
+	}
+	for _, orphan := range findUnreachable(adjacencies, predecessors) {
+		errs = errors.Join(errs, ValidationError[K]{Err: ErrOrphanVertex, Vertex: orphan})
+	}
+	return errs
+}
+
+// validateDeadLetter checks that the dead letter vertex of `vertex` exists and is not already one of its children.
+func validateDeadLetter[K comparable, T VertexSelfDescribe[K]](name K, vertex T, vertices map[K]T) error {
+	deadLetter, ok := deadLetterOf[K](vertex)
+	if !ok {
+		return nil
+	}
+	if deadLetter == name {
+		return ValidationError[K]{Err: ErrSelfLoop, Vertex: name}
+	}
+	target, ok := vertices[deadLetter]
+	if !ok {
+		return ValidationError[K]{Err: ErrUnknownDeadLetter, Vertex: name, DeadLetter: deadLetter}
+	}
+	if slices.Contains(target.GetParents(), name) {
+		return ValidationError[K]{Err: ErrDeadLetterIsChild, Vertex: name, DeadLetter: deadLetter}
+	}
+	return nil
+}
+
+// findCycles runs a depth first search over the adjacency map and returns every cycle closed by a back edge.
+// Each cycle starts and ends with the same vertex. Self loops are ignored, they are reported as `ErrSelfLoop`.
+func findCycles[K comparable](adjacencies map[K]map[K]graph.Edge[K]) [][]K {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+	state := make(map[K]int, len(adjacencies))
+	stack := make([]K, 0, len(adjacencies))
+	cycles := [][]K{}
+	var visit func(current K)
+	visit = func(current K) {
+		state[current] = inProgress
+		stack = append(stack, current)
+		for child := range adjacencies[current] {
+			if child == current {
+				continue
+			}
+			switch state[child] {
+			case unvisited:
+				visit(child)
+			case inProgress:
+				start := len(stack) - 1
+				for stack[start] != child {
+					start--
+				}
+				cycle := make([]K, 0, len(stack)-start+1)
+				cycle = append(cycle, stack[start:]...)
+				cycles = append(cycles, append(cycle, child))
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[current] = done
+	}
+	for vertex := range adjacencies {
+		if state[vertex] == unvisited {
+			visit(vertex)
+		}
+	}
+	return cycles
+}
+
+// findUnreachable returns the vertices that can't be reached from any parentless vertex.
+// Those vertices never receive any input, they only exist in cycles without entry point.
+func findUnreachable[K comparable](adjacencies, predecessors map[K]map[K]graph.Edge[K]) []K {
+	reached := make(map[K]struct{}, len(adjacencies))
+	toVisit := make([]K, 0, len(adjacencies))
+	for vertex, parents := range predecessors {
+		if len(parents) == 0 {
+			toVisit = append(toVisit, vertex)
+		}
+	}
+	for len(toVisit) > 0 {
+		current := toVisit[len(toVisit)-1]
+		toVisit = toVisit[:len(toVisit)-1]
+		if _, ok := reached[current]; ok {
+			continue
+		}
+		reached[current] = struct{}{}
+		for child := range adjacencies[current] {
+			toVisit = append(toVisit, child)
+		}
+	}
+	unreachable := []K{}
+	for vertex := range adjacencies {
+		if _, ok := reached[vertex]; !ok {
+			unreachable = append(unreachable, vertex)
+		}
+	}
+	return unreachable
+}
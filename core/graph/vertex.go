@@ -2,10 +2,16 @@ package graph
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"reflect"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/benji-bou/diwo"
+	"github.com/benji-bou/lugh/helper"
+	"golang.org/x/time/rate"
 )
 
 type Worker[K any] interface {
@@ -58,18 +64,105 @@ type VertexSelfDescribe[K comparable] interface {
 
 type IOWorkerVertex[K any] struct {
 	IOWorker[K]
-	name    string
-	parents []string
+	name       string
+	parents    []string
+	ports      map[string][]string
+	plugin     string
+	pluginPath string
+	config     string
+	subgraph   GraphDescriber
+	kind       Kind
+	buffer     BufferConfig[K]
+	fanout     FanoutConfig[K]
+	mapper     func(elem K) K
+	portOf     func(elem K) string
+	deadLetter string
+}
+
+type IOWorkerVertexOption[K any] = helper.Option[IOWorkerVertex[K]]
+
+// WithPluginName sets the name of the plugin behind the vertex. Used to describe the vertex in errors.
+func WithPluginName[K any](plugin string) IOWorkerVertexOption[K] {
+	return func(configure *IOWorkerVertex[K]) {
+		configure.plugin = plugin
+	}
+}
+
+// WithPluginPath sets the path the plugin behind the vertex is loaded from. Used to describe the vertex.
+func WithPluginPath[K any](path string) IOWorkerVertexOption[K] {
+	return func(configure *IOWorkerVertex[K]) {
+		configure.pluginPath = path
+	}
+}
+
+// WithConfigSummary sets a short description of the config of the plugin behind the vertex. Used to describe the vertex.
+func WithConfigSummary[K any](summary string) IOWorkerVertexOption[K] {
+	return func(configure *IOWorkerVertex[K]) {
+		configure.config = summary
+	}
+}
+
+// WithKind overrides the kind of the vertex detected from the decorated IOWorker.
+func WithKind[K any](kind Kind) IOWorkerVertexOption[K] {
+	return func(configure *IOWorkerVertex[K]) {
+		configure.kind = kind
+	}
 }
 
-func NewIOWorkerVertex[K any](name string, parents []string, decorated IOWorker[K]) IOWorkerVertex[K] {
-	return IOWorkerVertex[K]{
-		IOWorker: NewBroadcasterIOWorker(decorated),
-		name:     name,
-		parents:  parents,
+// WithVertexBuffer configures the buffer of each child subscribed to the vertex output. See `WithBuffer`.
+func WithVertexBuffer[K any](buffer BufferConfig[K]) IOWorkerVertexOption[K] {
+	return func(configure *IOWorkerVertex[K]) {
+		configure.buffer = buffer
 	}
 }
 
+// WithVertexFanout decides which children receive each item emitted by the vertex. See `WithFanout`.
+func WithVertexFanout[K any](fanout FanoutConfig[K]) IOWorkerVertexOption[K] {
+	return func(configure *IOWorkerVertex[K]) {
+		configure.fanout = fanout
+	}
+}
+
+// WithVertexOutputMap transforms each item emitted by the vertex before its children receive it. See `WithOutputMap`.
+func WithVertexOutputMap[K any](mapper func(elem K) K) IOWorkerVertexOption[K] {
+	return func(configure *IOWorkerVertex[K]) {
+		configure.mapper = mapper
+	}
+}
+
+// WithVertexPorts routes each item emitted by the vertex to the port named by `portOf`. See `WithPorts`.
+func WithVertexPorts[K any](portOf func(elem K) string) IOWorkerVertexOption[K] {
+	return func(configure *IOWorkerVertex[K]) {
+		configure.portOf = portOf
+	}
+}
+
+// WithDeadLetter links the vertex to the vertex receiving its failing inputs. See `ErrorDeadLetter`.
+func WithDeadLetter[K any](deadLetter string) IOWorkerVertexOption[K] {
+	return func(configure *IOWorkerVertex[K]) {
+		configure.deadLetter = deadLetter
+	}
+}
+
+// NewIOWorkerVertex decorates `decorated` as the vertex `name`. Each parent is a vertex name, or `vertex.port`
+// to read another port than the default one of the parent. See `ParseParent`.
+func NewIOWorkerVertex[K any](name string, parents []string, decorated IOWorker[K], opt ...IOWorkerVertexOption[K]) IOWorkerVertex[K] {
+	parentVertices, ports := parseParents(parents)
+	vertex := helper.Configure(IOWorkerVertex[K]{
+		name:    name,
+		parents: parentVertices,
+		ports:   ports,
+		kind:    KindOf(decorated),
+	}, opt...)
+	if describer, ok := decorated.(GraphDescriber); ok {
+		vertex.subgraph = describer
+	}
+	vertex.IOWorker = NewBroadcasterIOWorker(decorated,
+		WithBuffer(vertex.buffer), WithFanout(vertex.fanout), WithOutputMap(vertex.mapper), WithPorts(vertex.portOf),
+	)
+	return vertex
+}
+
 func (dwv IOWorkerVertex[K]) GetName() string {
 	return dwv.name
 }
@@ -78,9 +171,84 @@ func (dwv IOWorkerVertex[K]) GetParents() []string {
 	return dwv.parents
 }
 
+// GetParentPorts returns the ports of `parent` read by the vertex.
+func (dwv IOWorkerVertex[K]) GetParentPorts(parent string) []string {
+	return dwv.ports[parent]
+}
+
+// OutputPort returns the items emitted by the vertex on `port`. See `BroadcasterIOWorker.OutputPort`.
+func (dwv IOWorkerVertex[K]) OutputPort(port string) <-chan K {
+	if broadcaster, ok := dwv.IOWorker.(*BroadcasterIOWorker[K]); ok {
+		return broadcaster.OutputPort(port)
+	}
+	if port == PortDefault {
+		return dwv.Output()
+	}
+	return diwo.Empty[K]()
+}
+
+func (dwv IOWorkerVertex[K]) GetDeadLetter() string {
+	return dwv.deadLetter
+}
+
+// DeadLetters returns the failing inputs of the vertex. It is closed right away when the vertex can't emit them.
+func (dwv IOWorkerVertex[K]) DeadLetters() <-chan K {
+	if emitter, ok := dwv.IOWorker.(DeadLetterEmitter[K]); ok {
+		if deadLetterC := emitter.DeadLetters(); deadLetterC != nil {
+			return deadLetterC
+		}
+	}
+	return diwo.Empty[K]()
+}
+
+func (dwv IOWorkerVertex[K]) GetPlugin() string {
+	return dwv.plugin
+}
+
+func (dwv IOWorkerVertex[K]) Kind() Kind {
+	return dwv.kind
+}
+
+// ItemStats returns the items counters of the vertex.
+func (dwv IOWorkerVertex[K]) ItemStats() ItemStats {
+	if broadcaster, ok := dwv.IOWorker.(*BroadcasterIOWorker[K]); ok {
+		return broadcaster.ItemStats()
+	}
+	return ItemStats{}
+}
+
+// BufferStats returns the dropped and spilled items counters of the vertex output buffers.
+func (dwv IOWorkerVertex[K]) BufferStats() BufferStats {
+	if broadcaster, ok := dwv.IOWorker.(*BroadcasterIOWorker[K]); ok {
+		return broadcaster.BufferStats()
+	}
+	return BufferStats{}
+}
+
+// QueueDepth returns how many items emitted by the vertex its children did not read yet.
+func (dwv IOWorkerVertex[K]) QueueDepth() uint64 {
+	if broadcaster, ok := dwv.IOWorker.(*BroadcasterIOWorker[K]); ok {
+		return broadcaster.QueueDepth()
+	}
+	return 0
+}
+
+// Use decorates the IOWorker of the vertex with `middleware`. See `Middleware`.
+func (dwv IOWorkerVertex[K]) Use(middleware ...Middleware[K]) {
+	if broadcaster, ok := dwv.IOWorker.(*BroadcasterIOWorker[K]); ok {
+		broadcaster.Use(dwv, middleware...)
+	}
+}
+
 type ioWorker[K any] struct {
-	inputC  <-chan K
-	outputC chan K
+	inputC      <-chan K
+	outputC     chan K
+	deadLetterC chan K
+	errorPolicy ErrorPolicy[K]
+	checkpoint  Checkpoint[K]
+	onDone      []func(input K, err error)
+	limiter     *rate.Limiter
+	timeout     time.Duration
 }
 
 func (v *ioWorker[K]) SetInput(input <-chan K) {
@@ -105,22 +273,32 @@ func (v *ioWorker[K]) Close() {
 	if v.outputC != nil {
 		close(v.outputC)
 	}
+	if v.deadLetterC != nil {
+		close(v.deadLetterC)
+	}
 }
 
 type syncWorker[K any] struct {
 	ioWorker[K]
-	worker Worker[K]
+	worker      Worker[K]
+	concurrency int
+	ordered     bool
 }
 
 func NewIOWorkerFromWorker[K any](worker Worker[K]) IOWorker[K] {
 	s := &syncWorker[K]{
-		ioWorker: ioWorker[K]{},
-		worker:   worker,
+		ioWorker:    ioWorker[K]{},
+		worker:      worker,
+		concurrency: 1,
 	}
 
 	return s
 }
 
+func (*syncWorker[K]) Kind() Kind {
+	return KindWorker
+}
+
 func (s *syncWorker[K]) Run(ctx SyncContext) <-chan error {
 	ctx.Initializing()
 	slog.Debug("Initializing Worker started", "worker", reflect.TypeOf(s.worker).String())
@@ -134,31 +312,58 @@ func (s *syncWorker[K]) Run(ctx SyncContext) <-chan error {
 		}()
 		slog.Debug("Worker initialized wait for sync", "worker", reflect.TypeOf(s.worker).String())
 		ctx.Initialized()
-		slog.Debug("Worker initialized and sync", "worker", reflect.TypeOf(s.worker).String())
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case data, ok := <-s.inputC:
-				slog.Debug("Worker Received", "worker", typeWorker)
-				if !ok {
-					return
+		slog.Debug("Worker initialized and sync", "worker", reflect.TypeOf(s.worker).String(), "concurrency", s.concurrency, "ordered", s.ordered)
+		if s.ordered {
+			s.runOrdered(ctx, workerCtx, errC)
+		} else {
+			s.runUnordered(ctx, workerCtx, errC)
+		}
+	}, diwo.WithName(reflect.TypeOf(s.worker).String()))
+}
+
+// work calls the underlying worker on a single input and forwards each yielded element to `send`.
+// It returns the error the input failed with, already handled, for `done` to be called once the outputs are sent.
+func (s *syncWorker[K]) work(workerCtx context.Context, data K, errC chan<- error, send func(elem K)) error {
+	typeWorker := reflect.TypeOf(s.worker).String()
+	if outputs, ok := s.replay(data); ok {
+		for _, elem := range outputs {
+			send(elem)
+		}
+		return nil
+	}
+	var outputs []K
+	err := s.errorPolicy.Do(workerCtx, func() error {
+		if err := s.wait(workerCtx); err != nil {
+			return err
+		}
+		// The outputs of a failed attempt are already sent, only those of the last attempt are recorded.
+		outputs = outputs[:0]
+		return s.withTimeout(workerCtx, func(ctx context.Context, guard func(fn func()) bool) error {
+			return s.worker.Work(ctx, data, func(elem K) error {
+				if ctx.Err() != nil {
+					return ctx.Err()
 				}
-				err := s.worker.Work(workerCtx, data, func(elem K) error {
-					if workerCtx.Err() != nil {
-						return workerCtx.Err()
+				slog.Debug("Worker Yielding to output chan", "worker", typeWorker)
+				sent := guard(func() {
+					send(elem)
+					if s.checkpoint != nil {
+						outputs = append(outputs, elem)
 					}
-					slog.Debug("Worker Yielding to output chan", "worker", typeWorker)
-					s.SendOutput(elem)
-					slog.Debug("Worker Yielded to output chan", "worker", typeWorker)
-					return nil
 				})
-				if err != nil {
-					errC <- err
+				if !sent {
+					return s.timeoutError()
 				}
-			}
-		}
-	}, diwo.WithName(reflect.TypeOf(s.worker).String()))
+				slog.Debug("Worker Yielded to output chan", "worker", typeWorker)
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		s.handleError(workerCtx, data, err, errC)
+		return err
+	}
+	s.record(data, outputs)
+	return nil
 }
 
 type producerWorker[K any] struct {
@@ -174,6 +379,10 @@ func NewIOWorkerFromProducer[K any](producer Producer[K]) IOWorker[K] {
 	return v
 }
 
+func (*producerWorker[K]) Kind() Kind {
+	return KindProducer
+}
+
 func (p *producerWorker[K]) Run(ctx SyncContext) <-chan error {
 	ctx.Initializing()
 	slog.Debug("Producer Initialization started", "producer", reflect.TypeOf(p.producer).String())
@@ -216,14 +425,24 @@ func NewIOWorkerFromConsumer[K any](consumer Consumer[K]) IOWorker[K] {
 	return v
 }
 
+func (*consumerWorker[K]) Kind() Kind {
+	return KindConsumer
+}
+
 func (c *consumerWorker[K]) Run(ctx SyncContext) <-chan error {
 	ctx.Initializing()
 	slog.Debug("Consummer Initialization started", "consummer", reflect.TypeOf(c.consumer).String())
-	c.Close()
+	// A consumer emits nothing, its output is closed right away. Its dead letters are closed once it is done.
+	if c.outputC != nil {
+		close(c.outputC)
+	}
 	return diwo.New(func(eC chan<- error) {
 		typeConsumer := reflect.TypeOf(c.consumer).String()
 
 		defer func() {
+			if c.deadLetterC != nil {
+				close(c.deadLetterC)
+			}
 			slog.Debug("Consumer exited Closed output chan", "worker", typeConsumer)
 		}()
 		slog.Debug("Consumer initialized wait for sync", "consumer", typeConsumer)
@@ -238,11 +457,26 @@ func (c *consumerWorker[K]) Run(ctx SyncContext) <-chan error {
 				if !ok {
 					return
 				}
-				err := c.consumer.Consume(ctx, input)
+				if _, done := c.replay(input); done {
+					c.done(input, nil)
+					continue
+				}
+				err := c.errorPolicy.Do(ctx, func() error {
+					if err := c.wait(ctx); err != nil {
+						return err
+					}
+					return c.withTimeout(ctx, func(ctx context.Context, _ func(fn func()) bool) error {
+						return c.consumer.Consume(ctx, input)
+					})
+				})
 				slog.Debug("Consumer consummed", "consumer", typeConsumer, "elem", input)
 				if err != nil {
-					eC <- err
+					c.handleError(ctx, input, err, eC)
+					c.done(input, err)
+					continue
 				}
+				c.record(input, nil)
+				c.done(input, nil)
 			}
 		}
 	})
@@ -261,6 +495,19 @@ func NewIOWorkerFromRunner[K any](runner Runner[K]) IOWorker[K] {
 	return v
 }
 
+// Kind returns the kind of the runner when it can describe itself (gRPC plugins forward the kind of the remote plugin).
+func (v *runWorker[K]) Kind() Kind {
+	if kind := KindOf(v.runner); kind != KindUnknown {
+		return kind
+	}
+	return KindRunner
+}
+
+// Restarts returns how many times the runner restarted its plugin, like a gRPC plugin whose process crashed.
+func (v *runWorker[K]) Restarts() uint64 {
+	return RestartsOf(v.runner)
+}
+
 func (v *runWorker[K]) Run(ctx SyncContext) <-chan error {
 	ctx.Initializing()
 	slog.Debug("Initializing Runner started", "runner", reflect.TypeOf(v.runner).String())
@@ -268,6 +515,10 @@ func (v *runWorker[K]) Run(ctx SyncContext) <-chan error {
 	return diwo.New(func(c chan<- error) {
 		typeRunner := reflect.TypeOf(v.runner).String()
 		runnerCtx, runnerCancel := context.WithCancel(ctx)
+		inputC := v.inputC
+		if inputC != nil && v.limiter != nil {
+			inputC = v.limitInputs(runnerCtx, inputC)
+		}
 		defer func() {
 			defer runnerCancel()
 			v.Close()
@@ -276,13 +527,18 @@ func (v *runWorker[K]) Run(ctx SyncContext) <-chan error {
 		slog.Debug("Runner initialized wait for sync", "runner", typeRunner)
 		ctx.Initialized()
 		slog.Debug("Runner initialized and sync", "runner", typeRunner)
-		err := v.runner.Run(ctx, v.inputC, func(elem K, err error) error {
+		err := v.runner.Run(ctx, inputC, func(elem K, err error) error {
 			if runnerCtx.Err() != nil {
 				return runnerCtx.Err()
 			}
 			if err != nil {
 				slog.Debug("Runner Yielding to error chan", "runner", typeRunner, "error", err)
-				c <- err
+				var itemErr ItemError[K]
+				if errors.As(err, &itemErr) {
+					v.handleError(runnerCtx, itemErr.Input, itemErr.Err, c)
+				} else {
+					v.handleError(runnerCtx, elem, err, c)
+				}
 				slog.Debug("Runner Yielded to error chan", "runner", typeRunner, "error", err)
 				return nil
 			}
@@ -297,20 +553,190 @@ func (v *runWorker[K]) Run(ctx SyncContext) <-chan error {
 	})
 }
 
+type broker[K any] interface {
+	Subscribe() <-chan K
+}
+
 type BroadcasterIOWorker[K any] struct {
 	IOWorker[K]
-	broker *diwo.Broker[K]
+	buffer BufferConfig[K]
+	fanout FanoutConfig[K]
+	mapper func(elem K) K
+	portOf func(elem K) string
+	items  itemCounter
+	// ports holds the broker of each output port read by a subscriber, by name.
+	ports map[string]*outputPort[K]
+	// outputDone is closed once every output item is forwarded to its port, see `Run`.
+	outputDone chan struct{}
+	routed     bool
+	mux        sync.Mutex
+}
+
+type BroadcasterOption[K any] = helper.Option[BroadcasterIOWorker[K]]
+
+// WithBuffer bounds the queue of each subscriber to `buffer.Size` items and applies `buffer.Policy` when it is full.
+// Without it each subscriber queue is unbounded.
+func WithBuffer[K any](buffer BufferConfig[K]) BroadcasterOption[K] {
+	return func(configure *BroadcasterIOWorker[K]) {
+		configure.buffer = buffer
+	}
 }
 
-func NewBroadcasterIOWorker[K any](worker IOWorker[K]) *BroadcasterIOWorker[K] {
-	return &BroadcasterIOWorker[K]{
-		IOWorker: worker,
+// WithFanout shares the output items between the subscribers according to `fanout.Mode` instead of broadcasting them.
+func WithFanout[K any](fanout FanoutConfig[K]) BroadcasterOption[K] {
+	return func(configure *BroadcasterIOWorker[K]) {
+		configure.fanout = fanout
+	}
+}
+
+// WithOutputMap applies `mapper` once on each output item, before it is broadcast to the subscribers.
+// A nil `mapper` leaves the items untouched.
+func WithOutputMap[K any](mapper func(elem K) K) BroadcasterOption[K] {
+	return func(configure *BroadcasterIOWorker[K]) {
+		configure.mapper = mapper
+	}
+}
+
+// WithPorts routes each output item to the port named by `portOf`, read with `OutputPort`.
+// The name is read before `WithOutputMap` applies. Without it every item goes to the default port.
+func WithPorts[K any](portOf func(elem K) string) BroadcasterOption[K] {
+	return func(configure *BroadcasterIOWorker[K]) {
+		configure.portOf = portOf
 	}
 }
 
+func NewBroadcasterIOWorker[K any](worker IOWorker[K], opt ...BroadcasterOption[K]) *BroadcasterIOWorker[K] {
+	return helper.ConfigurePtr(&BroadcasterIOWorker[K]{
+		IOWorker: worker,
+	}, opt...)
+}
+
+func (v *BroadcasterIOWorker[K]) Kind() Kind {
+	return KindOf(v.IOWorker)
+}
+
+// Output returns the items emitted on the default port.
 func (v *BroadcasterIOWorker[K]) Output() <-chan K {
-	if v.broker == nil {
-		v.broker = diwo.NewBroker(v.IOWorker.Output())
+	return v.OutputPort(PortDefault)
+}
+
+// OutputPort returns the items emitted on `port`. The items of a port nobody reads are lost.
+func (v *BroadcasterIOWorker[K]) OutputPort(port string) <-chan K {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	if v.outputDone == nil {
+		v.outputDone = make(chan struct{})
+		v.ports = make(map[string]*outputPort[K])
+		go v.route(v.IOWorker.Output())
+	}
+	out, ok := v.ports[port]
+	if !ok {
+		out = newOutputPort(port, v.buffer, v.fanout)
+		v.ports[port] = out
+		if v.routed {
+			out.close()
+		}
+	}
+	return out.subscribe()
+}
+
+// route forwards each output item to the broker of its port.
+func (v *BroadcasterIOWorker[K]) route(outputC <-chan K) {
+	defer close(v.outputDone)
+	defer func() {
+		v.mux.Lock()
+		defer v.mux.Unlock()
+		v.routed = true
+		for _, out := range v.ports {
+			out.close()
+		}
+	}()
+	for elem := range outputC {
+		v.items.out.Add(1)
+		port := PortDefault
+		if v.portOf != nil {
+			port = v.portOf(elem)
+		}
+		if v.mapper != nil {
+			elem = v.mapper(elem)
+		}
+		v.mux.Lock()
+		out, ok := v.ports[port]
+		v.mux.Unlock()
+		if ok {
+			out.send(elem)
+		}
+	}
+}
+
+// Run runs the decorated IOWorker. The returned channel is closed once its outputs are all forwarded
+// (or the run is canceled), so the `ItemStats` are final when it is closed.
+func (v *BroadcasterIOWorker[K]) Run(ctx SyncContext) <-chan error {
+	errC := v.IOWorker.Run(ctx)
+	outputDone := v.outputDone
+	return diwo.New(func(c chan<- error) {
+		for err := range errC {
+			c <- err
+		}
+		if outputDone != nil {
+			select {
+			case <-outputDone:
+			case <-ctx.Done():
+			}
+		}
+	})
+}
+
+// SetInput counts the items read by the decorated IOWorker. See `ItemStats`.
+func (v *BroadcasterIOWorker[K]) SetInput(input <-chan K) {
+	if input == nil {
+		v.IOWorker.SetInput(nil)
+		return
+	}
+	v.IOWorker.SetInput(diwo.Map(input, func(elem K) K {
+		v.items.in.Add(1)
+		return elem
+	}))
+}
+
+// ItemStats returns how many items the decorated IOWorker read and emitted.
+// Items emitted while no child reads the output are not counted.
+func (v *BroadcasterIOWorker[K]) ItemStats() ItemStats {
+	return v.items.stats()
+}
+
+// BufferStats returns how many items were dropped or spilled by the subscribers buffers.
+// It is always empty when no buffer is configured.
+func (v *BroadcasterIOWorker[K]) BufferStats() BufferStats {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	res := BufferStats{}
+	for _, out := range v.ports {
+		res = res.Add(out.stats())
+	}
+	return res
+}
+
+// QueueDepth returns how many emitted items the subscribers did not read yet, summed over the subscribers.
+// Spilled items are queued, dropped items are not. Items shared between the subscribers are only expected once.
+func (v *BroadcasterIOWorker[K]) QueueDepth() uint64 {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	depth := uint64(0)
+	for _, out := range v.ports {
+		if v.fanout.distributes() {
+			depth += out.queue.sharedDepth(out.emitted.Load(), out.stats().Dropped)
+		} else {
+			depth += out.queue.depth(out.emitted.Load(), out.stats().Dropped)
+		}
+	}
+	return depth
+}
+
+// Use decorates the IOWorker broadcast by `v` with `middleware`, the first one being the outermost.
+// It must be called before `SetInput` and `Output`.
+func (v *BroadcasterIOWorker[K]) Use(vertex IOWorkerVertex[K], middleware ...Middleware[K]) {
+	for _, m := range slices.Backward(middleware) {
+		v.IOWorker = m(vertex, v.IOWorker)
 	}
-	return v.broker.Subscribe()
 }
@@ -1,8 +1,18 @@
 package graph_test
 
 import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/benji-bou/diwo"
+	"github.com/benji-bou/lugh/core/graph"
 	"github.com/benji-bou/lugh/core/graph/graphtest"
 )
 
@@ -17,3 +27,549 @@ func TestSynchronizationWorker(t *testing.T) {
 		graphtest.TestWorkerChain(t, useSyncWorkerTest)
 	}
 }
+
+func TestBroadcasterBufferPolicies(t *testing.T) {
+	input := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	testCases := []struct {
+		policy graph.BufferPolicy
+		assert func(t *testing.T, received []int, stats graph.BufferStats)
+	}{
+		{
+			policy: graph.BufferDropNewest,
+			assert: func(t *testing.T, received []int, stats graph.BufferStats) {
+				t.Helper()
+				if stats.Dropped == 0 || len(received)+int(stats.Dropped) != len(input) {
+					t.Errorf("dropNewest: received %v with %d dropped", received, stats.Dropped)
+				}
+			},
+		},
+		{
+			policy: graph.BufferDropOldest,
+			assert: func(t *testing.T, received []int, stats graph.BufferStats) {
+				t.Helper()
+				if stats.Dropped == 0 || len(received)+int(stats.Dropped) != len(input) || received[len(received)-1] != 10 {
+					t.Errorf("dropOldest: received %v with %d dropped", received, stats.Dropped)
+				}
+			},
+		},
+		{
+			policy: graph.BufferSpill,
+			assert: func(t *testing.T, received []int, stats graph.BufferStats) {
+				t.Helper()
+				if stats.Spilled == 0 || !slices.Equal(received, input) {
+					t.Errorf("spill: received %v with %d spilled", received, stats.Spilled)
+				}
+			},
+		},
+		{
+			policy: graph.BufferDisk,
+			assert: func(t *testing.T, received []int, stats graph.BufferStats) {
+				t.Helper()
+				if int(stats.Spilled) != len(input) || !slices.Equal(received, input) {
+					t.Errorf("disk: received %v with %d spilled", received, stats.Spilled)
+				}
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			codec := graph.Codec[int](intCodec{})
+			broadcaster := graph.NewBroadcasterIOWorker(graphtest.SliceProducer(input...),
+				graph.WithBuffer(graph.BufferConfig[int]{Size: 2, Policy: tc.policy, SpillDir: t.TempDir(), SegmentSize: 32, Codec: codec}),
+			)
+			outputC := broadcaster.Output()
+			ctx := graph.NewContext(context.Background())
+			errC := broadcaster.Run(ctx)
+			ctx.Synchronize()
+			for err := range errC {
+				t.Fatalf("producer failed: %v", err)
+			}
+			// The producer is done, the subscriber starts reading only now.
+			received := []int{}
+			for elem := range outputC {
+				received = append(received, elem)
+			}
+			if !slices.IsSorted(received) {
+				t.Errorf("items should keep their order, got %v", received)
+			}
+			tc.assert(t, received, broadcaster.BufferStats())
+		})
+	}
+}
+
+func TestBroadcasterFanout(t *testing.T) {
+	input := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	testCases := []struct {
+		fanout graph.FanoutConfig[int]
+		buffer graph.BufferConfig[int]
+		assert func(t *testing.T, received [][]int)
+	}{
+		{
+			fanout: graph.FanoutConfig[int]{Mode: graph.FanoutRoundRobin},
+			assert: func(t *testing.T, received [][]int) {
+				t.Helper()
+				for _, items := range received {
+					if len(items) != len(input)/len(received) {
+						t.Errorf("roundRobin: each subscriber should receive its share, got %v", received)
+					}
+				}
+			},
+		},
+		{
+			fanout: graph.FanoutConfig[int]{Mode: graph.FanoutHash, Key: func(elem int) string { return strconv.Itoa(elem % 2) }},
+			buffer: graph.BufferConfig[int]{Size: 2},
+			assert: func(t *testing.T, received [][]int) {
+				t.Helper()
+				for _, items := range received {
+					if slices.ContainsFunc(items, func(elem int) bool { return elem%2 != items[0]%2 }) {
+						t.Errorf("hash: items with the same key should go to the same subscriber, got %v", received)
+					}
+				}
+			},
+		},
+		{
+			fanout: graph.FanoutConfig[int]{Mode: graph.FanoutFirstAvailable},
+			assert: func(*testing.T, [][]int) {},
+		},
+		{
+			fanout: graph.FanoutConfig[int]{Mode: graph.FanoutFirstAvailable},
+			buffer: graph.BufferConfig[int]{Size: 2},
+			assert: func(*testing.T, [][]int) {},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.fanout.Mode)+" buffer "+strconv.Itoa(tc.buffer.Size), func(t *testing.T) {
+			broadcaster := graph.NewBroadcasterIOWorker(graphtest.SliceProducer(input...), graph.WithFanout(tc.fanout), graph.WithBuffer(tc.buffer))
+			outputs := []<-chan int{broadcaster.Output(), broadcaster.Output(), broadcaster.Output()}
+			ctx := graph.NewContext(context.Background())
+			errC := broadcaster.Run(ctx)
+			ctx.Synchronize()
+			received := make([][]int, len(outputs))
+			wg := sync.WaitGroup{}
+			for index, outputC := range outputs {
+				wg.Go(func() {
+					for elem := range outputC {
+						received[index] = append(received[index], elem)
+					}
+				})
+			}
+			for err := range errC {
+				t.Fatalf("producer failed: %v", err)
+			}
+			wg.Wait()
+			if all := slices.Sorted(slices.Values(slices.Concat(received...))); !slices.Equal(all, input) {
+				t.Errorf("each item should be received exactly once, got %v", received)
+			}
+			tc.assert(t, received)
+		})
+	}
+}
+
+type intCodec struct{}
+
+func (intCodec) Encode(elem int) ([]byte, error) {
+	return []byte(strconv.Itoa(elem)), nil
+}
+
+func (intCodec) Decode(raw []byte) (int, error) {
+	return strconv.Atoi(string(raw))
+}
+
+func TestConcurrentWorker(t *testing.T) {
+	input := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for _, ordered := range []bool{false, true} {
+		t.Run("ordered "+strconv.FormatBool(ordered), func(t *testing.T) {
+			inFlight, maxInFlight := atomic.Int32{}, atomic.Int32{}
+			worker := graph.NewIOWorkerFromWorker(graph.WorkerFunc[int](func(_ context.Context, elem int, yield func(elem int) error) error {
+				current := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					prevMax := maxInFlight.Load()
+					if current <= prevMax || maxInFlight.CompareAndSwap(prevMax, current) {
+						break
+					}
+				}
+				// the smallest inputs are the slowest to finish
+				time.Sleep(time.Duration(len(input)-elem) * time.Millisecond)
+				return yield(elem)
+			}))
+			if err := graph.SetConcurrency(worker, 4, ordered); err != nil {
+				t.Fatalf("SetConcurrency failed: %v", err)
+			}
+			inputC := make(chan int)
+			worker.SetInput(inputC)
+			outputC := worker.Output()
+			ctx := graph.NewContext(context.Background())
+			worker.Run(ctx)
+			ctx.Synchronize()
+			go func() {
+				defer close(inputC)
+				for _, elem := range input {
+					inputC <- elem
+				}
+			}()
+			received := []int{}
+			for elem := range outputC {
+				received = append(received, elem)
+			}
+			if maxInFlight.Load() < 2 {
+				t.Errorf("inputs should be processed in parallel, max in flight %d", maxInFlight.Load())
+			}
+			if ordered && !slices.Equal(received, input) {
+				t.Errorf("outputs should follow the inputs order, got %v", received)
+			}
+			if !slices.Equal(slices.Sorted(slices.Values(received)), input) {
+				t.Errorf("all the inputs should be processed, got %v", received)
+			}
+		})
+	}
+}
+
+// mapCheckpoint records the outputs of the inputs in memory, keyed by input.
+type mapCheckpoint struct {
+	outputs map[int][]int
+	mux     sync.Mutex
+}
+
+func (m *mapCheckpoint) Outputs(input int) ([]int, bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	outputs, ok := m.outputs[input]
+	return outputs, ok
+}
+
+func (m *mapCheckpoint) Record(input int, outputs []int) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.outputs[input] = outputs
+	return nil
+}
+
+func TestCheckpoint(t *testing.T) {
+	input := []int{1, 2, 3, 4}
+	testCases := map[string]func(processed *[]int) graph.IOWorker[int]{
+		"worker": func(processed *[]int) graph.IOWorker[int] {
+			return graph.NewIOWorkerFromWorker(graph.WorkerFunc[int](func(_ context.Context, elem int, yield func(elem int) error) error {
+				*processed = append(*processed, elem)
+				if elem == 4 {
+					return errors.New("fail")
+				}
+				return yield(elem * 10)
+			}))
+		},
+	}
+	for name, newWorker := range testCases {
+		t.Run(name, func(t *testing.T) {
+			checkpoint := &mapCheckpoint{outputs: map[int][]int{2: {20, 21}}}
+			processed := []int{}
+			worker := newWorker(&processed)
+			if err := graph.SetCheckpoint[int](worker, checkpoint); err != nil {
+				t.Fatalf("SetCheckpoint failed: %v", err)
+			}
+			worker.SetInput(diwo.FromSlice(input))
+			outputC := worker.Output()
+			ctx := graph.NewContext(context.Background())
+			errC := worker.Run(ctx)
+			ctx.Synchronize()
+			go func() {
+				for range errC {
+				}
+			}()
+			received := []int{}
+			for elem := range outputC {
+				received = append(received, elem)
+			}
+			if !slices.Equal(processed, []int{1, 3, 4}) {
+				t.Errorf("the checkpointed input should be skipped, processed %v", processed)
+			}
+			if !slices.Equal(slices.Sorted(slices.Values(received)), []int{10, 20, 21, 30}) {
+				t.Errorf("the recorded outputs should be emitted again, got %v", received)
+			}
+			expected := map[int][]int{1: {10}, 2: {20, 21}, 3: {30}}
+			if !maps.EqualFunc(checkpoint.outputs, expected, slices.Equal) {
+				t.Errorf("only the inputs processed without error should be recorded, got %v", checkpoint.outputs)
+			}
+		})
+	}
+}
+
+// readAheadRunner reads all its inputs before emitting anything, like the gRPC client streaming its inputs to
+// the plugin server.
+func readAheadRunner(processed *[]int) graph.IOWorker[int] {
+	return graph.NewIOWorkerFromRunner(graph.RunnerFunc[int](func(_ context.Context, inputC <-chan int, yield func(elem int, err error) error) error {
+		read := []int{}
+		for elem := range inputC {
+			read = append(read, elem)
+		}
+		*processed = append(*processed, read...)
+		for _, elem := range read {
+			if err := yield(elem+1, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+func TestCheckpointReadAheadRunner(t *testing.T) {
+	checkpoint := &mapCheckpoint{outputs: map[int][]int{}}
+	for run := range 2 {
+		processed := []int{}
+		worker := graph.NewIOWorkerFromWorker(graph.WorkerFunc[int](func(_ context.Context, elem int, yield func(elem int) error) error {
+			processed = append(processed, elem)
+			return yield(elem * 10)
+		}))
+		if err := graph.SetCheckpoint[int](worker, checkpoint); err != nil {
+			t.Fatalf("SetCheckpoint failed: %v", err)
+		}
+		runnerProcessed := []int{}
+		runner := readAheadRunner(&runnerProcessed)
+		if err := graph.SetCheckpoint[int](runner, checkpoint); !errors.Is(err, graph.ErrCheckpointNotSupported) {
+			t.Fatalf("runners should not support checkpoints, got %v", err)
+		}
+		worker.SetInput(diwo.FromSlice([]int{1, 2, 3}))
+		runner.SetInput(worker.Output())
+		outputC := runner.Output()
+		ctx := graph.NewContext(context.Background())
+		worker.Run(ctx)
+		runner.Run(ctx)
+		ctx.Synchronize()
+		received := []int{}
+		for elem := range outputC {
+			received = append(received, elem)
+		}
+		if run == 1 && len(processed) > 0 {
+			t.Errorf("the resumed worker should replay its recorded outputs, processed %v", processed)
+		}
+		if !slices.Equal(runnerProcessed, []int{10, 20, 30}) || !slices.Equal(received, []int{11, 21, 31}) {
+			t.Errorf("run %d: the runner should process every input again, processed %v and emitted %v", run, runnerProcessed, received)
+		}
+	}
+}
+
+func TestCheckpointNotSupported(t *testing.T) {
+	producer := graph.NewIOWorkerFromProducer(graph.ProducerFunc[int](func(_ context.Context, _ func(elem int) error) error {
+		return nil
+	}))
+	if err := graph.SetCheckpoint[int](producer, &mapCheckpoint{}); !errors.Is(err, graph.ErrCheckpointNotSupported) {
+		t.Errorf("producers should not support checkpoints, got %v", err)
+	}
+}
+
+func TestNotifyDone(t *testing.T) {
+	worker := graph.NewIOWorkerFromWorker(graph.WorkerFunc[int](func(_ context.Context, elem int, yield func(elem int) error) error {
+		if elem == 2 {
+			return errors.New("failed")
+		}
+		return yield(elem * 10)
+	}))
+	if err := graph.SetConcurrency(worker, 2, true); err != nil {
+		t.Fatal(err)
+	}
+	var mux sync.Mutex
+	done := map[int]error{}
+	err := graph.NotifyDone(worker, func(input int, err error) {
+		mux.Lock()
+		defer mux.Unlock()
+		if _, ok := done[input]; ok {
+			t.Errorf("input %d is done twice", input)
+		}
+		done[input] = err
+	})
+	if err != nil {
+		t.Fatalf("NotifyDone failed: %v", err)
+	}
+	worker.SetInput(diwo.FromSlice([]int{1, 2, 3}))
+	outputC := worker.Output()
+	ctx := graph.NewContext(context.Background())
+	errC := worker.Run(ctx)
+	ctx.Synchronize()
+	go func() {
+		for range errC {
+		}
+	}()
+	for range outputC {
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if len(done) != 3 || done[1] != nil || done[2] == nil || done[3] != nil {
+		t.Errorf("expected each input done once with its error, got %v", done)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	input := []int{1, 2, 3, 4, 5}
+	testCases := map[string]graph.IOWorker[int]{
+		"worker": graph.NewIOWorkerFromWorker(graph.WorkerFunc[int](func(_ context.Context, elem int, yield func(elem int) error) error {
+			return yield(elem)
+		})),
+		"runner": graph.NewIOWorkerFromRunner(graph.RunnerFunc[int](func(_ context.Context, inputC <-chan int, yield func(elem int, err error) error) error {
+			for elem := range inputC {
+				if err := yield(elem, nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		})),
+	}
+	for name, worker := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if err := graph.SetRateLimit(worker, graph.RateLimit{PerSecond: 100, Burst: 1}); err != nil {
+				t.Fatalf("SetRateLimit failed: %v", err)
+			}
+			worker.SetInput(diwo.FromSlice(input))
+			outputC := worker.Output()
+			ctx := graph.NewContext(context.Background())
+			worker.Run(ctx)
+			ctx.Synchronize()
+			start := time.Now()
+			received := []int{}
+			for elem := range outputC {
+				received = append(received, elem)
+			}
+			if !slices.Equal(received, input) {
+				t.Errorf("all the inputs should be processed, got %v", received)
+			}
+			if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+				t.Errorf("5 inputs at 100 per second should take at least 40ms, took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	input := []int{1, 2, 3}
+	release := make(chan struct{})
+	defer close(release)
+	testCases := map[string]graph.IOWorker[int]{
+		// The stuck worker ignores its context, it is abandoned once timed out.
+		"worker": graph.NewIOWorkerFromWorker(graph.WorkerFunc[int](func(_ context.Context, elem int, yield func(elem int) error) error {
+			if elem == 2 {
+				<-release
+			}
+			return yield(elem)
+		})),
+	}
+	for name, worker := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if err := graph.SetTimeout(worker, 20*time.Millisecond); err != nil {
+				t.Fatalf("SetTimeout failed: %v", err)
+			}
+			if err := graph.SetErrorPolicy(worker, graph.ErrorPolicy[int]{Action: graph.ErrorReport}); err != nil {
+				t.Fatalf("SetErrorPolicy failed: %v", err)
+			}
+			worker.SetInput(diwo.FromSlice(input))
+			outputC := worker.Output()
+			ctx := graph.NewContext(context.Background())
+			errC := worker.Run(ctx)
+			ctx.Synchronize()
+			errs := []error{}
+			errsDone := make(chan struct{})
+			go func() {
+				defer close(errsDone)
+				for err := range errC {
+					errs = append(errs, err)
+				}
+			}()
+			received := []int{}
+			for elem := range outputC {
+				received = append(received, elem)
+			}
+			<-errsDone
+			timeouts := 0
+			for _, err := range errs {
+				if !errors.Is(err, graph.ErrItemTimeout) {
+					t.Errorf("the error should be a timeout, got %v", err)
+				}
+				timeouts++
+			}
+			if !slices.Equal(received, []int{1, 3}) {
+				t.Errorf("the timed out input should not be emitted, got %v", received)
+			}
+			if timeouts != 1 {
+				t.Errorf("a single input should time out, got %d errors", timeouts)
+			}
+		})
+	}
+}
+
+func TestTimeoutConsumer(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	consumed := []int{}
+	consumer := graph.NewIOWorkerFromConsumer(graph.ConsumerFunc[int](func(_ context.Context, elem int) error {
+		if elem == 2 {
+			<-release
+		}
+		consumed = append(consumed, elem)
+		return nil
+	}))
+	if err := graph.SetTimeout(consumer, 20*time.Millisecond); err != nil {
+		t.Fatalf("SetTimeout failed: %v", err)
+	}
+	deadLetters := []int{}
+	policy := graph.ErrorPolicy[int]{Action: graph.ErrorDeadLetter, DeadLetter: "dlq", DeadLetterItem: func(input int, err error) int {
+		if !errors.Is(err, graph.ErrItemTimeout) {
+			t.Errorf("the error should be a timeout, got %v", err)
+		}
+		return input
+	}}
+	if err := graph.SetErrorPolicy(consumer, policy); err != nil {
+		t.Fatalf("SetErrorPolicy failed: %v", err)
+	}
+	consumer.SetInput(diwo.FromSlice([]int{1, 2, 3}))
+	deadLetterC := consumer.(graph.DeadLetterEmitter[int]).DeadLetters()
+	ctx := graph.NewContext(context.Background())
+	errC := consumer.Run(ctx)
+	ctx.Synchronize()
+	for elem := range deadLetterC {
+		deadLetters = append(deadLetters, elem)
+	}
+	for err := range errC {
+		t.Errorf("the timed out input should go to the dead letter stage, got error %v", err)
+	}
+	if !slices.Equal(consumed, []int{1, 3}) || !slices.Equal(deadLetters, []int{2}) {
+		t.Errorf("the timed out input should be dead lettered, consumed %v and dead lettered %v", consumed, deadLetters)
+	}
+}
+
+// timeoutRunner bounds the time spent on each input itself, like the gRPC plugins forwarding it to the plugin server.
+type timeoutRunner struct {
+	graph.RunnerFunc[int]
+	timeout time.Duration
+}
+
+func (tr *timeoutRunner) SetTimeout(timeout time.Duration) error {
+	tr.timeout = timeout
+	return nil
+}
+
+func TestTimeoutRunner(t *testing.T) {
+	runner := &timeoutRunner{}
+	if err := graph.SetTimeout(graph.NewIOWorkerFromRunner[int](runner), time.Second); err != nil || runner.timeout != time.Second {
+		t.Errorf("the timeout should be forwarded to the runner, got %s: %v", runner.timeout, err)
+	}
+	// The runner reads its inputs ahead, the graph can't tell when it is done with one.
+	readAhead := readAheadRunner(&[]int{})
+	if err := graph.SetTimeout(readAhead, time.Second); !errors.Is(err, graph.ErrTimeoutNotSupported) {
+		t.Errorf("runners should not support timeouts unless they bound their inputs themselves, got %v", err)
+	}
+	if err := graph.SetTimeout(readAhead, 0); err != nil {
+		t.Errorf("a zero timeout should always be accepted, got %v", err)
+	}
+}
+
+func TestRateLimitNotSupported(t *testing.T) {
+	producer := graph.NewIOWorkerFromProducer(graph.ProducerFunc[int](func(_ context.Context, _ func(elem int) error) error {
+		return nil
+	}))
+	if err := graph.SetRateLimit(producer, graph.RateLimit{PerSecond: 1}); !errors.Is(err, graph.ErrRateLimitNotSupported) {
+		t.Errorf("producers should not support rate limits, got %v", err)
+	}
+	if err := graph.SetTimeout(producer, time.Second); !errors.Is(err, graph.ErrTimeoutNotSupported) {
+		t.Errorf("producers should not support timeouts, got %v", err)
+	}
+	if err := graph.SetRateLimit(producer, graph.RateLimit{}); err != nil {
+		t.Errorf("a zero rate limit should always be accepted, got %v", err)
+	}
+}
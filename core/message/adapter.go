@@ -0,0 +1,282 @@
+package message
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/benji-bou/diwo"
+	"github.com/benji-bou/lugh/core/graph"
+)
+
+// FromWorker adapts a Worker handling raw payloads. Each output inherits the headers of the input that produced it.
+func FromWorker(worker graph.Worker[[]byte]) graph.Worker[Message] {
+	return graph.WorkerFunc[Message](func(ctx context.Context, input Message, yield func(elem Message) error) error {
+		return worker.Work(ctx, input.Payload, func(elem []byte) error {
+			return yield(input.Derive(elem))
+		})
+	})
+}
+
+// FromProducer adapts a Producer of raw payloads. Each output starts a new trace.
+func FromProducer(producer graph.Producer[[]byte]) graph.Producer[Message] {
+	return graph.ProducerFunc[Message](func(ctx context.Context, yield func(elem Message) error) error {
+		return producer.Produce(ctx, func(elem []byte) error {
+			return yield(New(elem))
+		})
+	})
+}
+
+// FromConsumer adapts a Consumer of raw payloads. The headers are discarded.
+func FromConsumer(consumer graph.Consumer[[]byte]) graph.Consumer[Message] {
+	return graph.ConsumerFunc[Message](func(ctx context.Context, input Message) error {
+		return consumer.Consume(ctx, input.Payload)
+	})
+}
+
+// recentInputs is the number of inputs a streaming plugin reads ahead of the one it handles that `lastHeaders` keeps.
+const recentInputs = 64
+
+// lastHeaders keeps the headers of the last input read by a streaming plugin.
+// Streaming plugins do not tell which input an output comes from, their outputs inherit the headers of the last input.
+// Outputs emitted before any input start a new trace.
+type lastHeaders struct {
+	headers atomic.Pointer[Headers]
+	recent  []Message
+	mux     sync.Mutex
+}
+
+func (lh *lastHeaders) payloads(input <-chan Message) <-chan []byte {
+	if input == nil {
+		return nil
+	}
+	return diwo.Map(input, func(elem Message) []byte {
+		lh.headers.Store(&elem.Headers)
+		lh.mux.Lock()
+		defer lh.mux.Unlock()
+		if len(lh.recent) == recentInputs {
+			lh.recent = lh.recent[1:]
+		}
+		lh.recent = append(lh.recent, elem)
+		return elem.Payload
+	})
+}
+
+// input returns the recent input with `payload`, the plugin may have read the next inputs already.
+// An input that is not found anymore gets the headers of the last input.
+func (lh *lastHeaders) input(payload []byte) Message {
+	lh.mux.Lock()
+	defer lh.mux.Unlock()
+	for _, elem := range slices.Backward(lh.recent) {
+		if bytes.Equal(elem.Payload, payload) {
+			return Message{Payload: payload, Headers: elem.Headers.Clone()}
+		}
+	}
+	return lh.message(payload)
+}
+
+func (lh *lastHeaders) message(payload []byte) Message {
+	if headers := lh.headers.Load(); headers != nil {
+		return Message{Payload: payload, Headers: headers.Clone()}
+	}
+	return New(payload)
+}
+
+type runner struct {
+	runner graph.Runner[[]byte]
+}
+
+// FromRunner adapts a Runner of raw payloads. See `lastHeaders` for the headers of its outputs.
+func FromRunner(r graph.Runner[[]byte]) graph.Runner[Message] {
+	return runner{runner: r}
+}
+
+func (r runner) Run(ctx context.Context, input <-chan Message, yield func(elem Message, err error) error) error {
+	last := &lastHeaders{}
+	return r.runner.Run(ctx, last.payloads(input), func(elem []byte, err error) error {
+		if err != nil {
+			return yield(Message{}, err)
+		}
+		return yield(last.message(elem), nil)
+	})
+}
+
+func (r runner) Kind() graph.Kind {
+	return graph.KindOf(r.runner)
+}
+
+func (r runner) SetConcurrency(concurrency int, ordered bool) error {
+	return graph.SetConcurrency(r.runner, concurrency, ordered)
+}
+
+func (r runner) SetRetry(policy graph.RetryPolicy) error {
+	return graph.SetRetry(r.runner, policy)
+}
+
+func (r runner) SetTimeout(timeout time.Duration) error {
+	return graph.SetTimeout(r.runner, timeout)
+}
+
+type ioWorker struct {
+	worker      graph.IOWorker[[]byte]
+	last        lastHeaders
+	output      func() <-chan Message
+	deadLetters func() <-chan Message
+}
+
+// FromIOWorker adapts an IOWorker of raw payloads. See `lastHeaders` for the headers of its outputs.
+// The error policy, the dead letters and the checkpoint are forwarded to the adapted IOWorker, its failing and
+// checkpointed inputs are matched to the recent inputs by payload.
+func FromIOWorker(worker graph.IOWorker[[]byte]) graph.IOWorker[Message] {
+	w := &ioWorker{worker: worker}
+	w.output = sync.OnceValue(func() <-chan Message {
+		return diwo.Map(w.worker.Output(), w.last.message)
+	})
+	w.deadLetters = sync.OnceValue(func() <-chan Message {
+		emitter, ok := w.worker.(graph.DeadLetterEmitter[[]byte])
+		if !ok {
+			return nil
+		}
+		deadLetterC := emitter.DeadLetters()
+		if deadLetterC == nil {
+			return nil
+		}
+		return diwo.Map(deadLetterC, w.deadLetter)
+	})
+	return w
+}
+
+func (w *ioWorker) Run(ctx graph.SyncContext) <-chan error {
+	return w.worker.Run(ctx)
+}
+
+func (w *ioWorker) SetInput(input <-chan Message) {
+	w.worker.SetInput(w.last.payloads(input))
+}
+
+func (w *ioWorker) Output() <-chan Message {
+	return w.output()
+}
+
+func (w *ioWorker) Kind() graph.Kind {
+	return graph.KindOf(w.worker)
+}
+
+func (w *ioWorker) SetConcurrency(concurrency int, ordered bool) error {
+	return graph.SetConcurrency(w.worker, concurrency, ordered)
+}
+
+func (w *ioWorker) SetRetry(policy graph.RetryPolicy) error {
+	return graph.SetRetry(w.worker, policy)
+}
+
+func (w *ioWorker) SetTimeout(timeout time.Duration) error {
+	return graph.SetTimeout(w.worker, timeout)
+}
+
+func (w *ioWorker) SetRateLimit(limit graph.RateLimit) error {
+	return graph.SetRateLimit(w.worker, limit)
+}
+
+// SetErrorPolicy forwards the policy to the adapted IOWorker. Its dead letters are the encoded messages built by
+// `policy.DeadLetterItem`, decoded by `DeadLetters`.
+func (w *ioWorker) SetErrorPolicy(policy graph.ErrorPolicy[Message]) error {
+	return graph.SetErrorPolicy(w.worker, graph.ErrorPolicy[[]byte]{
+		RetryPolicy: policy.RetryPolicy,
+		Action:      policy.Action,
+		DeadLetter:  policy.DeadLetter,
+		DeadLetterItem: func(input []byte, err error) []byte {
+			item := w.last.input(input)
+			if policy.DeadLetterItem != nil {
+				item = policy.DeadLetterItem(item, err)
+			}
+			raw, err := Codec{}.Encode(item)
+			if err != nil {
+				return input
+			}
+			return raw
+		},
+	})
+}
+
+func (w *ioWorker) DeadLetters() <-chan Message {
+	return w.deadLetters()
+}
+
+func (w *ioWorker) deadLetter(raw []byte) Message {
+	item, err := Codec{}.Decode(raw)
+	if err != nil {
+		return w.last.input(raw)
+	}
+	return item
+}
+
+func (w *ioWorker) SetCheckpoint(checkpoint graph.Checkpoint[Message]) error {
+	return graph.SetCheckpoint[[]byte](w.worker, payloadCheckpoint{checkpoint: checkpoint, last: &w.last})
+}
+
+// NotifyDone calls `fn` with the recent input message carrying the payload the adapted IOWorker is done with.
+func (w *ioWorker) NotifyDone(fn func(input Message, err error)) error {
+	return graph.NotifyDone(w.worker, func(input []byte, err error) {
+		fn(w.last.input(input), err)
+	})
+}
+
+// payloadCheckpoint records the inputs of an adapted IOWorker as the recent input messages with the same payload.
+type payloadCheckpoint struct {
+	checkpoint graph.Checkpoint[Message]
+	last       *lastHeaders
+}
+
+func (pc payloadCheckpoint) Outputs(input []byte) ([][]byte, bool) {
+	outputs, ok := pc.checkpoint.Outputs(pc.last.input(input))
+	if !ok {
+		return nil, false
+	}
+	res := make([][]byte, 0, len(outputs))
+	for _, output := range outputs {
+		res = append(res, output.Payload)
+	}
+	return res, true
+}
+
+func (pc payloadCheckpoint) Record(input []byte, outputs [][]byte) error {
+	message := pc.last.input(input)
+	res := make([]Message, 0, len(outputs))
+	for _, output := range outputs {
+		res = append(res, message.Derive(output))
+	}
+	return pc.checkpoint.Record(message, res)
+}
+
+// IOWorker converts a plugin handling either messages or raw payloads to an IOWorker of messages.
+// It returns false when the plugin is neither a IOWorker, Worker, Producer, Consumer nor Runner.
+func IOWorker(plugin any) (graph.IOWorker[Message], bool) {
+	switch p := plugin.(type) {
+	case graph.IOWorker[Message]:
+		return p, true
+	case graph.Worker[Message]:
+		return graph.NewIOWorkerFromWorker(p), true
+	case graph.Producer[Message]:
+		return graph.NewIOWorkerFromProducer(p), true
+	case graph.Consumer[Message]:
+		return graph.NewIOWorkerFromConsumer(p), true
+	case graph.Runner[Message]:
+		return graph.NewIOWorkerFromRunner(p), true
+	case graph.IOWorker[[]byte]:
+		return FromIOWorker(p), true
+	case graph.Worker[[]byte]:
+		return graph.NewIOWorkerFromWorker(FromWorker(p)), true
+	case graph.Producer[[]byte]:
+		return graph.NewIOWorkerFromProducer(FromProducer(p)), true
+	case graph.Consumer[[]byte]:
+		return graph.NewIOWorkerFromConsumer(FromConsumer(p)), true
+	case graph.Runner[[]byte]:
+		return graph.NewIOWorkerFromRunner(FromRunner(p)), true
+	default:
+		return nil, false
+	}
+}
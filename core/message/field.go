@@ -0,0 +1,73 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrUnknownField = errors.New("unknown message field")
+
+// Field returns the accessor of a field of a message, described by `path`:
+// `payload` (the default), the headers `id`, `source`, `traceId`, `runId` and `contentType`,
+// `values.<key>` for a user-defined header, or `json.<key>.<key>...` for a value of a JSON payload.
+// A missing field is empty.
+func Field(path string) (func(m Message) string, error) {
+	switch head, tail, _ := strings.Cut(path, "."); head {
+	case "", "payload":
+		return func(m Message) string { return string(m.Payload) }, nil
+	case "id":
+		return func(m Message) string { return m.Headers.ID }, nil
+	case "source":
+		return func(m Message) string { return m.Headers.Source }, nil
+	case "traceId":
+		return func(m Message) string { return m.Headers.TraceID }, nil
+	case "runId":
+		return func(m Message) string { return m.Headers.RunID }, nil
+	case "contentType":
+		return func(m Message) string { return m.Headers.ContentType }, nil
+	case "values":
+		return func(m Message) string { return m.Get(tail) }, nil
+	case "json":
+		keys := strings.Split(tail, ".")
+		return func(m Message) string { return jsonField(m.Payload, keys) }, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownField, path)
+	}
+}
+
+// jsonField returns the value at `keys` in the JSON document `raw`. Strings are returned unquoted, other values in JSON.
+func jsonField(raw []byte, keys []string) string {
+	var node any
+	if err := json.Unmarshal(raw, &node); err != nil {
+		return ""
+	}
+	for _, key := range keys {
+		switch n := node.(type) {
+		case map[string]any:
+			node = n[key]
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(n) {
+				return ""
+			}
+			node = n[index]
+		default:
+			return ""
+		}
+	}
+	switch n := node.(type) {
+	case nil:
+		return ""
+	case string:
+		return n
+	default:
+		value, err := json.Marshal(n)
+		if err != nil {
+			return ""
+		}
+		return string(value)
+	}
+}
@@ -0,0 +1,195 @@
+// Package message defines the envelope flowing between the stages of a pipeline.
+// A Message carries the raw payload produced by a plugin and the headers describing where it comes from.
+// Plugins only handling []byte keep working: the adapters of this package wrap them and propagate the headers
+// of the input to every output.
+package message
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/benji-bou/lugh/helper"
+	"github.com/google/uuid"
+)
+
+// Headers describes the origin of a Message.
+// `Source` is the last stage which emitted the message and `Timestamp` when it did, `TraceID` correlates all the
+// messages derived from the same original input, `RunID` identifies the pipeline run. `Values` holds user-defined key/values.
+// `TraceID` and `SpanID` are W3C trace context identifiers, `SpanID` is the span of the stage which handled the message last.
+// `ID` is derived from the payload and the ids of the messages it descends from, so that a rerun of the same
+// template on the same inputs gives the same ids. It identifies the messages in the checkpoints of a run.
+// `Port` is the named output of the stage a message is emitted on, the default output if empty. The stage children
+// read it with `parents: [stage.port]`, it is cleared once the message is routed.
+type Headers struct {
+	ID          string            `json:"id,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	TraceID     string            `json:"traceId,omitempty"`
+	SpanID      string            `json:"spanId,omitempty"`
+	RunID       string            `json:"runId,omitempty"`
+	Timestamp   time.Time         `json:"timestamp,omitzero"`
+	ContentType string            `json:"contentType,omitempty"`
+	Values      map[string]string `json:"values,omitempty"`
+	Port        string            `json:"port,omitempty"`
+}
+
+// Clone returns a deep copy of the headers. The `Values` map is never shared between two messages.
+func (h Headers) Clone() Headers {
+	h.Values = maps.Clone(h.Values)
+	return h
+}
+
+type Message struct {
+	Payload []byte  `json:"payload"`
+	Headers Headers `json:"headers"`
+}
+
+type Option = helper.Option[Message]
+
+func WithSource(source string) Option {
+	return func(configure *Message) {
+		configure.Headers.Source = source
+	}
+}
+
+// WithPort emits the message on the named output `port` of the stage.
+func WithPort(port string) Option {
+	return func(configure *Message) {
+		configure.Headers.Port = port
+	}
+}
+
+func WithID(id string) Option {
+	return func(configure *Message) {
+		configure.Headers.ID = id
+	}
+}
+
+func WithTraceID(traceID string) Option {
+	return func(configure *Message) {
+		configure.Headers.TraceID = traceID
+	}
+}
+
+func WithRunID(runID string) Option {
+	return func(configure *Message) {
+		configure.Headers.RunID = runID
+	}
+}
+
+func WithContentType(contentType string) Option {
+	return func(configure *Message) {
+		configure.Headers.ContentType = contentType
+	}
+}
+
+func WithValue(key, value string) Option {
+	return func(configure *Message) {
+		if configure.Headers.Values == nil {
+			configure.Headers.Values = make(map[string]string)
+		}
+		configure.Headers.Values[key] = value
+	}
+}
+
+func WithHeaders(headers Headers) Option {
+	return func(configure *Message) {
+		configure.Headers = headers.Clone()
+	}
+}
+
+// NewTraceID returns a random trace id, 32 hexadecimal characters as in the W3C trace context.
+func NewTraceID() string {
+	id := uuid.New()
+	return hex.EncodeToString(id[:])
+}
+
+// NewID returns the id of a message emitted by `source` with `payload`, descending from the message `parentID`.
+func NewID(parentID, source string, payload []byte) string {
+	hash := sha256.New()
+	for _, part := range [][]byte{[]byte(parentID), []byte(source), payload} {
+		fmt.Fprintf(hash, "%d:", len(part))
+		hash.Write(part)
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// New creates a message starting a new trace. Its id only depends on its payload.
+func New(payload []byte, opt ...Option) Message {
+	return helper.Configure(Message{
+		Payload: payload,
+		Headers: Headers{ID: NewID("", "", payload), TraceID: NewTraceID(), Timestamp: time.Now()},
+	}, opt...)
+}
+
+// Port returns the output port `m` is emitted on. See `graph.WithPorts`.
+func Port(m Message) string {
+	return m.Headers.Port
+}
+
+// Derive creates a message with a new payload inheriting the headers of `m`.
+func (m Message) Derive(payload []byte) Message {
+	return Message{Payload: payload, Headers: m.Headers.Clone()}
+}
+
+// Stamp returns a mapper recording that the messages are emitted by the stage `source` during the run `runID`.
+// Messages without trace id start a new trace. It is applied on the output of each stage of a template.
+// The id of the message is derived from the stage, the payload and the id it inherited from its input.
+// The port is cleared, it is only meaningful on the output of the stage which set it. See `Port`.
+func Stamp(source, runID string) func(elem Message) Message {
+	return func(elem Message) Message {
+		elem.Headers.ID = NewID(elem.Headers.ID, source, elem.Payload)
+		elem.Headers.Port = ""
+		elem.Headers.Source = source
+		elem.Headers.Timestamp = time.Now()
+		if runID != "" {
+			elem.Headers.RunID = runID
+		}
+		if elem.Headers.TraceID == "" {
+			elem.Headers.TraceID = NewTraceID()
+		}
+		return elem
+	}
+}
+
+const (
+	// HeaderError is the user-defined header holding the error of a message sent to a dead letter stage.
+	HeaderError = "error"
+	// HeaderErrorSource is the user-defined header holding the stage which failed to process a dead letter message.
+	HeaderErrorSource = "errorSource"
+)
+
+// DeadLetter returns the builder of the messages sent to the dead letter stage of `source`.
+// The failing message is forwarded with its error in the `HeaderError` header.
+func DeadLetter(source string) func(input Message, err error) Message {
+	return func(input Message, err error) Message {
+		return helper.Configure(input.Derive(input.Payload), WithValue(HeaderError, err.Error()), WithValue(HeaderErrorSource, source))
+	}
+}
+
+// Get returns the value of the user-defined header `key`.
+func (m Message) Get(key string) string {
+	return m.Headers.Values[key]
+}
+
+// Codec encodes messages in JSON, it is used to spill messages to disk.
+type Codec struct{}
+
+func (Codec) Encode(elem Message) ([]byte, error) {
+	raw, err := json.Marshal(elem)
+	if err != nil {
+		return nil, fmt.Errorf("encode message: %w", err)
+	}
+	return raw, nil
+}
+
+func (Codec) Decode(raw []byte) (Message, error) {
+	res := Message{}
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return Message{}, fmt.Errorf("decode message: %w", err)
+	}
+	return res, nil
+}
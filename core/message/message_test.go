@@ -0,0 +1,177 @@
+package message_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/benji-bou/diwo"
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
+)
+
+func TestLegacyPluginsPropagateHeaders(t *testing.T) {
+	split := graph.WorkerFunc[[]byte](func(_ context.Context, input []byte, yield func(elem []byte) error) error {
+		for _, part := range bytes.Split(input, []byte(",")) {
+			if err := yield(part); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	upper := graph.RunnerFunc[[]byte](func(_ context.Context, input <-chan []byte, yield func(elem []byte, err error) error) error {
+		for elem := range input {
+			if err := yield(bytes.ToUpper(elem), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	testCases := map[string]any{"worker": split, "runner": upper}
+	for name, plugin := range testCases {
+		t.Run(name, func(t *testing.T) {
+			worker, ok := message.IOWorker(plugin)
+			if !ok {
+				t.Fatalf("%T is not adapted", plugin)
+			}
+			vertex := graph.NewIOWorkerVertex("stage", nil, worker, graph.WithVertexOutputMap(message.Stamp("stage", "run")))
+			input := message.New([]byte("a,b"), message.WithSource("input"), message.WithValue("target", "example.com"))
+			vertex.SetInput(diwo.Once(input))
+			outputC := vertex.Output()
+			ctx := graph.NewContext(context.Background())
+			errC := vertex.Run(ctx)
+			ctx.Synchronize()
+			go func() {
+				for err := range errC {
+					t.Errorf("unexpected error %v", err)
+				}
+			}()
+			received := 0
+			for output := range outputC {
+				received++
+				if output.Headers.Source != "stage" || output.Headers.RunID != "run" {
+					t.Errorf("output %s not stamped: %+v", output.Payload, output.Headers)
+				}
+				if output.Headers.TraceID != input.Headers.TraceID || output.Get("target") != "example.com" {
+					t.Errorf("output %s lost the input headers: %+v", output.Payload, output.Headers)
+				}
+			}
+			if received == 0 {
+				t.Error("no output received")
+			}
+		})
+	}
+}
+
+// memoryCheckpoint records the outputs of each input by message id.
+type memoryCheckpoint map[string][]message.Message
+
+func (mc memoryCheckpoint) Outputs(input message.Message) ([]message.Message, bool) {
+	outputs, ok := mc[input.Headers.ID]
+	return outputs, ok
+}
+
+func (mc memoryCheckpoint) Record(input message.Message, outputs []message.Message) error {
+	mc[input.Headers.ID] = outputs
+	return nil
+}
+
+func TestAdaptedIOWorkerErrorPolicy(t *testing.T) {
+	failing := graph.NewIOWorkerFromWorker(graph.WorkerFunc[[]byte](func(_ context.Context, input []byte, yield func(elem []byte) error) error {
+		if string(input) == "fail" {
+			return errors.New("failed")
+		}
+		return yield(bytes.ToUpper(input))
+	}))
+	worker, ok := message.IOWorker(failing)
+	if !ok {
+		t.Fatalf("%T is not adapted", failing)
+	}
+	policy := graph.ErrorPolicy[message.Message]{Action: graph.ErrorDeadLetter, DeadLetter: "dlq", DeadLetterItem: message.DeadLetter("stage")}
+	if err := graph.SetErrorPolicy(worker, policy); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint := memoryCheckpoint{}
+	if err := graph.SetCheckpoint[message.Message](worker, checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	inputs := []message.Message{
+		message.New([]byte("ok"), message.WithValue("target", "a")),
+		message.New([]byte("fail"), message.WithValue("target", "b")),
+	}
+	worker.SetInput(diwo.FromSlice(inputs))
+	deadLetterC := worker.(graph.DeadLetterEmitter[message.Message]).DeadLetters()
+	outputC := worker.Output()
+	ctx := graph.NewContext(context.Background())
+	errC := worker.Run(ctx)
+	ctx.Synchronize()
+	go func() {
+		for err := range errC {
+			t.Errorf("unexpected error %v", err)
+		}
+	}()
+	deadLetters := []message.Message{}
+	deadLettersDone := make(chan struct{})
+	go func() {
+		defer close(deadLettersDone)
+		for deadLetter := range deadLetterC {
+			deadLetters = append(deadLetters, deadLetter)
+		}
+	}()
+	for output := range outputC {
+		if string(output.Payload) != "OK" {
+			t.Errorf("unexpected output %s", output.Payload)
+		}
+	}
+	<-deadLettersDone
+	if len(deadLetters) != 1 || string(deadLetters[0].Payload) != "fail" || deadLetters[0].Get("target") != "b" ||
+		deadLetters[0].Get(message.HeaderError) != "failed" || deadLetters[0].Get(message.HeaderErrorSource) != "stage" {
+		t.Errorf("expected the failing input as dead letter, got %+v", deadLetters)
+	}
+	if outputs, ok := checkpoint[inputs[0].Headers.ID]; !ok || len(outputs) != 1 || string(outputs[0].Payload) != "OK" {
+		t.Errorf("expected the processed input to be checkpointed, got %+v", checkpoint)
+	}
+}
+
+func TestCodec(t *testing.T) {
+	codec := message.Codec{}
+	msg := message.New([]byte("payload"), message.WithContentType("text/plain"), message.WithValue("key", "value"))
+	raw, err := codec.Encode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := codec.Decode(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.Payload, msg.Payload) || decoded.Headers.TraceID != msg.Headers.TraceID ||
+		decoded.Headers.ContentType != "text/plain" || decoded.Get("key") != "value" || !decoded.Headers.Timestamp.Equal(msg.Headers.Timestamp) {
+		t.Errorf("decoded %+v, expected %+v", decoded, msg)
+	}
+}
+
+func TestField(t *testing.T) {
+	msg := message.New([]byte(`{"target":{"host":"example.com","ports":[80,443]}}`), message.WithSource("scan"), message.WithValue("key", "value"))
+	testCases := map[string]string{
+		"":                    string(msg.Payload),
+		"source":              "scan",
+		"values.key":          "value",
+		"json.target.host":    "example.com",
+		"json.target.ports":   "[80,443]",
+		"json.target.ports.1": "443",
+		"json.missing":        "",
+	}
+	for path, expected := range testCases {
+		field, err := message.Field(path)
+		if err != nil {
+			t.Fatalf("field %s: %v", path, err)
+		}
+		if value := field(msg); value != expected {
+			t.Errorf("field %s: expected %q, got %q", path, expected, value)
+		}
+	}
+	if _, err := message.Field("unknown"); !errors.Is(err, message.ErrUnknownField) {
+		t.Errorf("unknown field should fail, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+// Package metrics instruments the stages of a pipeline with Prometheus collectors.
+// `Middleware` decorates the IOWorker of every vertex of a `graph.IO`, see `graph.WithMiddleware`.
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/benji-bou/diwo"
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "lugh"
+
+var stageLabels = []string{"stage", "plugin"}
+
+// Metrics holds the collectors of every instrumented stage, labelled by stage and plugin name.
+type Metrics struct {
+	itemsIn    *prometheus.CounterVec
+	itemsOut   *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	latency    *prometheus.HistogramVec
+	queueDepth *prometheus.Desc
+	restarts   *prometheus.Desc
+	stages     map[stage]probes
+	mux        sync.Mutex
+}
+
+type stage struct {
+	name   string
+	plugin string
+}
+
+// probes are read when the metrics are collected.
+type probes struct {
+	queueDepth func() uint64
+	restarts   func() uint64
+}
+
+// New creates the stage collectors and registers them on `registerer`.
+func New(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		itemsIn: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "stage_items_in_total", Help: "Items read by the stage.",
+		}, stageLabels),
+		itemsOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "stage_items_out_total", Help: "Items emitted by the stage.",
+		}, stageLabels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "stage_errors_total", Help: "Errors reported by the stage.",
+		}, stageLabels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "stage_latency_seconds",
+			Help:    "Time between an item emitted by the stage and the last item it read.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 10), //nolint:mnd // from 1ms to ~4min
+		}, stageLabels),
+		queueDepth: prometheus.NewDesc(prometheus.BuildFQName(namespace, "stage", "queue_depth"),
+			"Items emitted by the stage its children did not read yet.", stageLabels, nil),
+		restarts: prometheus.NewDesc(prometheus.BuildFQName(namespace, "stage", "restarts_total"),
+			"Restarts of the stage plugin.", stageLabels, nil),
+		stages: make(map[stage]probes),
+	}
+	if err := registerer.Register(m); err != nil {
+		return nil, fmt.Errorf("register stage metrics: %w", err)
+	}
+	return m, nil
+}
+
+func (m *Metrics) Describe(c chan<- *prometheus.Desc) {
+	m.itemsIn.Describe(c)
+	m.itemsOut.Describe(c)
+	m.errors.Describe(c)
+	m.latency.Describe(c)
+	c <- m.queueDepth
+	c <- m.restarts
+}
+
+func (m *Metrics) Collect(c chan<- prometheus.Metric) {
+	m.itemsIn.Collect(c)
+	m.itemsOut.Collect(c)
+	m.errors.Collect(c)
+	m.latency.Collect(c)
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for s, p := range m.stages {
+		c <- prometheus.MustNewConstMetric(m.queueDepth, prometheus.GaugeValue, float64(p.queueDepth()), s.name, s.plugin)
+		c <- prometheus.MustNewConstMetric(m.restarts, prometheus.CounterValue, float64(p.restarts()), s.name, s.plugin)
+	}
+}
+
+// Middleware instruments the IOWorker of each vertex. A stage instrumented again, by a later run, replaces the previous one.
+func Middleware[K any](m *Metrics) graph.Middleware[K] {
+	return func(vertex graph.IOWorkerVertex[K], worker graph.IOWorker[K]) graph.IOWorker[K] {
+		s := stage{name: vertex.GetName(), plugin: vertex.GetPlugin()}
+		restarts := func() uint64 { return graph.RestartsOf(worker) }
+		m.mux.Lock()
+		m.stages[s] = probes{queueDepth: vertex.QueueDepth, restarts: restarts}
+		m.mux.Unlock()
+		return newIOWorker(worker, m, s)
+	}
+}
+
+// ioWorker counts the items going through the decorated IOWorker and its errors.
+// The latency is measured from the last input read: the outputs of a streaming plugin can't be matched to their input.
+type ioWorker[K any] struct {
+	worker    graph.IOWorker[K]
+	itemsIn   prometheus.Counter
+	itemsOut  prometheus.Counter
+	errors    prometheus.Counter
+	latency   prometheus.Observer
+	lastInput atomic.Int64
+	output    func() <-chan K
+}
+
+func newIOWorker[K any](worker graph.IOWorker[K], m *Metrics, s stage) *ioWorker[K] {
+	w := &ioWorker[K]{
+		worker:   worker,
+		itemsIn:  m.itemsIn.WithLabelValues(s.name, s.plugin),
+		itemsOut: m.itemsOut.WithLabelValues(s.name, s.plugin),
+		errors:   m.errors.WithLabelValues(s.name, s.plugin),
+		latency:  m.latency.WithLabelValues(s.name, s.plugin),
+	}
+	w.output = sync.OnceValue(func() <-chan K {
+		return diwo.Map(w.worker.Output(), w.emitted)
+	})
+	return w
+}
+
+func (w *ioWorker[K]) emitted(elem K) K {
+	w.itemsOut.Inc()
+	if last := w.lastInput.Load(); last != 0 {
+		w.latency.Observe(time.Since(time.Unix(0, last)).Seconds())
+	}
+	return elem
+}
+
+func (w *ioWorker[K]) SetInput(input <-chan K) {
+	if input == nil {
+		w.worker.SetInput(nil)
+		return
+	}
+	w.worker.SetInput(diwo.Map(input, func(elem K) K {
+		w.itemsIn.Inc()
+		w.lastInput.Store(time.Now().UnixNano())
+		return elem
+	}))
+}
+
+func (w *ioWorker[K]) Output() <-chan K {
+	return w.output()
+}
+
+func (w *ioWorker[K]) Run(ctx graph.SyncContext) <-chan error {
+	return diwo.Map(w.worker.Run(ctx), func(err error) error {
+		w.errors.Inc()
+		return err
+	})
+}
+
+func (w *ioWorker[K]) Kind() graph.Kind {
+	return graph.KindOf(w.worker)
+}
+
+func (w *ioWorker[K]) NotifyDone(fn func(input K, err error)) error {
+	return graph.NotifyDone(w.worker, fn)
+}
+
+func (w *ioWorker[K]) Restarts() uint64 {
+	return graph.RestartsOf(w.worker)
+}
+
+func (w *ioWorker[K]) DeadLetters() <-chan K {
+	if emitter, ok := w.worker.(graph.DeadLetterEmitter[K]); ok {
+		return emitter.DeadLetters()
+	}
+	return nil
+}
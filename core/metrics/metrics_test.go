@@ -0,0 +1,66 @@
+package metrics_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/benji-bou/diwo"
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/graph/graphtest"
+	"github.com/benji-bou/lugh/core/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := metrics.New(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := graph.NewIO(
+		graph.WithVertices([]graph.IOWorkerVertex[int]{
+			graph.NewIOWorkerVertex("forward", []string{}, graphtest.ForwardWorker[int](), graph.WithPluginName[int]("forward")),
+			graph.NewIOWorkerVertex("odd", []string{"forward"}, graphtest.OddWorker[int](), graph.WithPluginName[int]("odd")),
+		}),
+		graph.WithMiddleware(metrics.Middleware[int](m)),
+	)
+	g.SetInput(diwo.FromSlice([]int{1, 2, 3, 4, 5, 6}))
+	outputC := g.Output()
+	ctx := graph.NewContext(context.Background())
+	errC := g.Run(ctx)
+	ctx.Synchronize()
+	go func() {
+		for range outputC {
+		}
+	}()
+	for err := range errC {
+		t.Errorf("unexpected error %v", err)
+	}
+	expected := `
+# HELP lugh_stage_items_in_total Items read by the stage.
+# TYPE lugh_stage_items_in_total counter
+lugh_stage_items_in_total{plugin="forward",stage="forward"} 6
+lugh_stage_items_in_total{plugin="odd",stage="odd"} 6
+# HELP lugh_stage_items_out_total Items emitted by the stage.
+# TYPE lugh_stage_items_out_total counter
+lugh_stage_items_out_total{plugin="forward",stage="forward"} 6
+lugh_stage_items_out_total{plugin="odd",stage="odd"} 3
+# HELP lugh_stage_errors_total Errors reported by the stage.
+# TYPE lugh_stage_errors_total counter
+lugh_stage_errors_total{plugin="forward",stage="forward"} 0
+lugh_stage_errors_total{plugin="odd",stage="odd"} 0
+# HELP lugh_stage_queue_depth Items emitted by the stage its children did not read yet.
+# TYPE lugh_stage_queue_depth gauge
+lugh_stage_queue_depth{plugin="forward",stage="forward"} 0
+lugh_stage_queue_depth{plugin="odd",stage="odd"} 0
+`
+	names := []string{"lugh_stage_items_in_total", "lugh_stage_items_out_total", "lugh_stage_errors_total", "lugh_stage_queue_depth"}
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), names...); err != nil {
+		t.Error(err)
+	}
+	if count := testutil.CollectAndCount(m, "lugh_stage_latency_seconds"); count != 2 {
+		t.Errorf("expected a latency histogram per stage, got %d", count)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"time"
 
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
@@ -34,3 +37,93 @@ func closeStream(stream grpc.ClientStream, name string) error {
 	}
 	return nil
 }
+
+var kindToGraph = map[Kind]graph.Kind{
+	Kind_KIND_UNKNOWN:  graph.KindUnknown,
+	Kind_KIND_PRODUCER: graph.KindProducer,
+	Kind_KIND_CONSUMER: graph.KindConsumer,
+	Kind_KIND_WORKER:   graph.KindWorker,
+	Kind_KIND_RUNNER:   graph.KindRunner,
+	Kind_KIND_GRAPH:    graph.KindGraph,
+}
+
+// headersToProto converts the headers of a message to be sent with the last chunk of its `DataStream`.
+func headersToProto(headers message.Headers) *Headers {
+	res := &Headers{
+		Id:          headers.ID,
+		Source:      headers.Source,
+		TraceId:     headers.TraceID,
+		SpanId:      headers.SpanID,
+		RunId:       headers.RunID,
+		ContentType: headers.ContentType,
+		Values:      headers.Values,
+		Port:        headers.Port,
+	}
+	if !headers.Timestamp.IsZero() {
+		res.Timestamp = headers.Timestamp.UnixNano()
+	}
+	return res
+}
+
+// errorToProto converts an error of the served plugin. The failing input is sent back when the error carries it.
+func errorToProto(err error) *Error {
+	res := &Error{Message: err.Error()}
+	var itemErr graph.ItemError[message.Message]
+	if errors.As(err, &itemErr) {
+		res.Input = messageToProto(itemErr.Input)
+	}
+	return res
+}
+
+// errorFromProto converts an error of the plugin server to a `graph.ItemError` when the failing input is known.
+func errorFromProto(protoErr *Error) error {
+	err := errors.New(protoErr.GetMessage())
+	if protoErr.GetInput() == nil {
+		return err
+	}
+	return graph.ItemError[message.Message]{Input: messageFromProto(protoErr.GetInput()), Err: err}
+}
+
+// messageToProto converts a message to a single complete `DataStream`, for the messages sent outside of the data stream.
+func messageToProto(msg message.Message) *DataStream {
+	return &DataStream{Data: msg.Payload, Headers: headersToProto(msg.Headers), IsComplete: true}
+}
+
+// doneFromProto converts an input the plugin server is done with and its outputs.
+func doneFromProto(done *Done) (message.Message, []message.Message) {
+	outputs := make([]message.Message, 0, len(done.GetOutputs()))
+	for _, output := range done.GetOutputs() {
+		outputs = append(outputs, messageFromProto(output))
+	}
+	return messageFromProto(done.GetInput()), outputs
+}
+
+func messageFromProto(data *DataStream) message.Message {
+	headers := data.GetHeaders()
+	res := message.Message{
+		Payload: data.GetData(),
+		Headers: message.Headers{
+			ID:          headers.GetId(),
+			Source:      headers.GetSource(),
+			TraceID:     headers.GetTraceId(),
+			SpanID:      headers.GetSpanId(),
+			RunID:       headers.GetRunId(),
+			ContentType: headers.GetContentType(),
+			Values:      headers.GetValues(),
+			Port:        headers.GetPort(),
+		},
+	}
+	if headers.GetTimestamp() != 0 {
+		res.Headers.Timestamp = time.Unix(0, headers.GetTimestamp())
+	}
+	return res
+}
+
+func kindFromGraph(kind graph.Kind) Kind {
+	for grpcKind, graphKind := range kindToGraph {
+		if graphKind == kind {
+			return grpcKind
+		}
+	}
+	return Kind_KIND_UNKNOWN
+}
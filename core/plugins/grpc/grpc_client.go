@@ -5,97 +5,320 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// maxRestarts is how many times a run restarts a crashed plugin process before giving up.
+const maxRestarts = 3
+
+// restarter spawns a new plugin process in place of the current one. It is implemented by `Plugin`.
+type restarter interface {
+	restart() (IOWorkerPluginsClient, error)
+}
+
 type GRPCClient struct {
-	client                 IOWorkerPluginsClient
-	Name                   string
-	clientStreamOutputDone chan struct{}
+	client    IOWorkerPluginsClient
+	Name      string
+	kind      func() graph.Kind
+	restarter restarter
+	restarts  atomic.Uint64
+
+	// The configuration is sent again to a restarted plugin process.
+	config     []byte
+	runOptions *RunOptions
+	retry      *RetryOptions
+	timeout    *TimeoutOptions
+	checkpoint graph.Checkpoint[message.Message]
+	onDone     []func(input message.Message, err error)
 }
 
 func NewGRPCClient(client IOWorkerPluginsClient, name string) *GRPCClient {
-	return &GRPCClient{
-		client:                 client,
-		Name:                   name,
-		clientStreamOutputDone: make(chan struct{}),
+	m := &GRPCClient{
+		client: client,
+		Name:   name,
 	}
+	m.kind = sync.OnceValue(m.fetchKind)
+	return m
+}
+
+// Kind returns the kind of the remote plugin. It is fetched once from the plugin server.
+// Plugins built before `GetKind` existed are reported as `graph.KindUnknown`.
+func (m *GRPCClient) Kind() graph.Kind {
+	return m.kind()
+}
+
+func (m *GRPCClient) fetchKind() graph.Kind {
+	resp, err := m.client.GetKind(context.Background(), &Empty{})
+	if err != nil {
+		slog.Warn("GRPCClient: failed to get plugin kind", "error", err, "name", m.Name)
+		return graph.KindUnknown
+	}
+	return kindToGraph[resp.GetKind()]
 }
 
 func (m *GRPCClient) GetInputSchema() ([]byte, error) {
 	resp, err := m.client.GetInputSchema(context.Background(), &Empty{})
-	return resp.Config, err
+	return resp.GetConfig(), err
 }
 
 func (m *GRPCClient) Config(config []byte) error {
 	in := &RunInputConfig{Config: config}
-	_, err := m.client.Config(context.Background(), in)
-	return err
+	if _, err := m.client.Config(context.Background(), in); err != nil {
+		return err
+	}
+	m.config = config
+	return nil
 }
 
-func (m *GRPCClient) Run(ctx context.Context, inputC <-chan []byte, yield func(elem []byte, err error) error) error {
+// SetConcurrency asks the plugin server to process `concurrency` inputs in parallel.
+func (m *GRPCClient) SetConcurrency(concurrency int, ordered bool) error {
+	options := &RunOptions{Concurrency: int32(concurrency), Ordered: ordered} //nolint:gosec // concurrency is a small number
+	if _, err := m.client.SetRunOptions(context.Background(), options); err != nil {
+		return fmt.Errorf("plugin %s set run options: %w", m.Name, err)
+	}
+	m.runOptions = options
+	return nil
+}
+
+// SetRetry asks the plugin server to retry the inputs the plugin fails to process.
+func (m *GRPCClient) SetRetry(policy graph.RetryPolicy) error {
+	options := &RetryOptions{
+		Retry:      int32(policy.Retry), //nolint:gosec // retry is a small number
+		Backoff:    int64(policy.Backoff),
+		MaxBackoff: int64(policy.MaxBackoff),
+	}
+	if _, err := m.client.SetRetry(context.Background(), options); err != nil {
+		return fmt.Errorf("plugin %s set retry: %w", m.Name, err)
+	}
+	m.retry = options
+	return nil
+}
+
+// SetTimeout asks the plugin server to fail the inputs the plugin spends more than `timeout` on.
+// The client streams its inputs ahead of the plugin, only the server knows when the plugin is done with one.
+func (m *GRPCClient) SetTimeout(timeout time.Duration) error {
+	options := &TimeoutOptions{Timeout: int64(timeout)}
+	if _, err := m.client.SetTimeout(context.Background(), options); err != nil {
+		return fmt.Errorf("plugin %s set timeout: %w", m.Name, err)
+	}
+	m.timeout = options
+	return nil
+}
+
+// SetCheckpoint asks the plugin server to report each input the plugin is done with, and its outputs, to record them in `checkpoint`.
+// The inputs already recorded are not sent to the plugin, their recorded outputs are yielded instead.
+// Plugins unable to tell when they are done with an input, like runners, return `graph.ErrCheckpointNotSupported`.
+func (m *GRPCClient) SetCheckpoint(checkpoint graph.Checkpoint[message.Message]) error {
+	if err := m.reportDone(); err != nil {
+		return err
+	}
+	m.checkpoint = checkpoint
+	return nil
+}
+
+// NotifyDone calls `fn` once the plugin server reports an input done or failed, see `graph.DoneNotifier`.
+func (m *GRPCClient) NotifyDone(fn func(input message.Message, err error)) error {
+	if err := m.reportDone(); err != nil {
+		return fmt.Errorf("%w: %w", graph.ErrDoneNotSupported, err)
+	}
+	m.onDone = append(m.onDone, fn)
+	return nil
+}
+
+// reportDone asks the plugin server to send each input the plugin is done with, and its outputs.
+func (m *GRPCClient) reportDone() error {
+	_, err := m.client.SetCheckpoint(context.Background(), &Empty{})
+	if status.Code(err) == codes.Unimplemented {
+		return fmt.Errorf("%w by plugin %s: %s", graph.ErrCheckpointNotSupported, m.Name, status.Convert(err).Message())
+	}
+	if err != nil {
+		return fmt.Errorf("plugin %s set checkpoint: %w", m.Name, err)
+	}
+	return nil
+}
+
+// record records an input the plugin server is done with.
+func (m *GRPCClient) record(done *Done) {
+	input, outputs := doneFromProto(done)
+	if m.checkpoint != nil {
+		if err := m.checkpoint.Record(input, outputs); err != nil {
+			slog.Warn("failed to checkpoint input, it will be processed again on resume", "error", err, "name", m.Name)
+		}
+	}
+	m.done(input, nil)
+}
+
+func (m *GRPCClient) done(input message.Message, err error) {
+	for _, fn := range m.onDone {
+		fn(input, err)
+	}
+}
+
+// replay returns the outputs recorded for `input` by a previous run.
+func (m *GRPCClient) replay(input message.Message) ([]message.Message, bool) {
+	if m.checkpoint == nil {
+		return nil, false
+	}
+	return m.checkpoint.Outputs(input)
+}
+
+// Restarts returns how many times the plugin process was restarted after it crashed.
+func (m *GRPCClient) Restarts() uint64 {
+	return m.restarts.Load()
+}
+
+// reconnect spawns a new plugin process and sends it the configuration of the crashed one.
+func (m *GRPCClient) reconnect() error {
+	client, err := m.restarter.restart()
+	if err != nil {
+		return fmt.Errorf("restart plugin %s: %w", m.Name, err)
+	}
+	m.client = client
+	m.restarts.Add(1)
+	if m.config != nil {
+		if err := m.Config(m.config); err != nil {
+			return fmt.Errorf("configure restarted plugin %s: %w", m.Name, err)
+		}
+	}
+	if m.runOptions != nil {
+		if err := m.SetConcurrency(int(m.runOptions.GetConcurrency()), m.runOptions.GetOrdered()); err != nil {
+			return err
+		}
+	}
+	if m.retry != nil {
+		if _, err := m.client.SetRetry(context.Background(), m.retry); err != nil {
+			return fmt.Errorf("plugin %s set retry: %w", m.Name, err)
+		}
+	}
+	if m.timeout != nil {
+		if _, err := m.client.SetTimeout(context.Background(), m.timeout); err != nil {
+			return fmt.Errorf("plugin %s set timeout: %w", m.Name, err)
+		}
+	}
+	if m.checkpoint != nil || len(m.onDone) > 0 {
+		return m.reportDone()
+	}
+	return nil
+}
+
+// canRestart tells whether the run failing with `err` lost the plugin process and can go on with a new one.
+func (m *GRPCClient) canRestart(ctx context.Context, err error, restarts int) bool {
+	if err == nil || ctx.Err() != nil || restarts >= maxRestarts || m.restarter == nil {
+		return false
+	}
+	return status.Code(err) == codes.Unavailable
+}
+
+// Run streams the input messages, headers included, to the plugin server and yields the messages it sends back.
+// When the plugin process crashes, it is restarted up to `maxRestarts` times and the next inputs are sent to the new one.
+// The inputs the crashed process was working on are lost, the crash is yielded as an error.
+func (m *GRPCClient) Run(ctx context.Context, inputC <-chan message.Message, yield func(elem message.Message, err error) error) error {
+	for restarts := 0; ; restarts++ {
+		err := m.run(ctx, inputC, yield)
+		if !m.canRestart(ctx, err, restarts) {
+			m.discardInputs(ctx, inputC)
+			return err
+		}
+		slog.Warn("GRPCClient: plugin process exited, restarting it", "error", err, "name", m.Name)
+		if errRestart := m.reconnect(); errRestart != nil {
+			m.discardInputs(ctx, inputC)
+			return errors.Join(err, errRestart)
+		}
+		if errYield := yield(message.Message{}, fmt.Errorf("plugin %s restarted: %w", m.Name, err)); errYield != nil {
+			return errYield
+		}
+	}
+}
+
+// run streams the inputs to the current plugin process until `inputC` is closed or the process stops answering.
+func (m *GRPCClient) run(ctx context.Context, inputC <-chan message.Message, yield func(elem message.Message, err error) error) error {
 	runStream, err := m.client.Run(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to create run stream: %w", err)
 	}
 
+	// The outputs recorded by a previous run are yielded while the plugin outputs are.
+	var yieldMux sync.Mutex
+	yieldOne := func(elem message.Message, err error) error {
+		yieldMux.Lock()
+		defer yieldMux.Unlock()
+		return yield(elem, err)
+	}
+	outputDoneC := make(chan struct{})
 	inputDoneC := make(chan struct{})
 	go func() {
-		m.handleInputStream(ctx, inputC, runStream)
+		m.handleInputStream(ctx, inputC, outputDoneC, runStream, yieldOne)
 		close(inputDoneC)
 	}()
-	err = m.handleOutputStream(runStream, yield)
+	err = m.handleOutputStream(runStream, yieldOne)
+	close(outputDoneC)
 	<-inputDoneC
 	return err
 }
 
-func (m *GRPCClient) handleInputStream(ctx context.Context, inputC <-chan []byte, runStream grpc.BidiStreamingClient[DataStream, RunStream]) {
-	outputDone := false
+// discardInputs reads the inputs no plugin process will ever answer, so that the parents aren't blocked.
+func (m *GRPCClient) discardInputs(ctx context.Context, inputC <-chan message.Message) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-inputC:
+			if !ok {
+				return
+			}
+			slog.Debug("Runner: GRPCCLient: Output stream is Done but keep receiving input data. Doing nothing",
+				"GRPCClient", m.Name,
+				"function", "discardInputs")
+		}
+	}
+}
+
+func (m *GRPCClient) handleInputStream(ctx context.Context, inputC <-chan message.Message, outputDoneC <-chan struct{}, runStream grpc.BidiStreamingClient[DataStream, RunStream], yield func(elem message.Message, err error) error) {
 	runloop := NewRunLoop()
 	defer runStream.CloseSend()
 	for {
-		var outputDoneC <-chan struct{}
-		if !outputDone {
-			outputDoneC = m.clientStreamOutputDone
-		}
 		select {
 		case <-ctx.Done():
 			slog.Debug("Runner: GRPCCLient: context done", "GRPCClient", m.Name)
 			return
-		case _, ok := <-outputDoneC:
-			// `!ok` here `clientStreamOutputDone` is closed means that we won't receive anymore data from the plugin server.
-			// Not necessary to send data to the plugin server if no response will ever be sent back
-			if !ok {
-				outputDone = true
-			}
+		case <-outputDoneC:
+			// We won't receive anymore data from the plugin server, the next inputs are left to `Run`.
+			return
 		case inputStreamData, ok := <-inputC:
 			// `!ok` no more input will be received we can safely close the stream and return
 			if !ok {
 				slog.Debug("input channel closed", "GRPCClient", m.Name)
 				return
 			}
-			if !outputDone {
-				slog.Debug("Runner: GRPCCLient: sending data to plugin server", "GRPCClient", m.Name)
-				err := m.sendNewData(runloop, &DataStream{Data: inputStreamData, ParentSrc: m.Name}, runStream)
-				if err != nil {
-					slog.Error("Runner: GRPCCLient:failed to send data to plugin server", "GRPCClient", m.Name,
-						"function", "handleGRPCPluginInput", "error", err)
+			if outputs, ok := m.replay(inputStreamData); ok {
+				for _, output := range outputs {
+					if err := yield(output, nil); err != nil {
+						return
+					}
 				}
-			} else {
-				slog.Debug("Runner: GRPCCLient: Output stream is Done but keep receiving input data. Doing nothing",
-					"GRPCClient", m.Name,
-					"function", "handleGRPCPluginInput")
+				m.done(inputStreamData, nil)
+				continue
+			}
+			slog.Debug("Runner: GRPCCLient: sending data to plugin server", "GRPCClient", m.Name)
+			err := m.sendNewData(runloop, &DataStream{Data: inputStreamData.Payload, ParentSrc: m.Name, Headers: headersToProto(inputStreamData.Headers)}, runStream)
+			if err != nil {
+				slog.Error("Runner: GRPCCLient:failed to send data to plugin server", "GRPCClient", m.Name,
+					"function", "handleGRPCPluginInput", "error", err)
 			}
 		}
 	}
 }
 
-func (m *GRPCClient) handleOutputStream(runStream grpc.BidiStreamingClient[DataStream, RunStream], yield func(elem []byte, err error) error) error {
-	defer func() {
-		slog.Debug("Closing GRPCClient output stream", "GRPCClient", m.Name)
-		close(m.clientStreamOutputDone)
-	}()
+func (m *GRPCClient) handleOutputStream(runStream grpc.BidiStreamingClient[DataStream, RunStream], yield func(elem message.Message, err error) error) error {
+	defer slog.Debug("Closing GRPCClient output stream", "GRPCClient", m.Name)
 	runloop := NewRunLoop()
 	for {
 		req, err := runStream.Recv()
@@ -107,15 +330,24 @@ func (m *GRPCClient) handleOutputStream(runStream grpc.BidiStreamingClient[DataS
 			}
 			return nil
 		}
+		if req.Done != nil {
+			m.record(req.Done)
+			continue
+		}
 		if req.Error != nil {
-			if errYield := yield(nil, errors.New(req.Error.Message)); errYield != nil {
+			err := errorFromProto(req.Error)
+			var itemErr graph.ItemError[message.Message]
+			if errors.As(err, &itemErr) {
+				m.done(itemErr.Input, itemErr.Err)
+			}
+			if errYield := yield(message.Message{}, err); errYield != nil {
 				return errYield
 			}
 			continue
 		}
 		toForward := runloop.Recv(req.Data)
 		if toForward != nil {
-			if errYield := yield(toForward.Data, nil); errYield != nil {
+			if errYield := yield(messageFromProto(toForward), nil); errYield != nil {
 				return errYield
 			}
 		}
@@ -123,7 +355,7 @@ func (m *GRPCClient) handleOutputStream(runStream grpc.BidiStreamingClient[DataS
 }
 
 func (m *GRPCClient) sendNewData(runloop *RunLoop, dataStream *DataStream, stream grpc.BidiStreamingClient[DataStream, RunStream]) error {
-	for _, dataToSend := range runloop.Send(&DataStream{Data: dataStream.Data, ParentSrc: m.Name}) {
+	for _, dataToSend := range runloop.Send(&DataStream{Data: dataStream.Data, ParentSrc: m.Name, Headers: dataStream.Headers}) {
 		err := stream.Send(dataToSend)
 		if err != nil {
 			return fmt.Errorf("send data to client stream named %s: %w", m.Name, err)
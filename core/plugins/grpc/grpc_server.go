@@ -2,20 +2,31 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
 	"github.com/benji-bou/lugh/core/plugins/pluginapi"
 	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
 )
 
+var errNoRunStream = errors.New("no run stream")
+
 // Here is the gRPC server that GRPCClient talks to.
 type GRPCServer struct {
-	// This is the real implementation
-	Worker     pluginapi.IOWorker
+	// This is the real implementation. Plugins handling raw payloads are adapted with `message.IOWorker`.
+	Worker     graph.IOWorker[message.Message]
 	Configurer pluginapi.PluginConfigurer
 	Name       string
+	// stream is the stream of the current `Run`, the outputs, errors and inputs done are sent on it.
+	stream    grpc.BidiStreamingServer[DataStream, RunStream]
+	streamMux sync.Mutex
 }
 
 func (m *GRPCServer) GetInputSchema(context.Context, *Empty) (*InputSchema, error) {
@@ -35,8 +46,90 @@ func (m *GRPCServer) Config(_ context.Context, config *RunInputConfig) (*Empty,
 	return nil, fmt.Errorf("plugin %s does not implement PluginConfigurer", m.Name)
 }
 
+// GetKind returns the kind of the served plugin so the client can check how the stage is wired.
+func (m *GRPCServer) GetKind(context.Context, *Empty) (*PluginKind, error) {
+	return &PluginKind{Kind: kindFromGraph(graph.KindOf(m.Worker))}, nil
+}
+
+// SetRunOptions configures how the served plugin processes its inputs. See `graph.ConcurrencyConfigurer`.
+func (m *GRPCServer) SetRunOptions(_ context.Context, options *RunOptions) (*Empty, error) {
+	err := graph.SetConcurrency(m.Worker, int(options.GetConcurrency()), options.GetOrdered())
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", m.Name, err)
+	}
+	return &Empty{}, nil
+}
+
+// SetRetry retries the inputs the served plugin fails to process. See `graph.RetryConfigurer`.
+func (m *GRPCServer) SetRetry(_ context.Context, options *RetryOptions) (*Empty, error) {
+	err := graph.SetRetry(m.Worker, graph.RetryPolicy{
+		Retry:      int(options.GetRetry()),
+		Backoff:    time.Duration(options.GetBackoff()),
+		MaxBackoff: time.Duration(options.GetMaxBackoff()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", m.Name, err)
+	}
+	return &Empty{}, nil
+}
+
+// SetTimeout bounds the time the served plugin spends on each input. See `graph.TimeoutConfigurer`.
+func (m *GRPCServer) SetTimeout(_ context.Context, options *TimeoutOptions) (*Empty, error) {
+	err := graph.SetTimeout(m.Worker, time.Duration(options.GetTimeout()))
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", m.Name, err)
+	}
+	return &Empty{}, nil
+}
+
+// SetCheckpoint sends each input the served plugin is done with, and its outputs, to the client which records them.
+// See `graph.CheckpointConfigurer`. The client doesn't send the inputs it already recorded.
+func (m *GRPCServer) SetCheckpoint(context.Context, *Empty) (*Empty, error) {
+	err := graph.SetCheckpoint[message.Message](m.Worker, streamCheckpoint{server: m})
+	if errors.Is(err, graph.ErrCheckpointNotSupported) {
+		return nil, status.Errorf(codes.Unimplemented, "plugin %s: %v", m.Name, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", m.Name, err)
+	}
+	return &Empty{}, nil
+}
+
+// streamCheckpoint reports the inputs done on the run stream, the client holds the checkpoint.
+type streamCheckpoint struct {
+	server *GRPCServer
+}
+
+func (streamCheckpoint) Outputs(message.Message) ([]message.Message, bool) {
+	return nil, false
+}
+
+func (c streamCheckpoint) Record(input message.Message, outputs []message.Message) error {
+	done := &Done{Input: messageToProto(input), Outputs: make([]*DataStream, 0, len(outputs))}
+	for _, output := range outputs {
+		done.Outputs = append(done.Outputs, messageToProto(output))
+	}
+	return c.server.send(&RunStream{Done: done})
+}
+
+// send sends `res` on the stream of the current `Run`. It is called by the run loop and the checkpoint.
+func (m *GRPCServer) send(res *RunStream) error {
+	m.streamMux.Lock()
+	defer m.streamMux.Unlock()
+	if m.stream == nil {
+		return fmt.Errorf("plugin %s: %w", m.Name, errNoRunStream)
+	}
+	return m.stream.Send(res)
+}
+
+func (m *GRPCServer) setStream(stream grpc.BidiStreamingServer[DataStream, RunStream]) {
+	m.streamMux.Lock()
+	defer m.streamMux.Unlock()
+	m.stream = stream
+}
+
 func (m *GRPCServer) input(ctx graph.SyncContext, stream grpc.BidiStreamingServer[DataStream, RunStream]) error {
-	inputC := make(chan []byte)
+	inputC := make(chan message.Message)
 	m.Worker.SetInput(inputC)
 	defer close(inputC)
 	runLoop := NewRunLoop()
@@ -54,7 +147,7 @@ func (m *GRPCServer) input(ctx graph.SyncContext, stream grpc.BidiStreamingServe
 		toForward := runLoop.Recv(req)
 		if toForward != nil {
 			slog.Debug("Plugin server forwarding data to plugin ", "name", m.Name)
-			inputC <- toForward.Data
+			inputC <- messageFromProto(toForward)
 			slog.Debug("Plugin server forwarded data to plugin ", "name", m.Name)
 		}
 	}
@@ -63,6 +156,8 @@ func (m *GRPCServer) input(ctx graph.SyncContext, stream grpc.BidiStreamingServe
 func (m *GRPCServer) Run(stream grpc.BidiStreamingServer[DataStream, RunStream]) error {
 	currentctx, cancelCtx := context.WithCancel(stream.Context())
 	defer cancelCtx()
+	m.setStream(stream)
+	defer m.setStream(nil)
 	ctxSync := graph.NewContext(currentctx)
 	outputC := m.Worker.Output()
 	ctxSync.Initializing()
@@ -72,6 +167,7 @@ func (m *GRPCServer) Run(stream grpc.BidiStreamingServer[DataStream, RunStream])
 	ctxSync.Synchronize()
 	for {
 		if errC == nil && outputC == nil {
+			slog.Info("GRPC Server Run ended (run error channel of underlying plugin is closed)", "name", m.Name)
 			return nil
 		}
 		select {
@@ -81,7 +177,7 @@ func (m *GRPCServer) Run(stream grpc.BidiStreamingServer[DataStream, RunStream])
 				continue
 			}
 			slog.Info("error received", "error", err, "name", m.Name)
-			err = stream.Send(&RunStream{Error: &Error{Message: err.Error()}})
+			err = m.send(&RunStream{Error: errorToProto(err)})
 			if err != nil {
 				slog.Error("sending error data over stream failed",
 					"function", "Output",
@@ -99,8 +195,8 @@ func (m *GRPCServer) Run(stream grpc.BidiStreamingServer[DataStream, RunStream])
 				continue
 			}
 			slog.Debug("Plugin server received data to output from plugin ", "name", m.Name)
-			for _, d := range runLoop.Send(&DataStream{Data: dataOutput, ParentSrc: m.Name}) {
-				err := stream.Send(&RunStream{Data: d})
+			for _, d := range runLoop.Send(&DataStream{Data: dataOutput.Payload, ParentSrc: m.Name, Headers: headersToProto(dataOutput.Headers)}) {
+				err := m.send(&RunStream{Data: d})
 				if err != nil {
 					slog.Error("sending data over stream failed",
 						"function", "Output",
@@ -113,9 +209,6 @@ func (m *GRPCServer) Run(stream grpc.BidiStreamingServer[DataStream, RunStream])
 			}
 		}
 	}
-
-	slog.Info("GRPC Server Run ended (run error channel of underlying plugin is closed)", "name", m.Name)
-	return nil
 }
 
 func (*GRPCServer) mustEmbedUnimplementedIOWorkerPluginsServer() {
@@ -0,0 +1,144 @@
+package grpc_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/benji-bou/diwo"
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
+	"github.com/benji-bou/lugh/core/plugins/grpc"
+	"github.com/benji-bou/lugh/core/plugins/pluginapi"
+)
+
+// TestMain serves the test plugin when the test binary is started as a plugin process.
+func TestMain(m *testing.M) {
+	if os.Getenv(grpc.DefaultHandshake.MagicCookieKey) == grpc.DefaultHandshake.MagicCookieValue {
+		grpc.NewPlugin("crasher", grpc.WithPluginMessageWorker(graph.WorkerFunc[message.Message](crash))).Serve()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// crash echoes its inputs and kills the plugin process on the input "crash".
+func crash(_ context.Context, input message.Message, yield func(elem message.Message) error) error {
+	if string(input.Payload) == "crash" {
+		os.Exit(1)
+	}
+	return yield(input)
+}
+
+// connect starts the test binary as the plugin process.
+func connect(t *testing.T) pluginapi.MessageRunner {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin := grpc.NewPlugin("crasher", grpc.WithPath(t.TempDir()), grpc.WithCmdConfig(exec.Command(exe)))
+	runner, err := plugin.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(plugin.Cleanup)
+	return runner
+}
+
+// memoryCheckpoint records the outputs of each input by message id.
+type memoryCheckpoint struct {
+	outputs map[string][]message.Message
+	mux     sync.Mutex
+}
+
+func (mc *memoryCheckpoint) Outputs(input message.Message) ([]message.Message, bool) {
+	mc.mux.Lock()
+	defer mc.mux.Unlock()
+	outputs, ok := mc.outputs[input.Headers.ID]
+	return outputs, ok
+}
+
+func (mc *memoryCheckpoint) Record(input message.Message, outputs []message.Message) error {
+	mc.mux.Lock()
+	defer mc.mux.Unlock()
+	mc.outputs[input.Headers.ID] = outputs
+	return nil
+}
+
+func TestRunCheckpoint(t *testing.T) {
+	runner := connect(t)
+	crash := message.New([]byte("crash"))
+	checkpoint := &memoryCheckpoint{outputs: map[string][]message.Message{
+		crash.Headers.ID: {message.New([]byte("recorded"))},
+	}}
+	if err := graph.SetCheckpoint[message.Message](runner, checkpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	next := message.New([]byte("next"))
+	outputs := []string{}
+	err := runner.Run(context.Background(), diwo.FromSlice([]message.Message{crash, next}), func(elem message.Message, err error) error {
+		if err != nil {
+			t.Errorf("the recorded input is sent to the plugin: %v", err)
+			return nil
+		}
+		outputs = append(outputs, string(elem.Payload))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !slices.Equal(outputs, []string{"recorded", "next"}) {
+		t.Errorf("outputs = %v, want [recorded next]", outputs)
+	}
+	recorded, ok := checkpoint.Outputs(next)
+	if !ok || len(recorded) != 1 || string(recorded[0].Payload) != "next" {
+		t.Errorf("recorded outputs of next = %v, %t, want [next]", recorded, ok)
+	}
+}
+
+func TestRunRestartsCrashedPlugin(t *testing.T) {
+	runner := connect(t)
+
+	inputC := make(chan message.Message)
+	answeredC := make(chan struct{})
+	restartedC := make(chan struct{})
+	go func() {
+		inputC <- message.New([]byte("a"))
+		// The inputs sent to the crashing process are lost.
+		<-answeredC
+		inputC <- message.New([]byte("crash"))
+		<-restartedC
+		inputC <- message.New([]byte("b"))
+		close(inputC)
+	}()
+	outputs := []string{}
+	errs := 0
+	err := runner.Run(context.Background(), inputC, func(elem message.Message, err error) error {
+		if err != nil {
+			errs++
+			close(restartedC)
+			return nil
+		}
+		outputs = append(outputs, string(elem.Payload))
+		if len(outputs) == 1 {
+			close(answeredC)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if errs != 1 {
+		t.Errorf("errors = %d, want the crash reported once", errs)
+	}
+	if !slices.Equal(outputs, []string{"a", "b"}) {
+		t.Errorf("outputs = %v, want [a b]", outputs)
+	}
+	if restarts := graph.RestartsOf(runner); restarts != 1 {
+		t.Errorf("restarts = %d, want 1", restarts)
+	}
+}
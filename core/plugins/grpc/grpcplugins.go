@@ -4,6 +4,8 @@ import (
 	context "context"
 	"errors"
 
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
 	"github.com/benji-bou/lugh/core/plugins/pluginapi"
 	goplugin "github.com/hashicorp/go-plugin"
 	grpc "google.golang.org/grpc"
@@ -18,7 +20,7 @@ var (
 type IOWorkerGRPCPlugin struct {
 	// GRPCPlugin must still implement the Plugin interface
 	goplugin.NetRPCUnsupportedPlugin
-	Worker     pluginapi.IOWorker
+	Worker     graph.IOWorker[message.Message]
 	Configurer pluginapi.PluginConfigurer
 	Name       string
 	// Concrete implementation, written in Go. This is only used for plugins
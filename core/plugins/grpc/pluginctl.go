@@ -8,7 +8,9 @@ import (
 	"path/filepath"
 
 	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
 	"github.com/benji-bou/lugh/core/plugins/pluginapi"
+	"github.com/benji-bou/lugh/core/secret"
 	"github.com/benji-bou/lugh/helper"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-plugin"
@@ -107,52 +109,48 @@ func WithCmdConfig(cmd *exec.Cmd) PluginOption {
 }
 
 func WithPluginProducer(plg pluginapi.Producer) PluginOption {
-	return func(p *Plugin) {
-		var configurer pluginapi.PluginConfigurer
-		if configurerTmp, ok := plg.(pluginapi.PluginConfigurer); ok {
-			configurer = configurerTmp
-		}
-		p.plugin = IOWorkerGRPCPlugin{Worker: graph.NewIOWorkerFromProducer(plg), Configurer: configurer, Name: p.name}
-	}
+	return withPluginIOWorker(plg, graph.NewIOWorkerFromProducer(message.FromProducer(plg)))
 }
 
 func WithPluginConsumer(plg pluginapi.Consumer) PluginOption {
-	return func(p *Plugin) {
-		var configurer pluginapi.PluginConfigurer
-		if configurerTmp, ok := plg.(pluginapi.PluginConfigurer); ok {
-			configurer = configurerTmp
-		}
-		p.plugin = IOWorkerGRPCPlugin{Worker: graph.NewIOWorkerFromConsumer(plg), Configurer: configurer, Name: p.name}
-	}
+	return withPluginIOWorker(plg, graph.NewIOWorkerFromConsumer(message.FromConsumer(plg)))
 }
 
 func WithPluginRunner(plg pluginapi.Runner) PluginOption {
-	return func(p *Plugin) {
-		var configurer pluginapi.PluginConfigurer
-		if configurerTmp, ok := plg.(pluginapi.PluginConfigurer); ok {
-			configurer = configurerTmp
-		}
-		p.plugin = IOWorkerGRPCPlugin{Worker: graph.NewIOWorkerFromRunner(plg), Configurer: configurer, Name: p.name}
-	}
+	return withPluginIOWorker(plg, graph.NewIOWorkerFromRunner(message.FromRunner(plg)))
 }
 
 func WithPluginWorker(plg pluginapi.Worker) PluginOption {
-	return func(p *Plugin) {
-		var configurer pluginapi.PluginConfigurer
-		if configurerTmp, ok := plg.(pluginapi.PluginConfigurer); ok {
-			configurer = configurerTmp
-		}
-		p.plugin = IOWorkerGRPCPlugin{Worker: graph.NewIOWorkerFromWorker(plg), Configurer: configurer, Name: p.name}
-	}
+	return withPluginIOWorker(plg, graph.NewIOWorkerFromWorker(message.FromWorker(plg)))
 }
 
 func WithPluginIOWorker(plg pluginapi.IOWorker) PluginOption {
+	return withPluginIOWorker(plg, message.FromIOWorker(plg))
+}
+
+// WithPluginMessageWorker serves a Worker reading and writing the headers of the messages.
+func WithPluginMessageWorker(plg pluginapi.MessageWorker) PluginOption {
+	return withPluginIOWorker(plg, graph.NewIOWorkerFromWorker(plg))
+}
+
+// WithPluginMessageRunner serves a Runner reading and writing the headers of the messages.
+func WithPluginMessageRunner(plg pluginapi.MessageRunner) PluginOption {
+	return withPluginIOWorker(plg, graph.NewIOWorkerFromRunner(plg))
+}
+
+// WithPluginMessageIOWorker serves an IOWorker reading and writing the headers of the messages.
+func WithPluginMessageIOWorker(plg pluginapi.MessageIOWorker) PluginOption {
+	return withPluginIOWorker(plg, plg)
+}
+
+// withPluginIOWorker serves `worker`. `plg` is the plugin implementation, used as configurer when it implements `pluginapi.PluginConfigurer`.
+func withPluginIOWorker(plg any, worker pluginapi.MessageIOWorker) PluginOption {
 	return func(p *Plugin) {
 		var configurer pluginapi.PluginConfigurer
 		if configurerTmp, ok := plg.(pluginapi.PluginConfigurer); ok {
 			configurer = configurerTmp
 		}
-		p.plugin = IOWorkerGRPCPlugin{Worker: plg, Configurer: configurer, Name: p.name}
+		p.plugin = IOWorkerGRPCPlugin{Worker: worker, Configurer: configurer, Name: p.name}
 	}
 }
 
@@ -176,9 +174,9 @@ func (p *Plugin) Serve() {
 	slog.Debug("stop serving plugin", "name", p.name)
 }
 
-func (p *Plugin) Connect() (pluginapi.Runner, error) {
-	log := hclog.Default().Named(p.name)
-	log.SetLevel(hclog.Debug)
+func (p *Plugin) Connect() (pluginapi.MessageRunner, error) {
+	// The logs of the plugin process go through this logger, the secrets they hold are redacted.
+	log := hclog.New(&hclog.LoggerOptions{Name: p.name, Level: hclog.Debug, Output: secret.Writer(os.Stderr)})
 	p.client = plugin.NewClient(&plugin.ClientConfig{
 		HandshakeConfig:  p.handshake,
 		Plugins:          plugin.PluginSet{"plugin": p.plugin},
@@ -198,14 +196,33 @@ func (p *Plugin) Connect() (pluginapi.Runner, error) {
 		return nil, fmt.Errorf("failed to dispense plugin, %w", err)
 	}
 
-	resSec, ok := res.(pluginapi.Runner)
+	resSec, ok := res.(pluginapi.MessageRunner)
 	if !ok {
 		slog.Error("failed to dispense plugin not a SecPluginable", "function", "Connect", "Object", "Plugin", "file", "grpc.go")
 		return nil, fmt.Errorf("failed to dispense plugin  not a SecPluginable")
 	}
+	if client, ok := resSec.(*GRPCClient); ok {
+		client.restarter = p
+	}
 	return resSec, nil
 }
 
+// restart kills what is left of the plugin process, like after a crash, and connects to a new one.
+func (p *Plugin) restart() (IOWorkerPluginsClient, error) {
+	p.Cleanup()
+	// A command can only be started once.
+	p.cmd = &exec.Cmd{Path: p.cmd.Path, Args: p.cmd.Args, Env: p.cmd.Env, Dir: p.cmd.Dir}
+	runner, err := p.Connect()
+	if err != nil {
+		return nil, err
+	}
+	client, ok := runner.(*GRPCClient)
+	if !ok {
+		return nil, fmt.Errorf("restarted plugin %s is a %T, not a gRPC client", p.name, runner)
+	}
+	return client.client, nil
+}
+
 func (p *Plugin) Cleanup() {
 	if p.client != nil {
 		p.client.Kill()
@@ -20,6 +20,64 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type Kind int32
+
+const (
+	Kind_KIND_UNKNOWN  Kind = 0
+	Kind_KIND_PRODUCER Kind = 1
+	Kind_KIND_CONSUMER Kind = 2
+	Kind_KIND_WORKER   Kind = 3
+	Kind_KIND_RUNNER   Kind = 4
+	Kind_KIND_GRAPH    Kind = 5
+)
+
+// Enum value maps for Kind.
+var (
+	Kind_name = map[int32]string{
+		0: "KIND_UNKNOWN",
+		1: "KIND_PRODUCER",
+		2: "KIND_CONSUMER",
+		3: "KIND_WORKER",
+		4: "KIND_RUNNER",
+		5: "KIND_GRAPH",
+	}
+	Kind_value = map[string]int32{
+		"KIND_UNKNOWN":  0,
+		"KIND_PRODUCER": 1,
+		"KIND_CONSUMER": 2,
+		"KIND_WORKER":   3,
+		"KIND_RUNNER":   4,
+		"KIND_GRAPH":    5,
+	}
+)
+
+func (x Kind) Enum() *Kind {
+	p := new(Kind)
+	*p = x
+	return p
+}
+
+func (x Kind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Kind) Descriptor() protoreflect.EnumDescriptor {
+	return file_core_plugins_grpc_plugins_proto_enumTypes[0].Descriptor()
+}
+
+func (Kind) Type() protoreflect.EnumType {
+	return &file_core_plugins_grpc_plugins_proto_enumTypes[0]
+}
+
+func (x Kind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Kind.Descriptor instead.
+func (Kind) EnumDescriptor() ([]byte, []int) {
+	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{0}
+}
+
 type RunInputConfig struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -114,22 +172,134 @@ func (x *InputSchema) GetConfig() []byte {
 	return nil
 }
 
+type Headers struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Source      string            `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	TraceId     string            `protobuf:"bytes,2,opt,name=traceId,proto3" json:"traceId,omitempty"`
+	RunId       string            `protobuf:"bytes,3,opt,name=runId,proto3" json:"runId,omitempty"`
+	Timestamp   int64             `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	ContentType string            `protobuf:"bytes,5,opt,name=contentType,proto3" json:"contentType,omitempty"`
+	Values      map[string]string `protobuf:"bytes,6,rep,name=values,proto3" json:"values,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	SpanId      string            `protobuf:"bytes,7,opt,name=spanId,proto3" json:"spanId,omitempty"`
+	Id          string            `protobuf:"bytes,8,opt,name=id,proto3" json:"id,omitempty"`
+	Port        string            `protobuf:"bytes,9,opt,name=port,proto3" json:"port,omitempty"`
+}
+
+func (x *Headers) Reset() {
+	*x = Headers{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_core_plugins_grpc_plugins_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Headers) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Headers) ProtoMessage() {}
+
+func (x *Headers) ProtoReflect() protoreflect.Message {
+	mi := &file_core_plugins_grpc_plugins_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Headers.ProtoReflect.Descriptor instead.
+func (*Headers) Descriptor() ([]byte, []int) {
+	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Headers) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Headers) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *Headers) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+func (x *Headers) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Headers) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Headers) GetValues() map[string]string {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+func (x *Headers) GetSpanId() string {
+	if x != nil {
+		return x.SpanId
+	}
+	return ""
+}
+
+func (x *Headers) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Headers) GetPort() string {
+	if x != nil {
+		return x.Port
+	}
+	return ""
+}
+
 type DataStream struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Data       []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
-	ParentSrc  string `protobuf:"bytes,2,opt,name=parentSrc,proto3" json:"parentSrc,omitempty"`
-	Id         string `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
-	IsComplete bool   `protobuf:"varint,4,opt,name=isComplete,proto3" json:"isComplete,omitempty"`
-	TotalLen   int64  `protobuf:"varint,5,opt,name=totalLen,proto3" json:"totalLen,omitempty"`
+	Data       []byte   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	ParentSrc  string   `protobuf:"bytes,2,opt,name=parentSrc,proto3" json:"parentSrc,omitempty"`
+	Id         string   `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
+	IsComplete bool     `protobuf:"varint,4,opt,name=isComplete,proto3" json:"isComplete,omitempty"`
+	TotalLen   int64    `protobuf:"varint,5,opt,name=totalLen,proto3" json:"totalLen,omitempty"`
+	Headers    *Headers `protobuf:"bytes,6,opt,name=headers,proto3" json:"headers,omitempty"`
 }
 
 func (x *DataStream) Reset() {
 	*x = DataStream{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_core_plugins_grpc_plugins_proto_msgTypes[2]
+		mi := &file_core_plugins_grpc_plugins_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -142,7 +312,7 @@ func (x *DataStream) String() string {
 func (*DataStream) ProtoMessage() {}
 
 func (x *DataStream) ProtoReflect() protoreflect.Message {
-	mi := &file_core_plugins_grpc_plugins_proto_msgTypes[2]
+	mi := &file_core_plugins_grpc_plugins_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -155,7 +325,7 @@ func (x *DataStream) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DataStream.ProtoReflect.Descriptor instead.
 func (*DataStream) Descriptor() ([]byte, []int) {
-	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{2}
+	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *DataStream) GetData() []byte {
@@ -193,6 +363,13 @@ func (x *DataStream) GetTotalLen() int64 {
 	return 0
 }
 
+func (x *DataStream) GetHeaders() *Headers {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
 type RunStream struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -200,12 +377,13 @@ type RunStream struct {
 
 	Data  *DataStream `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
 	Error *Error      `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Done  *Done       `protobuf:"bytes,3,opt,name=done,proto3" json:"done,omitempty"`
 }
 
 func (x *RunStream) Reset() {
 	*x = RunStream{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_core_plugins_grpc_plugins_proto_msgTypes[3]
+		mi := &file_core_plugins_grpc_plugins_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -218,7 +396,7 @@ func (x *RunStream) String() string {
 func (*RunStream) ProtoMessage() {}
 
 func (x *RunStream) ProtoReflect() protoreflect.Message {
-	mi := &file_core_plugins_grpc_plugins_proto_msgTypes[3]
+	mi := &file_core_plugins_grpc_plugins_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -231,7 +409,7 @@ func (x *RunStream) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RunStream.ProtoReflect.Descriptor instead.
 func (*RunStream) Descriptor() ([]byte, []int) {
-	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{3}
+	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *RunStream) GetData() *DataStream {
@@ -248,6 +426,13 @@ func (x *RunStream) GetError() *Error {
 	return nil
 }
 
+func (x *RunStream) GetDone() *Done {
+	if x != nil {
+		return x.Done
+	}
+	return nil
+}
+
 type Empty struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -257,7 +442,7 @@ type Empty struct {
 func (x *Empty) Reset() {
 	*x = Empty{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_core_plugins_grpc_plugins_proto_msgTypes[4]
+		mi := &file_core_plugins_grpc_plugins_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -270,7 +455,7 @@ func (x *Empty) String() string {
 func (*Empty) ProtoMessage() {}
 
 func (x *Empty) ProtoReflect() protoreflect.Message {
-	mi := &file_core_plugins_grpc_plugins_proto_msgTypes[4]
+	mi := &file_core_plugins_grpc_plugins_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -283,7 +468,219 @@ func (x *Empty) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Empty.ProtoReflect.Descriptor instead.
 func (*Empty) Descriptor() ([]byte, []int) {
-	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{4}
+	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{5}
+}
+
+type PluginKind struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Kind Kind `protobuf:"varint,1,opt,name=kind,proto3,enum=grpc.Kind" json:"kind,omitempty"`
+}
+
+func (x *PluginKind) Reset() {
+	*x = PluginKind{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_core_plugins_grpc_plugins_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PluginKind) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginKind) ProtoMessage() {}
+
+func (x *PluginKind) ProtoReflect() protoreflect.Message {
+	mi := &file_core_plugins_grpc_plugins_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginKind.ProtoReflect.Descriptor instead.
+func (*PluginKind) Descriptor() ([]byte, []int) {
+	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PluginKind) GetKind() Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return Kind_KIND_UNKNOWN
+}
+
+type RunOptions struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Concurrency int32 `protobuf:"varint,1,opt,name=concurrency,proto3" json:"concurrency,omitempty"`
+	Ordered     bool  `protobuf:"varint,2,opt,name=ordered,proto3" json:"ordered,omitempty"`
+}
+
+func (x *RunOptions) Reset() {
+	*x = RunOptions{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_core_plugins_grpc_plugins_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RunOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunOptions) ProtoMessage() {}
+
+func (x *RunOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_core_plugins_grpc_plugins_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunOptions.ProtoReflect.Descriptor instead.
+func (*RunOptions) Descriptor() ([]byte, []int) {
+	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RunOptions) GetConcurrency() int32 {
+	if x != nil {
+		return x.Concurrency
+	}
+	return 0
+}
+
+func (x *RunOptions) GetOrdered() bool {
+	if x != nil {
+		return x.Ordered
+	}
+	return false
+}
+
+type RetryOptions struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Retry      int32 `protobuf:"varint,1,opt,name=retry,proto3" json:"retry,omitempty"`
+	Backoff    int64 `protobuf:"varint,2,opt,name=backoff,proto3" json:"backoff,omitempty"`
+	MaxBackoff int64 `protobuf:"varint,3,opt,name=maxBackoff,proto3" json:"maxBackoff,omitempty"`
+}
+
+func (x *RetryOptions) Reset() {
+	*x = RetryOptions{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_core_plugins_grpc_plugins_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RetryOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetryOptions) ProtoMessage() {}
+
+func (x *RetryOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_core_plugins_grpc_plugins_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetryOptions.ProtoReflect.Descriptor instead.
+func (*RetryOptions) Descriptor() ([]byte, []int) {
+	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *RetryOptions) GetRetry() int32 {
+	if x != nil {
+		return x.Retry
+	}
+	return 0
+}
+
+func (x *RetryOptions) GetBackoff() int64 {
+	if x != nil {
+		return x.Backoff
+	}
+	return 0
+}
+
+func (x *RetryOptions) GetMaxBackoff() int64 {
+	if x != nil {
+		return x.MaxBackoff
+	}
+	return 0
+}
+
+type TimeoutOptions struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Timeout int64 `protobuf:"varint,1,opt,name=timeout,proto3" json:"timeout,omitempty"`
+}
+
+func (x *TimeoutOptions) Reset() {
+	*x = TimeoutOptions{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_core_plugins_grpc_plugins_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TimeoutOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TimeoutOptions) ProtoMessage() {}
+
+func (x *TimeoutOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_core_plugins_grpc_plugins_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TimeoutOptions.ProtoReflect.Descriptor instead.
+func (*TimeoutOptions) Descriptor() ([]byte, []int) {
+	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TimeoutOptions) GetTimeout() int64 {
+	if x != nil {
+		return x.Timeout
+	}
+	return 0
 }
 
 type Error struct {
@@ -291,13 +688,14 @@ type Error struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Message string `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	Message string      `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	Input   *DataStream `protobuf:"bytes,2,opt,name=input,proto3" json:"input,omitempty"`
 }
 
 func (x *Error) Reset() {
 	*x = Error{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_core_plugins_grpc_plugins_proto_msgTypes[5]
+		mi := &file_core_plugins_grpc_plugins_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -310,7 +708,7 @@ func (x *Error) String() string {
 func (*Error) ProtoMessage() {}
 
 func (x *Error) ProtoReflect() protoreflect.Message {
-	mi := &file_core_plugins_grpc_plugins_proto_msgTypes[5]
+	mi := &file_core_plugins_grpc_plugins_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -323,7 +721,7 @@ func (x *Error) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Error.ProtoReflect.Descriptor instead.
 func (*Error) Descriptor() ([]byte, []int) {
-	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{5}
+	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *Error) GetMessage() string {
@@ -333,6 +731,68 @@ func (x *Error) GetMessage() string {
 	return ""
 }
 
+func (x *Error) GetInput() *DataStream {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
+type Done struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Input   *DataStream   `protobuf:"bytes,1,opt,name=input,proto3" json:"input,omitempty"`
+	Outputs []*DataStream `protobuf:"bytes,2,rep,name=outputs,proto3" json:"outputs,omitempty"`
+}
+
+func (x *Done) Reset() {
+	*x = Done{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_core_plugins_grpc_plugins_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Done) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Done) ProtoMessage() {}
+
+func (x *Done) ProtoReflect() protoreflect.Message {
+	mi := &file_core_plugins_grpc_plugins_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Done.ProtoReflect.Descriptor instead.
+func (*Done) Descriptor() ([]byte, []int) {
+	return file_core_plugins_grpc_plugins_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Done) GetInput() *DataStream {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
+func (x *Done) GetOutputs() []*DataStream {
+	if x != nil {
+		return x.Outputs
+	}
+	return nil
+}
+
 var File_core_plugins_grpc_plugins_proto protoreflect.FileDescriptor
 
 var file_core_plugins_grpc_plugins_proto_rawDesc = []byte{
@@ -343,37 +803,108 @@ var file_core_plugins_grpc_plugins_proto_rawDesc = []byte{
 	0x66, 0x69, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x63, 0x6f, 0x6e, 0x66, 0x69,
 	0x67, 0x22, 0x25, 0x0a, 0x0b, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61,
 	0x12, 0x16, 0x0a, 0x06, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c,
-	0x52, 0x06, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x22, 0x8a, 0x01, 0x0a, 0x0a, 0x44, 0x61, 0x74,
-	0x61, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x1c, 0x0a, 0x09, 0x70,
-	0x61, 0x72, 0x65, 0x6e, 0x74, 0x53, 0x72, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
-	0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x53, 0x72, 0x63, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18,
-	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x1e, 0x0a, 0x0a, 0x69, 0x73, 0x43,
-	0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x69,
-	0x73, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x74, 0x6f, 0x74,
-	0x61, 0x6c, 0x4c, 0x65, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x74, 0x6f, 0x74,
-	0x61, 0x6c, 0x4c, 0x65, 0x6e, 0x22, 0x54, 0x0a, 0x09, 0x52, 0x75, 0x6e, 0x53, 0x74, 0x72, 0x65,
-	0x61, 0x6d, 0x12, 0x24, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x10, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x53, 0x74, 0x72, 0x65,
-	0x61, 0x6d, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x21, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f,
-	0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x45,
-	0x72, 0x72, 0x6f, 0x72, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0x07, 0x0a, 0x05, 0x45,
-	0x6d, 0x70, 0x74, 0x79, 0x22, 0x21, 0x0a, 0x05, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x18, 0x0a,
-	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
-	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x32, 0x9e, 0x01, 0x0a, 0x0f, 0x49, 0x4f, 0x57, 0x6f,
-	0x72, 0x6b, 0x65, 0x72, 0x50, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x73, 0x12, 0x30, 0x0a, 0x0e, 0x47,
-	0x65, 0x74, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x12, 0x0b, 0x2e,
-	0x67, 0x72, 0x70, 0x63, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x11, 0x2e, 0x67, 0x72, 0x70,
-	0x63, 0x2e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x12, 0x2b, 0x0a,
-	0x06, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x14, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x52,
-	0x75, 0x6e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x1a, 0x0b, 0x2e,
-	0x67, 0x72, 0x70, 0x63, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x2c, 0x0a, 0x03, 0x52, 0x75,
-	0x6e, 0x12, 0x10, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x53, 0x74, 0x72,
-	0x65, 0x61, 0x6d, 0x1a, 0x0f, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x52, 0x75, 0x6e, 0x53, 0x74,
-	0x72, 0x65, 0x61, 0x6d, 0x28, 0x01, 0x30, 0x01, 0x42, 0x2d, 0x5a, 0x2b, 0x67, 0x69, 0x74, 0x68,
-	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x62, 0x65, 0x6e, 0x6a, 0x69, 0x2d, 0x62, 0x6f, 0x75,
-	0x2f, 0x6c, 0x75, 0x67, 0x68, 0x2f, 0x63, 0x6f, 0x72, 0x65, 0x2f, 0x70, 0x6c, 0x75, 0x67, 0x69,
-	0x6e, 0x73, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x52, 0x06, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x22, 0xbb, 0x02, 0x0a, 0x07, 0x48, 0x65, 0x61,
+	0x64, 0x65, 0x72, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x18, 0x0a, 0x07,
+	0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74,
+	0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x72, 0x75, 0x6e, 0x49, 0x64, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x72, 0x75, 0x6e, 0x49, 0x64, 0x12, 0x1c, 0x0a, 0x09,
+	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x6f,
+	0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x31, 0x0a, 0x06,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67,
+	0x72, 0x70, 0x63, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x2e, 0x56, 0x61, 0x6c, 0x75,
+	0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x12,
+	0x16, 0x0a, 0x06, 0x73, 0x70, 0x61, 0x6e, 0x49, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x73, 0x70, 0x61, 0x6e, 0x49, 0x64, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x08, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x1a, 0x39, 0x0a, 0x0b, 0x56,
+	0x61, 0x6c, 0x75, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65,
+	0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xb3, 0x01, 0x0a, 0x0a, 0x44, 0x61, 0x74, 0x61, 0x53,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x61, 0x72,
+	0x65, 0x6e, 0x74, 0x53, 0x72, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70, 0x61,
+	0x72, 0x65, 0x6e, 0x74, 0x53, 0x72, 0x63, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x1e, 0x0a, 0x0a, 0x69, 0x73, 0x43, 0x6f, 0x6d,
+	0x70, 0x6c, 0x65, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x69, 0x73, 0x43,
+	0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x74, 0x6f, 0x74, 0x61, 0x6c,
+	0x4c, 0x65, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x74, 0x6f, 0x74, 0x61, 0x6c,
+	0x4c, 0x65, 0x6e, 0x12, 0x27, 0x0a, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x48, 0x65, 0x61, 0x64,
+	0x65, 0x72, 0x73, 0x52, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x22, 0x74, 0x0a, 0x09,
+	0x52, 0x75, 0x6e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x24, 0x0a, 0x04, 0x64, 0x61, 0x74,
+	0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x44,
+	0x61, 0x74, 0x61, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12,
+	0x21, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b,
+	0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x52, 0x05, 0x65, 0x72, 0x72,
+	0x6f, 0x72, 0x12, 0x1e, 0x0a, 0x04, 0x64, 0x6f, 0x6e, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0a, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x44, 0x6f, 0x6e, 0x65, 0x52, 0x04, 0x64, 0x6f,
+	0x6e, 0x65, 0x22, 0x07, 0x0a, 0x05, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x2c, 0x0a, 0x0a, 0x50,
+	0x6c, 0x75, 0x67, 0x69, 0x6e, 0x4b, 0x69, 0x6e, 0x64, 0x12, 0x1e, 0x0a, 0x04, 0x6b, 0x69, 0x6e,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0a, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x4b,
+	0x69, 0x6e, 0x64, 0x52, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x22, 0x48, 0x0a, 0x0a, 0x52, 0x75, 0x6e,
+	0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x6f, 0x6e, 0x63, 0x75,
+	0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x63, 0x6f,
+	0x6e, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x6f, 0x72, 0x64,
+	0x65, 0x72, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x6f, 0x72, 0x64, 0x65,
+	0x72, 0x65, 0x64, 0x22, 0x5e, 0x0a, 0x0c, 0x52, 0x65, 0x74, 0x72, 0x79, 0x4f, 0x70, 0x74, 0x69,
+	0x6f, 0x6e, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x72, 0x65, 0x74, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x05, 0x72, 0x65, 0x74, 0x72, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x62, 0x61, 0x63,
+	0x6b, 0x6f, 0x66, 0x66, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x62, 0x61, 0x63, 0x6b,
+	0x6f, 0x66, 0x66, 0x12, 0x1e, 0x0a, 0x0a, 0x6d, 0x61, 0x78, 0x42, 0x61, 0x63, 0x6b, 0x6f, 0x66,
+	0x66, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x6d, 0x61, 0x78, 0x42, 0x61, 0x63, 0x6b,
+	0x6f, 0x66, 0x66, 0x22, 0x2a, 0x0a, 0x0e, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x4f, 0x70,
+	0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x22,
+	0x49, 0x0a, 0x05, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x12, 0x26, 0x0a, 0x05, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x10, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x53, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x52, 0x05, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x22, 0x5a, 0x0a, 0x04, 0x44, 0x6f,
+	0x6e, 0x65, 0x12, 0x26, 0x0a, 0x05, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x10, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x53, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x52, 0x05, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x12, 0x2a, 0x0a, 0x07, 0x6f, 0x75,
+	0x74, 0x70, 0x75, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x67, 0x72,
+	0x70, 0x63, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x07, 0x6f,
+	0x75, 0x74, 0x70, 0x75, 0x74, 0x73, 0x2a, 0x70, 0x0a, 0x04, 0x4b, 0x69, 0x6e, 0x64, 0x12, 0x10,
+	0x0a, 0x0c, 0x4b, 0x49, 0x4e, 0x44, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00,
+	0x12, 0x11, 0x0a, 0x0d, 0x4b, 0x49, 0x4e, 0x44, 0x5f, 0x50, 0x52, 0x4f, 0x44, 0x55, 0x43, 0x45,
+	0x52, 0x10, 0x01, 0x12, 0x11, 0x0a, 0x0d, 0x4b, 0x49, 0x4e, 0x44, 0x5f, 0x43, 0x4f, 0x4e, 0x53,
+	0x55, 0x4d, 0x45, 0x52, 0x10, 0x02, 0x12, 0x0f, 0x0a, 0x0b, 0x4b, 0x49, 0x4e, 0x44, 0x5f, 0x57,
+	0x4f, 0x52, 0x4b, 0x45, 0x52, 0x10, 0x03, 0x12, 0x0f, 0x0a, 0x0b, 0x4b, 0x49, 0x4e, 0x44, 0x5f,
+	0x52, 0x55, 0x4e, 0x4e, 0x45, 0x52, 0x10, 0x04, 0x12, 0x0e, 0x0a, 0x0a, 0x4b, 0x49, 0x4e, 0x44,
+	0x5f, 0x47, 0x52, 0x41, 0x50, 0x48, 0x10, 0x05, 0x32, 0x81, 0x03, 0x0a, 0x0f, 0x49, 0x4f, 0x57,
+	0x6f, 0x72, 0x6b, 0x65, 0x72, 0x50, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x73, 0x12, 0x30, 0x0a, 0x0e,
+	0x47, 0x65, 0x74, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x12, 0x0b,
+	0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x11, 0x2e, 0x67, 0x72,
+	0x70, 0x63, 0x2e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x12, 0x2b,
+	0x0a, 0x06, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x14, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e,
+	0x52, 0x75, 0x6e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x1a, 0x0b,
+	0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x2c, 0x0a, 0x03, 0x52,
+	0x75, 0x6e, 0x12, 0x10, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x53, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x1a, 0x0f, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x52, 0x75, 0x6e, 0x53,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x28, 0x01, 0x30, 0x01, 0x12, 0x28, 0x0a, 0x07, 0x47, 0x65, 0x74,
+	0x4b, 0x69, 0x6e, 0x64, 0x12, 0x0b, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x45, 0x6d, 0x70, 0x74,
+	0x79, 0x1a, 0x10, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x50, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x4b,
+	0x69, 0x6e, 0x64, 0x12, 0x2e, 0x0a, 0x0d, 0x53, 0x65, 0x74, 0x52, 0x75, 0x6e, 0x4f, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x12, 0x10, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x52, 0x75, 0x6e, 0x4f,
+	0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x1a, 0x0b, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x45, 0x6d,
+	0x70, 0x74, 0x79, 0x12, 0x2b, 0x0a, 0x08, 0x53, 0x65, 0x74, 0x52, 0x65, 0x74, 0x72, 0x79, 0x12,
+	0x12, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x52, 0x65, 0x74, 0x72, 0x79, 0x4f, 0x70, 0x74, 0x69,
+	0x6f, 0x6e, 0x73, 0x1a, 0x0b, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79,
+	0x12, 0x2f, 0x0a, 0x0a, 0x53, 0x65, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x12, 0x14,
+	0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x4f, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x1a, 0x0b, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x45, 0x6d, 0x70, 0x74,
+	0x79, 0x12, 0x29, 0x0a, 0x0d, 0x53, 0x65, 0x74, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69,
+	0x6e, 0x74, 0x12, 0x0b, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a,
+	0x0b, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x42, 0x2d, 0x5a, 0x2b,
+	0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x62, 0x65, 0x6e, 0x6a, 0x69,
+	0x2d, 0x62, 0x6f, 0x75, 0x2f, 0x6c, 0x75, 0x67, 0x68, 0x2f, 0x63, 0x6f, 0x72, 0x65, 0x2f, 0x70,
+	0x6c, 0x75, 0x67, 0x69, 0x6e, 0x73, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
 }
 
 var (
@@ -388,29 +919,55 @@ func file_core_plugins_grpc_plugins_proto_rawDescGZIP() []byte {
 	return file_core_plugins_grpc_plugins_proto_rawDescData
 }
 
-var file_core_plugins_grpc_plugins_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_core_plugins_grpc_plugins_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_core_plugins_grpc_plugins_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
 var file_core_plugins_grpc_plugins_proto_goTypes = []interface{}{
-	(*RunInputConfig)(nil), // 0: grpc.RunInputConfig
-	(*InputSchema)(nil),    // 1: grpc.InputSchema
-	(*DataStream)(nil),     // 2: grpc.DataStream
-	(*RunStream)(nil),      // 3: grpc.RunStream
-	(*Empty)(nil),          // 4: grpc.Empty
-	(*Error)(nil),          // 5: grpc.Error
+	(Kind)(0),              // 0: grpc.Kind
+	(*RunInputConfig)(nil), // 1: grpc.RunInputConfig
+	(*InputSchema)(nil),    // 2: grpc.InputSchema
+	(*Headers)(nil),        // 3: grpc.Headers
+	(*DataStream)(nil),     // 4: grpc.DataStream
+	(*RunStream)(nil),      // 5: grpc.RunStream
+	(*Empty)(nil),          // 6: grpc.Empty
+	(*PluginKind)(nil),     // 7: grpc.PluginKind
+	(*RunOptions)(nil),     // 8: grpc.RunOptions
+	(*RetryOptions)(nil),   // 9: grpc.RetryOptions
+	(*TimeoutOptions)(nil), // 10: grpc.TimeoutOptions
+	(*Error)(nil),          // 11: grpc.Error
+	(*Done)(nil),           // 12: grpc.Done
+	nil,                    // 13: grpc.Headers.ValuesEntry
 }
 var file_core_plugins_grpc_plugins_proto_depIdxs = []int32{
-	2, // 0: grpc.RunStream.data:type_name -> grpc.DataStream
-	5, // 1: grpc.RunStream.error:type_name -> grpc.Error
-	4, // 2: grpc.IOWorkerPlugins.GetInputSchema:input_type -> grpc.Empty
-	0, // 3: grpc.IOWorkerPlugins.Config:input_type -> grpc.RunInputConfig
-	2, // 4: grpc.IOWorkerPlugins.Run:input_type -> grpc.DataStream
-	1, // 5: grpc.IOWorkerPlugins.GetInputSchema:output_type -> grpc.InputSchema
-	4, // 6: grpc.IOWorkerPlugins.Config:output_type -> grpc.Empty
-	3, // 7: grpc.IOWorkerPlugins.Run:output_type -> grpc.RunStream
-	5, // [5:8] is the sub-list for method output_type
-	2, // [2:5] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	13, // 0: grpc.Headers.values:type_name -> grpc.Headers.ValuesEntry
+	3,  // 1: grpc.DataStream.headers:type_name -> grpc.Headers
+	4,  // 2: grpc.RunStream.data:type_name -> grpc.DataStream
+	11, // 3: grpc.RunStream.error:type_name -> grpc.Error
+	12, // 4: grpc.RunStream.done:type_name -> grpc.Done
+	0,  // 5: grpc.PluginKind.kind:type_name -> grpc.Kind
+	4,  // 6: grpc.Error.input:type_name -> grpc.DataStream
+	4,  // 7: grpc.Done.input:type_name -> grpc.DataStream
+	4,  // 8: grpc.Done.outputs:type_name -> grpc.DataStream
+	6,  // 9: grpc.IOWorkerPlugins.GetInputSchema:input_type -> grpc.Empty
+	1,  // 10: grpc.IOWorkerPlugins.Config:input_type -> grpc.RunInputConfig
+	4,  // 11: grpc.IOWorkerPlugins.Run:input_type -> grpc.DataStream
+	6,  // 12: grpc.IOWorkerPlugins.GetKind:input_type -> grpc.Empty
+	8,  // 13: grpc.IOWorkerPlugins.SetRunOptions:input_type -> grpc.RunOptions
+	9,  // 14: grpc.IOWorkerPlugins.SetRetry:input_type -> grpc.RetryOptions
+	10, // 15: grpc.IOWorkerPlugins.SetTimeout:input_type -> grpc.TimeoutOptions
+	6,  // 16: grpc.IOWorkerPlugins.SetCheckpoint:input_type -> grpc.Empty
+	2,  // 17: grpc.IOWorkerPlugins.GetInputSchema:output_type -> grpc.InputSchema
+	6,  // 18: grpc.IOWorkerPlugins.Config:output_type -> grpc.Empty
+	5,  // 19: grpc.IOWorkerPlugins.Run:output_type -> grpc.RunStream
+	7,  // 20: grpc.IOWorkerPlugins.GetKind:output_type -> grpc.PluginKind
+	6,  // 21: grpc.IOWorkerPlugins.SetRunOptions:output_type -> grpc.Empty
+	6,  // 22: grpc.IOWorkerPlugins.SetRetry:output_type -> grpc.Empty
+	6,  // 23: grpc.IOWorkerPlugins.SetTimeout:output_type -> grpc.Empty
+	6,  // 24: grpc.IOWorkerPlugins.SetCheckpoint:output_type -> grpc.Empty
+	17, // [17:25] is the sub-list for method output_type
+	9,  // [9:17] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_core_plugins_grpc_plugins_proto_init() }
@@ -444,7 +1001,7 @@ func file_core_plugins_grpc_plugins_proto_init() {
 			}
 		}
 		file_core_plugins_grpc_plugins_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*DataStream); i {
+			switch v := v.(*Headers); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -456,7 +1013,7 @@ func file_core_plugins_grpc_plugins_proto_init() {
 			}
 		}
 		file_core_plugins_grpc_plugins_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*RunStream); i {
+			switch v := v.(*DataStream); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -468,7 +1025,7 @@ func file_core_plugins_grpc_plugins_proto_init() {
 			}
 		}
 		file_core_plugins_grpc_plugins_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Empty); i {
+			switch v := v.(*RunStream); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -480,6 +1037,66 @@ func file_core_plugins_grpc_plugins_proto_init() {
 			}
 		}
 		file_core_plugins_grpc_plugins_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Empty); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_core_plugins_grpc_plugins_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PluginKind); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_core_plugins_grpc_plugins_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RunOptions); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_core_plugins_grpc_plugins_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RetryOptions); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_core_plugins_grpc_plugins_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TimeoutOptions); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_core_plugins_grpc_plugins_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Error); i {
 			case 0:
 				return &v.state
@@ -491,19 +1108,32 @@ func file_core_plugins_grpc_plugins_proto_init() {
 				return nil
 			}
 		}
+		file_core_plugins_grpc_plugins_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Done); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_core_plugins_grpc_plugins_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   6,
+			NumEnums:      1,
+			NumMessages:   13,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_core_plugins_grpc_plugins_proto_goTypes,
 		DependencyIndexes: file_core_plugins_grpc_plugins_proto_depIdxs,
+		EnumInfos:         file_core_plugins_grpc_plugins_proto_enumTypes,
 		MessageInfos:      file_core_plugins_grpc_plugins_proto_msgTypes,
 	}.Build()
 	File_core_plugins_grpc_plugins_proto = out.File
@@ -22,6 +22,11 @@ const (
 	IOWorkerPlugins_GetInputSchema_FullMethodName = "/grpc.IOWorkerPlugins/GetInputSchema"
 	IOWorkerPlugins_Config_FullMethodName         = "/grpc.IOWorkerPlugins/Config"
 	IOWorkerPlugins_Run_FullMethodName            = "/grpc.IOWorkerPlugins/Run"
+	IOWorkerPlugins_GetKind_FullMethodName        = "/grpc.IOWorkerPlugins/GetKind"
+	IOWorkerPlugins_SetRunOptions_FullMethodName  = "/grpc.IOWorkerPlugins/SetRunOptions"
+	IOWorkerPlugins_SetRetry_FullMethodName       = "/grpc.IOWorkerPlugins/SetRetry"
+	IOWorkerPlugins_SetTimeout_FullMethodName     = "/grpc.IOWorkerPlugins/SetTimeout"
+	IOWorkerPlugins_SetCheckpoint_FullMethodName  = "/grpc.IOWorkerPlugins/SetCheckpoint"
 )
 
 // IOWorkerPluginsClient is the client API for IOWorkerPlugins service.
@@ -31,6 +36,11 @@ type IOWorkerPluginsClient interface {
 	GetInputSchema(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*InputSchema, error)
 	Config(ctx context.Context, in *RunInputConfig, opts ...grpc.CallOption) (*Empty, error)
 	Run(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[DataStream, RunStream], error)
+	GetKind(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*PluginKind, error)
+	SetRunOptions(ctx context.Context, in *RunOptions, opts ...grpc.CallOption) (*Empty, error)
+	SetRetry(ctx context.Context, in *RetryOptions, opts ...grpc.CallOption) (*Empty, error)
+	SetTimeout(ctx context.Context, in *TimeoutOptions, opts ...grpc.CallOption) (*Empty, error)
+	SetCheckpoint(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
 }
 
 type iOWorkerPluginsClient struct {
@@ -74,6 +84,56 @@ func (c *iOWorkerPluginsClient) Run(ctx context.Context, opts ...grpc.CallOption
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type IOWorkerPlugins_RunClient = grpc.BidiStreamingClient[DataStream, RunStream]
 
+func (c *iOWorkerPluginsClient) GetKind(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*PluginKind, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PluginKind)
+	err := c.cc.Invoke(ctx, IOWorkerPlugins_GetKind_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOWorkerPluginsClient) SetRunOptions(ctx context.Context, in *RunOptions, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, IOWorkerPlugins_SetRunOptions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOWorkerPluginsClient) SetRetry(ctx context.Context, in *RetryOptions, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, IOWorkerPlugins_SetRetry_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOWorkerPluginsClient) SetTimeout(ctx context.Context, in *TimeoutOptions, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, IOWorkerPlugins_SetTimeout_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOWorkerPluginsClient) SetCheckpoint(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, IOWorkerPlugins_SetCheckpoint_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // IOWorkerPluginsServer is the server API for IOWorkerPlugins service.
 // All implementations must embed UnimplementedIOWorkerPluginsServer
 // for forward compatibility.
@@ -81,6 +141,11 @@ type IOWorkerPluginsServer interface {
 	GetInputSchema(context.Context, *Empty) (*InputSchema, error)
 	Config(context.Context, *RunInputConfig) (*Empty, error)
 	Run(grpc.BidiStreamingServer[DataStream, RunStream]) error
+	GetKind(context.Context, *Empty) (*PluginKind, error)
+	SetRunOptions(context.Context, *RunOptions) (*Empty, error)
+	SetRetry(context.Context, *RetryOptions) (*Empty, error)
+	SetTimeout(context.Context, *TimeoutOptions) (*Empty, error)
+	SetCheckpoint(context.Context, *Empty) (*Empty, error)
 	mustEmbedUnimplementedIOWorkerPluginsServer()
 }
 
@@ -100,6 +165,21 @@ func (UnimplementedIOWorkerPluginsServer) Config(context.Context, *RunInputConfi
 func (UnimplementedIOWorkerPluginsServer) Run(grpc.BidiStreamingServer[DataStream, RunStream]) error {
 	return status.Errorf(codes.Unimplemented, "method Run not implemented")
 }
+func (UnimplementedIOWorkerPluginsServer) GetKind(context.Context, *Empty) (*PluginKind, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetKind not implemented")
+}
+func (UnimplementedIOWorkerPluginsServer) SetRunOptions(context.Context, *RunOptions) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRunOptions not implemented")
+}
+func (UnimplementedIOWorkerPluginsServer) SetRetry(context.Context, *RetryOptions) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRetry not implemented")
+}
+func (UnimplementedIOWorkerPluginsServer) SetTimeout(context.Context, *TimeoutOptions) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetTimeout not implemented")
+}
+func (UnimplementedIOWorkerPluginsServer) SetCheckpoint(context.Context, *Empty) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetCheckpoint not implemented")
+}
 func (UnimplementedIOWorkerPluginsServer) mustEmbedUnimplementedIOWorkerPluginsServer() {}
 func (UnimplementedIOWorkerPluginsServer) testEmbeddedByValue()                         {}
 
@@ -164,6 +244,96 @@ func _IOWorkerPlugins_Run_Handler(srv interface{}, stream grpc.ServerStream) err
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type IOWorkerPlugins_RunServer = grpc.BidiStreamingServer[DataStream, RunStream]
 
+func _IOWorkerPlugins_GetKind_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOWorkerPluginsServer).GetKind(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOWorkerPlugins_GetKind_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOWorkerPluginsServer).GetKind(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOWorkerPlugins_SetRunOptions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunOptions)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOWorkerPluginsServer).SetRunOptions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOWorkerPlugins_SetRunOptions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOWorkerPluginsServer).SetRunOptions(ctx, req.(*RunOptions))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOWorkerPlugins_SetRetry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetryOptions)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOWorkerPluginsServer).SetRetry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOWorkerPlugins_SetRetry_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOWorkerPluginsServer).SetRetry(ctx, req.(*RetryOptions))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOWorkerPlugins_SetTimeout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TimeoutOptions)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOWorkerPluginsServer).SetTimeout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOWorkerPlugins_SetTimeout_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOWorkerPluginsServer).SetTimeout(ctx, req.(*TimeoutOptions))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOWorkerPlugins_SetCheckpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOWorkerPluginsServer).SetCheckpoint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOWorkerPlugins_SetCheckpoint_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOWorkerPluginsServer).SetCheckpoint(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // IOWorkerPlugins_ServiceDesc is the grpc.ServiceDesc for IOWorkerPlugins service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -179,6 +349,26 @@ var IOWorkerPlugins_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Config",
 			Handler:    _IOWorkerPlugins_Config_Handler,
 		},
+		{
+			MethodName: "GetKind",
+			Handler:    _IOWorkerPlugins_GetKind_Handler,
+		},
+		{
+			MethodName: "SetRunOptions",
+			Handler:    _IOWorkerPlugins_SetRunOptions_Handler,
+		},
+		{
+			MethodName: "SetRetry",
+			Handler:    _IOWorkerPlugins_SetRetry_Handler,
+		},
+		{
+			MethodName: "SetTimeout",
+			Handler:    _IOWorkerPlugins_SetTimeout_Handler,
+		},
+		{
+			MethodName: "SetCheckpoint",
+			Handler:    _IOWorkerPlugins_SetCheckpoint_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -8,11 +8,12 @@ package load
 // The loader function should return the plugin or an error if the plugin could not be loaded.
 // You can registeer a default loader that will be used if no loader is found for the requested plugin name. By default the default loader is the GRPC Loader
 // The loaded plugin can either be
-//    - graph.IOWorker[[]byte
-//    - graph.Worker[[]byte]
-//    - graph.Producer[[]byte]
-//    - graph.Consumer[[]byte]
-//    - graph.Runner[[]byte]
+//    - graph.IOWorker[K]
+//    - graph.Worker[K]
+//    - graph.Producer[K]
+//    - graph.Consumer[K]
+//    - graph.Runner[K]
+// with K being message.Message or []byte. Plugins of []byte are adapted to propagate the headers of the messages.
 // if it is not one of these types, the loader will return an error. `ErrPluginTypeNotSupported`
 
 // This package expose Loadable interface that is used to load plugins. You register Loadable to load your plugins.
@@ -26,11 +27,14 @@ import (
 	"sync"
 
 	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
 	"github.com/benji-bou/lugh/core/plugins/pluginapi"
 )
 
 var ErrPluginTypeNotSupported = fmt.Errorf("plugin type not supported")
 
+// ConfigPlugin validates `config` against the schema returned by `GetInputSchema`, if any, and configures the plugin.
+// A plugin whose schema can't be fetched is configured without validation.
 func ConfigPlugin(pluginConfigurer pluginapi.PluginConfigurer, config any) error {
 	if config == nil {
 		return nil
@@ -39,13 +43,24 @@ func ConfigPlugin(pluginConfigurer pluginapi.PluginConfigurer, config any) error
 	if err != nil {
 		return err
 	}
+	schema, err := pluginConfigurer.GetInputSchema()
+	if err != nil {
+		slog.Warn("plugin config schema unavailable, the config is not validated", "error", err)
+	} else if err := ValidateConfig(schema, configBytes); err != nil {
+		return err
+	}
 	return pluginConfigurer.Config(configBytes)
 }
 
-func Worker(name string, path string, config any) (graph.IOWorker[[]byte], error) {
+func Worker(name string, path string, config any) (graph.IOWorker[message.Message], error) {
 	return Default().Load(name, path, config)
 }
 
+// IsRegistered tells whether the plugin `name` has its own loader, like the static plugins, in the default loader.
+func IsRegistered(name string) bool {
+	return Default().IsRegistered(name)
+}
+
 func RegisterDefault(defaultLoader Loadable) {
 	Default().RegisterDefault(defaultLoader)
 }
@@ -57,9 +72,20 @@ func Register(name string, loader Loadable, optionalName ...string) {
 	}
 }
 
+// Deprecate marks `alias` as a deprecated name of the plugin `name` in the default loader.
+func Deprecate(alias, name string) {
+	Default().Deprecate(alias, name)
+}
+
+// Deprecated returns the plugin replacing `alias` when it is a deprecated name, in the default loader.
+func Deprecated(alias string) (string, bool) {
+	return Default().Deprecated(alias)
+}
+
 // Loader is internal struct to manage plugins loaders.
 type Loader struct {
 	pluginsLoader map[string]Loadable
+	deprecated    map[string]string
 	defaultLoader Loadable
 	rwMutex       sync.RWMutex
 }
@@ -77,6 +103,7 @@ func Default(defaultLoader ...Loadable) *Loader {
 			fmt.Printf("default loader: %T\n", defLoader)
 			return &Loader{
 				pluginsLoader: make(map[string]Loadable),
+				deprecated:    make(map[string]string),
 				defaultLoader: defLoader,
 				rwMutex:       sync.RWMutex{},
 			}
@@ -99,11 +126,38 @@ func (l *Loader) Register(name string, loader Loadable) {
 	l.pluginsLoader[name] = loader
 }
 
+// Deprecate marks `alias` as a deprecated name of the plugin `name`. It is still loaded, with a warning.
+func (l *Loader) Deprecate(alias, name string) {
+	l.rwMutex.Lock()
+	defer l.rwMutex.Unlock()
+	l.deprecated[alias] = name
+}
+
+// Deprecated returns the plugin replacing `alias` when it is a deprecated name.
+func (l *Loader) Deprecated(alias string) (string, bool) {
+	l.rwMutex.RLock()
+	defer l.rwMutex.RUnlock()
+	name, ok := l.deprecated[alias]
+	return name, ok
+}
+
+// IsRegistered tells whether the plugin `name` has its own loader, the others are loaded by the default loader.
+func (l *Loader) IsRegistered(name string) bool {
+	l.rwMutex.RLock()
+	defer l.rwMutex.RUnlock()
+	_, ok := l.pluginsLoader[name]
+	return ok
+}
+
 // Load loads a IOWorker by name and path and config.
-func (l *Loader) Load(name string, path string, config any) (graph.IOWorker[[]byte], error) {
+func (l *Loader) Load(name string, path string, config any) (graph.IOWorker[message.Message], error) {
 	l.rwMutex.RLock()
 	loader, ok := l.pluginsLoader[name]
+	replacement, deprecated := l.deprecated[name]
 	l.rwMutex.RUnlock()
+	if deprecated {
+		slog.Warn("plugin name is deprecated", "plugin", name, "use", replacement)
+	}
 	if !ok {
 		slog.Info("plugin loader not found, using default loader", "plugin", name)
 		loader = l.defaultLoader
@@ -115,7 +169,7 @@ func (l *Loader) Load(name string, path string, config any) (graph.IOWorker[[]by
 	return l.convertPluginToIOWorker(plugin)
 }
 
-// WrapLoader wraps a plugin loader with a middleware. This is useful for adding data to the plugin config. or override the result graph.IOWorker[[]byte]
+// WrapLoader wraps a plugin loader with a middleware. This is useful for adding data to the plugin config. or override the result graph.IOWorker[message.Message]
 func (l *Loader) WrapLoader(name string, next MiddlewareLoader) {
 	l.rwMutex.Lock()
 	defer l.rwMutex.Unlock()
@@ -128,19 +182,10 @@ func (l *Loader) WrapLoader(name string, next MiddlewareLoader) {
 }
 
 // convertPluginToIOWorker converts a plugin to a IOWorker.
-func (l *Loader) convertPluginToIOWorker(plugin any) (graph.IOWorker[[]byte], error) {
-	switch plugin.(type) {
-	case graph.IOWorker[[]byte]:
-		return plugin.(graph.IOWorker[[]byte]), nil
-	case graph.Worker[[]byte]:
-		return graph.NewIOWorkerFromWorker(plugin.(graph.Worker[[]byte])), nil
-	case graph.Producer[[]byte]:
-		return graph.NewIOWorkerFromProducer(plugin.(graph.Producer[[]byte])), nil
-	case graph.Consumer[[]byte]:
-		return graph.NewIOWorkerFromConsumer(plugin.(graph.Consumer[[]byte])), nil
-	case graph.Runner[[]byte]:
-		return graph.NewIOWorkerFromRunner(plugin.(graph.Runner[[]byte])), nil
-	default:
+func (l *Loader) convertPluginToIOWorker(plugin any) (graph.IOWorker[message.Message], error) {
+	worker, ok := message.IOWorker(plugin)
+	if !ok {
 		return nil, fmt.Errorf("%w: type given is %T, ", ErrPluginTypeNotSupported, plugin)
 	}
+	return worker, nil
 }
@@ -0,0 +1,78 @@
+package load
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+var ErrInvalidConfig = errors.New("invalid plugin config")
+
+// schemaURL names the schema of a plugin config in the compiler, no other resource is loaded.
+const schemaURL = "lugh://plugin/config.schema.json"
+
+// ValidateConfig validates the JSON `config` against the JSON Schema `schema`, draft 2020-12 unless its `$schema`
+// tells otherwise. An empty schema accepts any config. Every invalid field of the config is reported.
+func ValidateConfig(schema []byte, config []byte) error {
+	if len(bytes.TrimSpace(schema)) == 0 || string(bytes.TrimSpace(schema)) == "null" {
+		return nil
+	}
+	schemaDoc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return fmt.Errorf("plugin config schema: %w", err)
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	if err := compiler.AddResource(schemaURL, schemaDoc); err != nil {
+		return fmt.Errorf("plugin config schema: %w", err)
+	}
+	compiled, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return fmt.Errorf("plugin config schema: %w", err)
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(config))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	var validationErr *jsonschema.ValidationError
+	if err := compiled.Validate(instance); errors.As(err, &validationErr) {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(configProblems(validationErr), "; "))
+	} else if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	return nil
+}
+
+// configProblems returns the deepest errors of the validation, each prefixed by the field it is about.
+func configProblems(err *jsonschema.ValidationError) []string {
+	if len(err.Causes) > 0 {
+		res := []string{}
+		for _, cause := range err.Causes {
+			res = append(res, configProblems(cause)...)
+		}
+		return res
+	}
+	message := "invalid"
+	if output := err.BasicOutput(); output.Error != nil {
+		message = output.Error.String()
+	}
+	return []string{fmt.Sprintf("%s: %s", fieldPath(err.InstanceLocation), message)}
+}
+
+// fieldPath formats the location of a value in the config, like `config.routes[0].expr`.
+func fieldPath(location []string) string {
+	b := &strings.Builder{}
+	b.WriteString("config")
+	for _, token := range location {
+		if _, err := strconv.Atoi(token); err == nil {
+			fmt.Fprintf(b, "[%s]", token)
+			continue
+		}
+		b.WriteString("." + token)
+	}
+	return b.String()
+}
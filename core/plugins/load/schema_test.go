@@ -0,0 +1,77 @@
+package load_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/benji-bou/lugh/core/plugins/load"
+	"github.com/benji-bou/lugh/core/plugins/pluginapi"
+)
+
+type route struct {
+	Port string `json:"port" required:"true"`
+	Expr string `json:"expr"`
+}
+
+type routeConfig struct {
+	Routes    []route `json:"routes"`
+	Unmatched string  `json:"unmatched"`
+	Workers   int     `json:"workers" minimum:"1"`
+}
+
+type configurer struct {
+	config []byte
+}
+
+func (c *configurer) GetInputSchema() ([]byte, error) {
+	return pluginapi.Schema(routeConfig{})
+}
+
+func (c *configurer) Config(config []byte) error {
+	c.config = config
+	return nil
+}
+
+func TestSchema(t *testing.T) {
+	schema, err := pluginapi.Schema(routeConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := map[string]any{}
+	if err := json.Unmarshal(schema, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["$schema"] != pluginapi.SchemaDraft {
+		t.Errorf("expected a draft 2020-12 schema, got %s", schema)
+	}
+	if err := load.ValidateConfig(schema, []byte(`{"routes":[{"port":"errors","expr":".status >= 400"}],"workers":2}`)); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+	err = load.ValidateConfig(schema, []byte(`{"routes":[{"expr":".status >= 400"}],"unmatched":1,"workers":0}`))
+	if !errors.Is(err, load.ErrInvalidConfig) {
+		t.Fatalf("expected an invalid config, got %v", err)
+	}
+	for _, field := range []string{"config.routes[0]: missing property 'port'", "config.unmatched: got number, want string", "config.workers:"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected %q to be reported in %q", field, err)
+		}
+	}
+	if err := load.ValidateConfig(nil, []byte(`{"any": "config"}`)); err != nil {
+		t.Errorf("expected no schema to accept any config, got %v", err)
+	}
+}
+
+func TestConfigPluginValidates(t *testing.T) {
+	plugin := &configurer{}
+	if err := load.ConfigPlugin(plugin, map[string]any{"unmatched": []string{"ok"}}); !errors.Is(err, load.ErrInvalidConfig) {
+		t.Errorf("expected the config to be rejected, got %v", err)
+	}
+	if plugin.config != nil {
+		t.Errorf("an invalid config should not reach the plugin")
+	}
+	if err := load.ConfigPlugin(plugin, map[string]any{"unmatched": "ok"}); err != nil || string(plugin.config) != `{"unmatched":"ok"}` {
+		t.Errorf("expected the config to reach the plugin, got %s: %v", plugin.config, err)
+	}
+}
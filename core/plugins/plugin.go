@@ -3,8 +3,11 @@ package plugins
 import (
 	"fmt"
 
+	"github.com/benji-bou/lugh/core/message"
 	"github.com/benji-bou/lugh/core/plugins/load"
 	"github.com/benji-bou/lugh/core/plugins/static/data/base64"
+	"github.com/benji-bou/lugh/core/plugins/static/data/batch"
+	"github.com/benji-bou/lugh/core/plugins/static/data/filter"
 	"github.com/benji-bou/lugh/core/plugins/static/data/forward"
 	"github.com/benji-bou/lugh/core/plugins/static/data/insert"
 	"github.com/benji-bou/lugh/core/plugins/static/data/regex"
@@ -12,6 +15,7 @@ import (
 	"github.com/benji-bou/lugh/core/plugins/static/data/template"
 	"github.com/benji-bou/lugh/core/plugins/static/fileinput"
 	"github.com/benji-bou/lugh/core/plugins/static/include"
+	"github.com/benji-bou/lugh/core/plugins/static/join"
 	"github.com/benji-bou/lugh/core/plugins/static/pipe"
 	"github.com/benji-bou/lugh/core/plugins/static/stdoutput"
 	tpl "github.com/benji-bou/lugh/core/template"
@@ -21,7 +25,7 @@ import (
 func InitLoader() {
 	load.RegisterDefault(load.Configure(load.GRPC))
 	load.Register("forward", load.Get(func() any {
-		return forward.Worker[[]byte]()
+		return forward.Worker[message.Message]()
 	}))
 	load.Register("fileinput", load.Get(func() any {
 		return fileinput.New()
@@ -29,6 +33,7 @@ func InitLoader() {
 	load.Register("pipe", load.Configure(func(name, path string) (any, error) {
 		return pipe.New(path), nil
 	}), "transform")
+	load.Deprecate("transform", "pipe")
 	load.Register("output", load.Get(func() any {
 		return stdoutput.New()
 	}), "stdoutput")
@@ -42,6 +47,27 @@ func InitLoader() {
 	load.Register("base64", load.Get(func() any {
 		return base64.Base64Decode()
 	}))
+	load.Register("batch", load.ConfigAsMap(func(name, path string, config map[string]any) (any, error) {
+		var batchConfig batch.Config
+		if err := decodeConfig(config, &batchConfig); err != nil {
+			return nil, fmt.Errorf("batch loader: decode config: %w", err)
+		}
+		return batch.New(batchConfig)
+	}))
+	load.Register("unbatch", load.ConfigAsMap(func(name, path string, config map[string]any) (any, error) {
+		var batchConfig batch.Config
+		if err := decodeConfig(config, &batchConfig); err != nil {
+			return nil, fmt.Errorf("unbatch loader: decode config: %w", err)
+		}
+		return batch.Unbatch(batchConfig)
+	}))
+	load.Register("filter", load.ConfigAsMap(func(name, path string, config map[string]any) (any, error) {
+		var filterConfig filter.Config
+		if err := decodeConfig(config, &filterConfig); err != nil {
+			return nil, fmt.Errorf("filter loader: decode config: %w", err)
+		}
+		return filter.Worker(filterConfig)
+	}), "route")
 	load.Register("insert", load.ConfigAsMap(func(name, path string, config map[string]any) (any, error) {
 		insertStr := "\n"
 		if s, ok := config["content"].(string); ok {
@@ -65,6 +91,7 @@ func InitLoader() {
 		}
 		return template.Worker(tplConfig)
 	}), "goTemplate")
+	load.Deprecate("goTemplate", "template")
 
 	load.Register("include", load.ConfigAsMap(func(name, path string, config map[string]any) (any, error) {
 		var includeConfig include.Config
@@ -73,4 +100,24 @@ func InitLoader() {
 		}
 		return include.Worker[tpl.Stage](includeConfig)
 	}))
+
+	load.Register("join", load.ConfigAsMap(func(name, path string, config map[string]any) (any, error) {
+		var joinConfig join.Config
+		if err := decodeConfig(config, &joinConfig); err != nil {
+			return nil, fmt.Errorf("join loader: decode config: %w", err)
+		}
+		return join.New(joinConfig)
+	}))
+}
+
+// decodeConfig decodes the config of a plugin, the durations are written like "30s".
+func decodeConfig(config map[string]any, result any) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     result,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(config)
 }
@@ -2,6 +2,7 @@ package pluginapi
 
 import (
 	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
 )
 
 type PluginConfigurer interface {
@@ -9,6 +10,9 @@ type PluginConfigurer interface {
 	Config(config []byte) error
 }
 
+// Worker, Runner, Producer, Consumer and IOWorker handle raw payloads.
+// The headers of their inputs are propagated to their outputs, see `message.IOWorker`.
+
 type Worker = graph.Worker[[]byte]
 
 type Runner = graph.Runner[[]byte]
@@ -18,3 +22,13 @@ type Producer = graph.Producer[[]byte]
 type Consumer = graph.Consumer[[]byte]
 
 type IOWorker = graph.IOWorker[[]byte]
+
+// Message is the envelope flowing between stages. Plugins reading or writing headers use the `Message*` types.
+// They emit a message on a named output port by setting its `Headers.Port`, see `message.WithPort`.
+type Message = message.Message
+
+type MessageWorker = graph.Worker[message.Message]
+
+type MessageRunner = graph.Runner[message.Message]
+
+type MessageIOWorker = graph.IOWorker[message.Message]
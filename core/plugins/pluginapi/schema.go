@@ -0,0 +1,32 @@
+package pluginapi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/swaggest/jsonschema-go"
+)
+
+// SchemaDraft is the JSON Schema draft of the config schemas returned by `GetInputSchema`.
+const SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema returns the JSON Schema of `config`, the Go struct the config of a plugin is decoded in. Its properties are
+// named after the `json` tags, the `required:"true"` tag makes a property required, see `jsonschema.Reflector` for the
+// other tags. It is meant to be returned by `GetInputSchema`:
+//
+//	func (*Plugin) GetInputSchema() ([]byte, error) {
+//		return pluginapi.Schema(Config{})
+//	}
+func Schema(config any) ([]byte, error) {
+	reflector := jsonschema.Reflector{}
+	schema, err := reflector.Reflect(config, jsonschema.InlineRefs)
+	if err != nil {
+		return nil, fmt.Errorf("reflect config schema of %T: %w", config, err)
+	}
+	schema.WithSchema(SchemaDraft)
+	raw, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("encode config schema of %T: %w", config, err)
+	}
+	return raw, nil
+}
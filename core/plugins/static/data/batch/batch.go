@@ -0,0 +1,179 @@
+// Package batch groups items to hand them over at once to the plugins working better on many inputs,
+// and splits such groups back into items.
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
+)
+
+// Format is the encoding of a batch.
+type Format string
+
+const (
+	// Text joins the payloads with the separator.
+	Text Format = "text"
+	// JSON encodes the payloads in a JSON array, the JSON payloads are kept as is and the others are strings.
+	JSON Format = "json"
+)
+
+const defaultSeparator = "\n"
+
+var ErrUnknownFormat = errors.New("unknown batch format")
+
+// Config configures a batch, emitted once it holds `Count` items, once adding an item would make it larger than
+// `Bytes`, or `Window` after its first item. At least one of them is required.
+// `Separator` joins the payloads of a `text` batch, a new line if empty.
+type Config struct {
+	Count     int           `mapstructure:"count"`
+	Bytes     int           `mapstructure:"bytes"`
+	Window    time.Duration `mapstructure:"window"`
+	Format    string        `mapstructure:"format"`
+	Separator string        `mapstructure:"separator"`
+}
+
+func (c Config) format() (Format, string, error) {
+	separator := c.Separator
+	if separator == "" {
+		separator = defaultSeparator
+	}
+	switch f := Format(c.Format); f {
+	case "":
+		return Text, separator, nil
+	case Text, JSON:
+		return f, separator, nil
+	default:
+		return "", "", fmt.Errorf("%w: %s", ErrUnknownFormat, c.Format)
+	}
+}
+
+// Runner emits the batches of its inputs, the headers of a batch are those of its first item.
+type Runner struct {
+	config    Config
+	format    Format
+	separator string
+}
+
+func New(config Config) (*Runner, error) {
+	if config.Count <= 0 && config.Bytes <= 0 && config.Window <= 0 {
+		return nil, errors.New("batch requires a count, bytes or window limit")
+	}
+	format, separator, err := config.format()
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{config: config, format: format, separator: separator}, nil
+}
+
+// Run emits the batches until the input is closed, the last batch is emitted even if it is not full.
+func (r *Runner) Run(ctx context.Context, input <-chan message.Message, yield func(elem message.Message, err error) error) error {
+	var items []message.Message
+	size := 0
+	timer := time.NewTimer(0)
+	timer.Stop()
+	defer timer.Stop()
+	flush := func() error {
+		timer.Stop()
+		if len(items) == 0 {
+			return nil
+		}
+		output, err := r.encode(items)
+		items, size = nil, 0
+		return yield(output, err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		case elem, ok := <-input:
+			if !ok {
+				return flush()
+			}
+			if r.config.Bytes > 0 && len(items) > 0 && size+len(elem.Payload) > r.config.Bytes {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			if len(items) == 0 && r.config.Window > 0 {
+				timer.Reset(r.config.Window)
+			}
+			items = append(items, elem)
+			size += len(elem.Payload)
+			if r.config.Count > 0 && len(items) >= r.config.Count {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (r *Runner) encode(items []message.Message) (message.Message, error) {
+	payloads := make([][]byte, len(items))
+	for index, item := range items {
+		payloads[index] = item.Payload
+	}
+	if r.format == Text {
+		return items[0].Derive(bytes.Join(payloads, []byte(r.separator))), nil
+	}
+	values := make([]any, len(payloads))
+	for index, payload := range payloads {
+		if json.Valid(payload) {
+			values[index] = json.RawMessage(payload)
+		} else {
+			values[index] = string(payload)
+		}
+	}
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return items[0], graph.ItemError[message.Message]{Input: items[0], Err: fmt.Errorf("encode batch: %w", err)}
+	}
+	output := items[0].Derive(raw)
+	output.Headers.ContentType = "application/json"
+	return output, nil
+}
+
+// Unbatch splits the batches encoded with `config`: the elements of a JSON array, or the parts between separators.
+// The JSON strings are emitted unquoted, the other JSON values as is.
+func Unbatch(config Config) (graph.WorkerFunc[[]byte], error) {
+	format, separator, err := config.format()
+	if err != nil {
+		return nil, err
+	}
+	return func(_ context.Context, input []byte, yield func(elem []byte) error) error {
+		if format == Text {
+			for elem := range bytes.SplitSeq(input, []byte(separator)) {
+				if err := yield(elem); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		values := []json.RawMessage{}
+		if err := json.Unmarshal(input, &values); err != nil {
+			return fmt.Errorf("unbatch: decode JSON array: %w", err)
+		}
+		for _, value := range values {
+			elem := []byte(value)
+			var str string
+			if err := json.Unmarshal(value, &str); err == nil {
+				elem = []byte(str)
+			}
+			if err := yield(elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,72 @@
+package batch_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/benji-bou/diwo"
+	"github.com/benji-bou/lugh/core/message"
+	"github.com/benji-bou/lugh/core/plugins/static/data/batch"
+)
+
+func TestBatch(t *testing.T) {
+	items := []string{"a.com", "b.com", `{"host":"c.com"}`, "d.com", "e.com"}
+	testCases := map[string]struct {
+		config   batch.Config
+		expected []string
+	}{
+		"count":  {config: batch.Config{Count: 2}, expected: []string{"a.com\nb.com", "{\"host\":\"c.com\"}\nd.com", "e.com"}},
+		"bytes":  {config: batch.Config{Bytes: 12, Separator: ","}, expected: []string{"a.com,b.com", `{"host":"c.com"}`, "d.com,e.com"}},
+		"window": {config: batch.Config{Window: time.Hour, Format: "json"}, expected: []string{`["a.com","b.com",{"host":"c.com"},"d.com","e.com"]`}},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			runner, err := batch.New(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inputs := make([]message.Message, len(items))
+			for index, item := range items {
+				inputs[index] = message.New([]byte(item))
+			}
+			batches := []string{}
+			err = runner.Run(context.Background(), diwo.FromSlice(inputs), func(elem message.Message, err error) error {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				batches = append(batches, string(elem.Payload))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(batches, tc.expected) {
+				t.Fatalf("expected batches %q, got %q", tc.expected, batches)
+			}
+			unbatch, err := batch.Unbatch(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			unbatched := []string{}
+			for _, b := range batches {
+				if err := unbatch(context.Background(), []byte(b), func(elem []byte) error {
+					unbatched = append(unbatched, string(elem))
+					return nil
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !slices.Equal(unbatched, items) {
+				t.Errorf("unbatch should give back the items, got %q", unbatched)
+			}
+		})
+	}
+}
+
+func TestBatchRequiresLimit(t *testing.T) {
+	if _, err := batch.New(batch.Config{}); err == nil {
+		t.Error("a batch without limit should be refused")
+	}
+}
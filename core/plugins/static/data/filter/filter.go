@@ -0,0 +1,63 @@
+// Package filter keeps or routes the items matching expressions, see the `expr` package for their syntax.
+package filter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/benji-bou/lugh/core/expr"
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
+)
+
+// Route sends the items matching `Expr` to the output `Port` of the stage, its default output if empty.
+type Route struct {
+	Port string `mapstructure:"port"`
+	Expr string `mapstructure:"expr"`
+}
+
+// Config configures a filter with `Expr`, or a router with `Routes`.
+// A filter emits the matching items on its default output. A router emits each item on the port of the first
+// route it matches. The items matching nothing go to the `Unmatched` port, they are dropped if it is empty.
+type Config struct {
+	Expr      string  `mapstructure:"expr"`
+	Routes    []Route `mapstructure:"routes"`
+	Unmatched string  `mapstructure:"unmatched"`
+}
+
+type route struct {
+	port string
+	expr *expr.Expr
+}
+
+// Worker compiles the expressions of `config`, the syntax errors are reported here rather than on the first item.
+func Worker(config Config) (graph.WorkerFunc[message.Message], error) {
+	if (config.Expr == "") == (len(config.Routes) == 0) {
+		return nil, errors.New("filter requires either an expr or routes")
+	}
+	if config.Expr != "" {
+		config.Routes = []Route{{Expr: config.Expr}}
+	}
+	routes := make([]route, 0, len(config.Routes))
+	for _, r := range config.Routes {
+		compiled, err := expr.Compile(r.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("filter expression %q: %w", r.Expr, err)
+		}
+		routes = append(routes, route{port: r.Port, expr: compiled})
+	}
+	return func(_ context.Context, input message.Message, yield func(elem message.Message) error) error {
+		for _, r := range routes {
+			if r.expr.Match(input) {
+				input.Headers.Port = r.port
+				return yield(input)
+			}
+		}
+		if config.Unmatched == "" {
+			return nil
+		}
+		input.Headers.Port = config.Unmatched
+		return yield(input)
+	}, nil
+}
@@ -0,0 +1,60 @@
+package filter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/benji-bou/lugh/core/expr"
+	"github.com/benji-bou/lugh/core/message"
+	"github.com/benji-bou/lugh/core/plugins/static/data/filter"
+)
+
+func TestRoutes(t *testing.T) {
+	worker, err := filter.Worker(filter.Config{
+		Routes: []filter.Route{
+			{Port: "server", Expr: ".status >= 500"},
+			{Port: "client", Expr: ".status >= 400"},
+		},
+		Unmatched: "ok",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for payload, port := range map[string]string{`{"status": 503}`: "server", `{"status": 404}`: "client", `{"status": 200}`: "ok"} {
+		outputs := []message.Message{}
+		err := worker.Work(context.Background(), message.New([]byte(payload)), func(elem message.Message) error {
+			outputs = append(outputs, elem)
+			return nil
+		})
+		if err != nil || len(outputs) != 1 || outputs[0].Headers.Port != port {
+			t.Errorf("%s should be routed to %s, got %+v, %v", payload, port, outputs, err)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	worker, err := filter.Worker(filter.Config{Expr: ".status >= 400"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := 0
+	for _, payload := range []string{`{"status": 503}`, `{"status": 200}`, `not json`} {
+		err := worker.Work(context.Background(), message.New([]byte(payload)), func(elem message.Message) error {
+			if elem.Headers.Port != "" {
+				t.Errorf("a matching item should be emitted on the default port, got %q", elem.Headers.Port)
+			}
+			outputs++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if outputs != 1 {
+		t.Errorf("only the matching items should be emitted, got %d", outputs)
+	}
+	if _, err := filter.Worker(filter.Config{Expr: ".status >="}); !errors.Is(err, expr.ErrSyntax) {
+		t.Errorf("a syntax error should be reported at load, got %v", err)
+	}
+}
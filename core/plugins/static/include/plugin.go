@@ -6,6 +6,7 @@ import (
 	"log/slog"
 
 	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
 	"github.com/benji-bou/lugh/core/template"
 )
 
@@ -15,22 +16,29 @@ type Config struct {
 	Filepath   string         `yaml:"filepath"`
 	Variables  map[string]any `yaml:"variables"`
 	PluginPath string         `yaml:"pluginpath"`
+	RunID      string         `yaml:"runid"`
 }
 
-func Worker[S template.PluginLoader](config Config) (graph.IOWorker[[]byte], error) {
+func Worker[S template.PluginLoader](config Config) (graph.IOWorker[message.Message], error) {
 	if config.Filepath == "" {
 		return nil, ErrEmptyIncludePath
 	}
 	config.Variables["is_included"] = true
-	tpl, err := template.NewFile[S](config.Filepath, template.WithPluginPath(config.PluginPath), template.WithVariables(config.Variables))
+	tpl, err := template.NewFile[S](config.Filepath, template.WithPluginPath(config.PluginPath), template.WithVariables(config.Variables), template.WithRunID(config.RunID))
 	if err != nil {
 		slog.Error("include template failed", "error", err)
 		return nil, fmt.Errorf("include template %s failed: %w", config.Filepath, err)
 	}
+	if err := tpl.Validate(); err != nil {
+		return nil, fmt.Errorf("include template %s: %w", config.Filepath, err)
+	}
 	vertices, err := tpl.WorkerVertexIterator()
 	if err != nil {
 		return nil, fmt.Errorf("include template %s get vertices: %w", config.Filepath, err)
 	}
 	g := graph.NewIO(graph.WithVertices(vertices))
+	if err := g.Validate(); err != nil {
+		return nil, fmt.Errorf("include template %s: %w", config.Filepath, err)
+	}
 	return g, nil
 }
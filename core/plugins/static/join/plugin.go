@@ -0,0 +1,258 @@
+// Package join combines the messages of several parents sharing the same key.
+// Each parent is an input of the join, identified by the source of its messages, with the field holding its key.
+// The messages are buffered in a window bounded in time and in count. As soon as a message finds a match in every
+// other input, the join emits a JSON record per combination: an object holding the payload of each input under its
+// source name, and the key under `key`.
+package join
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
+)
+
+// Type decides what happens to the messages leaving the window without a match.
+type Type string
+
+const (
+	// Inner drops them.
+	Inner Type = "inner"
+	// Left emits the unmatched messages of the first input, the other inputs are null in the record.
+	Left Type = "left"
+	// Outer emits the unmatched messages of every input.
+	Outer Type = "outer"
+)
+
+const defaultSize = 10000
+
+var ErrUnknownType = errors.New("unknown join type")
+
+// Input is a parent of the join. `Key` is the field of its messages to join on, see `message.Field`.
+type Input struct {
+	Source string `mapstructure:"source"`
+	Key    string `mapstructure:"key"`
+}
+
+// Config configures the join. `Window` is how long a message waits for its matches, forever when zero.
+// `Size` is how many messages are buffered at most, the oldest leaves the window first. It defaults to 10000.
+type Config struct {
+	Type   string        `mapstructure:"type"`
+	Inputs []Input       `mapstructure:"inputs"`
+	Window time.Duration `mapstructure:"window"`
+	Size   int           `mapstructure:"size"`
+}
+
+type input struct {
+	source string
+	key    func(m message.Message) string
+}
+
+type entry struct {
+	msg     message.Message
+	input   int
+	key     string
+	at      time.Time
+	matched bool
+}
+
+// Runner joins the messages of its inputs.
+type Runner struct {
+	joinType Type
+	inputs   []input
+	window   time.Duration
+	size     int
+}
+
+func New(config Config) (*Runner, error) {
+	r := &Runner{joinType: Type(config.Type), window: config.Window, size: config.Size}
+	switch r.joinType {
+	case "":
+		r.joinType = Inner
+	case Inner, Left, Outer:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, config.Type)
+	}
+	if len(config.Inputs) < 2 {
+		return nil, errors.New("join requires at least two inputs")
+	}
+	for _, in := range config.Inputs {
+		if in.Source == "" {
+			return nil, errors.New("join input without source")
+		}
+		key, err := message.Field(in.Key)
+		if err != nil {
+			return nil, fmt.Errorf("join input %s: %w", in.Source, err)
+		}
+		r.inputs = append(r.inputs, input{source: in.Source, key: key})
+	}
+	if r.size <= 0 {
+		r.size = defaultSize
+	}
+	return r, nil
+}
+
+// Run joins the messages until the input is closed, then the messages left in the window are expired.
+func (r *Runner) Run(ctx context.Context, inputC <-chan message.Message, yield func(elem message.Message, err error) error) error {
+	w := &window{runner: r, entries: make(map[string][][]*entry), yield: yield}
+	var tick <-chan time.Time
+	if r.window > 0 {
+		ticker := time.NewTicker(max(r.window/10, 10*time.Millisecond)) //nolint:mnd // expire with a 10% precision
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-tick:
+			if err := w.expire(func(e *entry) bool { return now.Sub(e.at) >= r.window }); err != nil {
+				return err
+			}
+		case msg, ok := <-inputC:
+			if !ok {
+				return w.expire(func(*entry) bool { return true })
+			}
+			if err := w.add(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// window holds the buffered messages by key and input, and all of them in arrival order.
+type window struct {
+	runner  *Runner
+	entries map[string][][]*entry
+	order   []*entry
+	yield   func(elem message.Message, err error) error
+}
+
+func (w *window) add(msg message.Message) error {
+	index := w.runner.inputIndex(msg.Headers.Source)
+	if index < 0 {
+		return w.yield(msg, graph.ItemError[message.Message]{Input: msg, Err: fmt.Errorf("join: message from unexpected source %q", msg.Headers.Source)})
+	}
+	e := &entry{msg: msg, input: index, key: w.runner.inputs[index].key(msg), at: time.Now()}
+	byInput, ok := w.entries[e.key]
+	if !ok {
+		byInput = make([][]*entry, len(w.runner.inputs))
+		w.entries[e.key] = byInput
+	}
+	byInput[index] = append(byInput[index], e)
+	w.order = append(w.order, e)
+	if err := w.match(e, byInput); err != nil {
+		return err
+	}
+	overflow := len(w.order) - w.runner.size
+	return w.expire(func(*entry) bool {
+		overflow--
+		return overflow >= 0
+	})
+}
+
+// match emits a record per combination of `e` with the messages of the other inputs.
+func (w *window) match(e *entry, byInput [][]*entry) error {
+	combinations := [][]*entry{make([]*entry, len(byInput))}
+	for index, entries := range byInput {
+		if index == e.input {
+			for _, combination := range combinations {
+				combination[index] = e
+			}
+			continue
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		next := make([][]*entry, 0, len(combinations)*len(entries))
+		for _, combination := range combinations {
+			for _, other := range entries {
+				combined := append([]*entry(nil), combination...)
+				combined[index] = other
+				next = append(next, combined)
+			}
+		}
+		combinations = next
+	}
+	for _, combination := range combinations {
+		for _, matched := range combination {
+			matched.matched = true
+		}
+		if err := w.emit(e, combination); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expire removes from the window the oldest messages while `expired` holds,
+// the unmatched ones are emitted alone depending on the join type.
+func (w *window) expire(expired func(e *entry) bool) error {
+	for len(w.order) > 0 && expired(w.order[0]) {
+		e := w.order[0]
+		w.order = w.order[1:]
+		byInput := w.entries[e.key]
+		byInput[e.input] = byInput[e.input][1:]
+		if isEmpty(byInput) {
+			delete(w.entries, e.key)
+		}
+		if e.matched || w.runner.joinType == Inner || (w.runner.joinType == Left && e.input != 0) {
+			continue
+		}
+		combination := make([]*entry, len(w.runner.inputs))
+		combination[e.input] = e
+		if err := w.emit(e, combination); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emit yields the record of `combination`, inheriting the headers of `trigger`.
+func (w *window) emit(trigger *entry, combination []*entry) error {
+	record := map[string]any{"key": trigger.key}
+	for index, e := range combination {
+		var value any
+		if e != nil {
+			value = payloadValue(e.msg.Payload)
+		}
+		record[w.runner.inputs[index].source] = value
+	}
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return w.yield(trigger.msg, graph.ItemError[message.Message]{Input: trigger.msg, Err: fmt.Errorf("join: encode record: %w", err)})
+	}
+	output := trigger.msg.Derive(raw)
+	output.Headers.ContentType = "application/json"
+	return w.yield(output, nil)
+}
+
+func (r *Runner) inputIndex(source string) int {
+	for index, in := range r.inputs {
+		if in.source == source {
+			return index
+		}
+	}
+	return -1
+}
+
+// payloadValue keeps the JSON payloads as is in the record, the other payloads are strings.
+func payloadValue(payload []byte) any {
+	if json.Valid(payload) {
+		return json.RawMessage(payload)
+	}
+	return string(payload)
+}
+
+func isEmpty(byInput [][]*entry) bool {
+	for _, entries := range byInput {
+		if len(entries) > 0 {
+			return false
+		}
+	}
+	return true
+}
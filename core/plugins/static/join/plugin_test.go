@@ -0,0 +1,63 @@
+package join_test
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/benji-bou/diwo"
+	"github.com/benji-bou/lugh/core/message"
+	"github.com/benji-bou/lugh/core/plugins/static/join"
+)
+
+func TestJoin(t *testing.T) {
+	inputs := []message.Message{
+		message.New([]byte(`{"host":"a.example.com"}`), message.WithSource("subdomains")),
+		message.New([]byte(`{"host":"b.example.com"}`), message.WithSource("subdomains")),
+		message.New([]byte(`{"host":"a.example.com","port":443}`), message.WithSource("ports")),
+		message.New([]byte(`{"host":"c.example.com","port":22}`), message.WithSource("ports")),
+	}
+	testCases := map[join.Type][]string{
+		join.Inner: {"a.example.com"},
+		join.Left:  {"a.example.com", "b.example.com"},
+		join.Outer: {"a.example.com", "b.example.com", "c.example.com"},
+	}
+	for joinType, expected := range testCases {
+		t.Run(string(joinType), func(t *testing.T) {
+			runner, err := join.New(join.Config{
+				Type:   string(joinType),
+				Inputs: []join.Input{{Source: "subdomains", Key: "json.host"}, {Source: "ports", Key: "json.host"}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			records := map[string]map[string]any{}
+			err = runner.Run(context.Background(), diwo.FromSlice(inputs), func(elem message.Message, err error) error {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+					return nil
+				}
+				record := map[string]any{}
+				if err := json.Unmarshal(elem.Payload, &record); err != nil {
+					t.Fatalf("invalid record %s: %v", elem.Payload, err)
+				}
+				records[record["key"].(string)] = record
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keys := slices.Sorted(maps.Keys(records)); !slices.Equal(keys, expected) {
+				t.Errorf("expected records for %v, got %v", expected, records)
+			}
+			if matched := records["a.example.com"]; matched["subdomains"] == nil || matched["ports"] == nil {
+				t.Errorf("the matching messages should be combined, got %v", matched)
+			}
+			if unmatched, ok := records["b.example.com"]; ok && unmatched["ports"] != nil {
+				t.Errorf("the unmatched message should be alone, got %v", unmatched)
+			}
+		})
+	}
+}
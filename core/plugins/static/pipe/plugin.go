@@ -5,16 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 
 	"github.com/benji-bou/diwo"
 	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
 	"github.com/benji-bou/lugh/core/plugins/load"
 	"gopkg.in/yaml.v3"
 )
 
 type Plugin struct {
 	defaultPluginsPath string
-	ioworkers          []graph.IOWorker[[]byte]
+	ioworkers          []graph.IOWorker[message.Message]
 }
 
 func New(pluginPath string) *Plugin {
@@ -47,7 +49,7 @@ func (p *Plugin) Config(config []byte) error {
 	if err != nil {
 		return fmt.Errorf("failed to unmarshal config: %w", err)
 	}
-	p.ioworkers = make([]graph.IOWorker[[]byte], 0, len(decodedConfig))
+	p.ioworkers = make([]graph.IOWorker[message.Message], 0, len(decodedConfig))
 	for _, pluginYAMLConfigs := range decodedConfig {
 		if len(pluginYAMLConfigs) > 1 {
 			return errors.New("a transformer can only have one config per step")
@@ -66,7 +68,49 @@ func (p *Plugin) Config(config []byte) error {
 	return nil
 }
 
-func (p *Plugin) Run(ctx context.Context, input <-chan []byte, yield func(elem []byte, err error) error) error {
+// SetCheckpoint records the inputs of each step able to tell when it is done with one, see `graph.CheckpointConfigurer`.
+// It must be called after `Config`. The steps share `checkpoint`, their inputs are told apart by their position.
+func (p *Plugin) SetCheckpoint(checkpoint graph.Checkpoint[message.Message]) error {
+	supported := false
+	for i, iow := range p.ioworkers {
+		err := graph.SetCheckpoint[message.Message](iow, stepCheckpoint{checkpoint: checkpoint, step: i})
+		if errors.Is(err, graph.ErrCheckpointNotSupported) {
+			slog.Warn("pipe step inputs are not checkpointed, they are processed again on resume", "step", i, "error", err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("pipe step %d checkpoint: %w", i, err)
+		}
+		supported = true
+	}
+	if !supported {
+		return fmt.Errorf("%w by any step of the pipe", graph.ErrCheckpointNotSupported)
+	}
+	return nil
+}
+
+// stepCheckpoint keys the inputs of a step by their id and the step position, a step may forward its input unchanged.
+type stepCheckpoint struct {
+	checkpoint graph.Checkpoint[message.Message]
+	step       int
+}
+
+func (c stepCheckpoint) key(input message.Message) message.Message {
+	if input.Headers.ID != "" {
+		input.Headers.ID = fmt.Sprintf("%s/%d", input.Headers.ID, c.step)
+	}
+	return input
+}
+
+func (c stepCheckpoint) Outputs(input message.Message) ([]message.Message, bool) {
+	return c.checkpoint.Outputs(c.key(input))
+}
+
+func (c stepCheckpoint) Record(input message.Message, outputs []message.Message) error {
+	return c.checkpoint.Record(c.key(input), outputs)
+}
+
+func (p *Plugin) Run(ctx context.Context, input <-chan message.Message, yield func(elem message.Message, err error) error) error {
 	if input == nil {
 		return errors.New("running pipe plugin: input is nil")
 	}
@@ -84,7 +128,7 @@ func (p *Plugin) Run(ctx context.Context, input <-chan []byte, yield func(elem [
 		case <-ctx.Done():
 			return ctx.Err()
 		case err := <-errC:
-			if err := yield(nil, err); err != nil {
+			if err := yield(message.Message{}, err); err != nil {
 				return err
 			}
 		case elem, ok := <-input:
@@ -0,0 +1,188 @@
+// Package record taps the edges of a graph: each message read by a stage is written in a JSON lines file per edge,
+// `<dir>/<stage>/<source>.jsonl` where `source` is the stage which emitted it, or the input of the graph.
+// The recorded inputs of a stage can be replayed into this stage alone to debug it. Secrets are redacted from the
+// recorded messages.
+package record
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/benji-bou/diwo"
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
+	"github.com/benji-bou/lugh/core/secret"
+)
+
+// sourceUnknown names the file of the messages without `message.Headers.Source`.
+const sourceUnknown = "unknown"
+
+// Recorder writes the messages read by the stages of a graph in `dir`.
+type Recorder struct {
+	dir   string
+	codec message.Codec
+	files map[string]*os.File
+	mux   sync.Mutex
+}
+
+// New records in `dir`, it is created when missing. The files of a previous recording are appended to.
+func New(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil { //nolint:mnd // the recording is only for the user
+		return nil, fmt.Errorf("create record dir: %w", err)
+	}
+	return &Recorder{dir: dir, files: map[string]*os.File{}}, nil
+}
+
+// Middleware records each message when the stage reads it.
+// A message which can't be recorded is still processed, the failure is logged.
+func (r *Recorder) Middleware() graph.Middleware[message.Message] {
+	return func(vertex graph.IOWorkerVertex[message.Message], worker graph.IOWorker[message.Message]) graph.IOWorker[message.Message] {
+		return &ioWorker{IOWorker: worker, recorder: r, stage: vertex.GetName()}
+	}
+}
+
+func (r *Recorder) write(stage string, elem message.Message) error {
+	// The payload is encoded in base64, it is redacted before being encoded and the headers after.
+	elem.Payload = secret.RedactBytes(elem.Payload)
+	raw, err := r.codec.Encode(elem)
+	if err != nil {
+		return err
+	}
+	raw = secret.RedactBytes(raw)
+	source := elem.Headers.Source
+	if source == "" {
+		source = sourceUnknown
+	}
+	path := filepath.Join(r.dir, url.PathEscape(stage), url.PathEscape(source)+".jsonl")
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	file, ok := r.files[path]
+	if !ok {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil { //nolint:mnd // the recording is only for the user
+			return fmt.Errorf("create record dir: %w", err)
+		}
+		file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:mnd // the recording is only for the user
+		if err != nil {
+			return fmt.Errorf("open record file: %w", err)
+		}
+		r.files[path] = file
+	}
+	if _, err := file.Write(append(raw, '\n')); err != nil {
+		return fmt.Errorf("write record: %w", err)
+	}
+	return nil
+}
+
+// Close closes the files of the recording.
+func (r *Recorder) Close() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	var errs error
+	for path, file := range r.files {
+		errs = errors.Join(errs, file.Close())
+		delete(r.files, path)
+	}
+	return errs
+}
+
+// Inputs returns the messages read by `stage` in the recording `dir`. The messages of an edge are in the order they
+// were read, the edges are merged by emission time.
+func Inputs(dir, stage string) ([]message.Message, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, url.PathEscape(stage), "*.jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("list records: %w", err)
+	}
+	if len(paths) == 0 {
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("no recording in %s: %w", dir, err)
+		}
+		return nil, fmt.Errorf("no input of stage %s recorded in %s", stage, dir)
+	}
+	inputs := []message.Message{}
+	for _, path := range paths {
+		edge, err := readEdge(path)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, edge...)
+	}
+	slices.SortStableFunc(inputs, func(a, b message.Message) int {
+		return a.Headers.Timestamp.Compare(b.Headers.Timestamp)
+	})
+	return inputs, nil
+}
+
+func readEdge(path string) ([]message.Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open record file: %w", err)
+	}
+	defer file.Close()
+	codec := message.Codec{}
+	messages := []message.Message{}
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			elem, decodeErr := codec.Decode(line)
+			if decodeErr != nil {
+				slog.Warn("skip invalid record line", "file", path, "error", decodeErr)
+			} else {
+				messages = append(messages, elem)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			// A truncated last line is a message the run was killed while recording.
+			return messages, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("read record file: %w", err)
+		}
+	}
+}
+
+type ioWorker struct {
+	graph.IOWorker[message.Message]
+	recorder *Recorder
+	stage    string
+}
+
+func (w *ioWorker) SetInput(input <-chan message.Message) {
+	if input == nil {
+		w.IOWorker.SetInput(nil)
+		return
+	}
+	w.IOWorker.SetInput(diwo.Map(input, func(elem message.Message) message.Message {
+		if err := w.recorder.write(w.stage, elem); err != nil {
+			slog.Warn("failed to record input", "stage", w.stage, "error", err)
+		}
+		return elem
+	}))
+}
+
+func (w *ioWorker) Kind() graph.Kind {
+	return graph.KindOf(w.IOWorker)
+}
+
+func (w *ioWorker) NotifyDone(fn func(input message.Message, err error)) error {
+	return graph.NotifyDone(w.IOWorker, fn)
+}
+
+func (w *ioWorker) Restarts() uint64 {
+	return graph.RestartsOf(w.IOWorker)
+}
+
+func (w *ioWorker) DeadLetters() <-chan message.Message {
+	if emitter, ok := w.IOWorker.(graph.DeadLetterEmitter[message.Message]); ok {
+		return emitter.DeadLetters()
+	}
+	return nil
+}
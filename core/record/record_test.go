@@ -0,0 +1,78 @@
+package record_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/benji-bou/diwo"
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
+	"github.com/benji-bou/lugh/core/record"
+)
+
+func split() graph.IOWorker[message.Message] {
+	return graph.NewIOWorkerFromWorker(graph.WorkerFunc[message.Message](func(_ context.Context, input message.Message, yield func(elem message.Message) error) error {
+		for _, part := range bytes.Split(input.Payload, []byte(",")) {
+			if err := yield(input.Derive(part)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+func TestRecord(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := record.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := graph.NewIO(
+		graph.WithVertices([]graph.IOWorkerVertex[message.Message]{
+			graph.NewIOWorkerVertex("split", []string{}, split(), graph.WithVertexOutputMap(message.Stamp("split", ""))),
+			graph.NewIOWorkerVertex("leaf", []string{"split"}, split()),
+		}),
+		graph.WithMiddleware(recorder.Middleware()),
+	)
+	g.SetInput(diwo.Once(message.New([]byte("a,b,c"), message.WithSource("raw-input"))))
+	outputC := g.Output()
+	ctx := graph.NewContext(context.Background())
+	errC := g.Run(ctx)
+	ctx.Synchronize()
+	go func() {
+		for err := range errC {
+			t.Errorf("unexpected error %v", err)
+		}
+	}()
+	for range outputC {
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	inputs, err := record.Inputs(dir, "split")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 1 || string(inputs[0].Payload) != "a,b,c" || inputs[0].Headers.Source != "raw-input" {
+		t.Errorf("unexpected inputs of split %+v", inputs)
+	}
+	inputs, err = record.Inputs(dir, "leaf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads := []string{}
+	for _, input := range inputs {
+		payloads = append(payloads, string(input.Payload))
+		if input.Headers.Source != "split" {
+			t.Errorf("expected the inputs of leaf to come from split, got %q", input.Headers.Source)
+		}
+	}
+	if len(payloads) != 3 || payloads[0] != "a" || payloads[1] != "b" || payloads[2] != "c" {
+		t.Errorf("unexpected inputs of leaf %v", payloads)
+	}
+	if _, err := record.Inputs(dir, "missing"); err == nil {
+		t.Error("expected an error for a stage without recorded inputs")
+	}
+}
@@ -0,0 +1,54 @@
+package secret
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+)
+
+// Cipher encrypts data with AES-GCM by a key derived from a passphrase with scrypt, like the store.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewSalt returns a random salt to derive the key of a `Cipher`.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+// NewCipher derives the key of the cipher from `passphrase` and `salt`.
+func NewCipher(passphrase string, salt []byte) (*Cipher, error) {
+	if passphrase == "" {
+		return nil, ErrNoPassphrase
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts `plaintext`, the result starts with its random nonce.
+func (c *Cipher) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts data encrypted by `Seal`.
+func (c *Cipher) Open(sealed []byte) ([]byte, error) {
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
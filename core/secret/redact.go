@@ -0,0 +1,155 @@
+// Package secret resolves the secrets referenced by templates and redacts their values from the logs, the graph
+// exports and the recorded runs.
+//
+// Every resolved secret is registered with `Register`. `Redact` replaces the registered values with `Mask`,
+// `Handler` and `Writer` apply it to the slog records and to the logs of the plugin processes.
+package secret
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Mask replaces the values of the secrets.
+const Mask = "[REDACTED]"
+
+var (
+	registered = map[string]struct{}{}
+	registerMu sync.Mutex
+	replacer   atomic.Pointer[strings.Replacer]
+)
+
+// Register adds values to redact. Their JSON escaped form is redacted too, so that they are redacted from JSON
+// documents like the config of the plugins.
+func Register(values ...string) {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		registered[value] = struct{}{}
+		if escaped, err := json.Marshal(value); err == nil {
+			registered[string(escaped[1:len(escaped)-1])] = struct{}{}
+		}
+	}
+	// The longest values are replaced first, a secret containing another one is fully redacted.
+	sorted := make([]string, 0, len(registered))
+	for value := range registered {
+		sorted = append(sorted, value)
+	}
+	slices.SortFunc(sorted, func(a, b string) int { return len(b) - len(a) })
+	pairs := make([]string, 0, 2*len(sorted)) //nolint:mnd // old and new strings
+	for _, value := range sorted {
+		pairs = append(pairs, value, Mask)
+	}
+	replacer.Store(strings.NewReplacer(pairs...))
+}
+
+// Redact returns `s` with the registered values replaced by `Mask`.
+func Redact(s string) string {
+	r := replacer.Load()
+	if r == nil {
+		return s
+	}
+	return r.Replace(s)
+}
+
+// RedactBytes returns `b` with the registered values replaced by `Mask`.
+func RedactBytes(b []byte) []byte {
+	r := replacer.Load()
+	if r == nil || len(b) == 0 {
+		return b
+	}
+	res := &bytes.Buffer{}
+	if _, err := r.WriteString(res, string(b)); err != nil {
+		return b
+	}
+	return res.Bytes()
+}
+
+// Writer redacts what is written to `w`, each write is redacted on its own.
+func Writer(w io.Writer) io.Writer {
+	return redactWriter{w: w}
+}
+
+type redactWriter struct {
+	w io.Writer
+}
+
+func (rw redactWriter) Write(p []byte) (int, error) {
+	if _, err := rw.w.Write(RedactBytes(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Handler redacts the message and the attributes of the records handled by `next`.
+func Handler(next slog.Handler) slog.Handler {
+	return redactHandler{next: next}
+}
+
+// RedactLogs redacts the output of the default slog logger.
+func RedactLogs() {
+	slog.SetDefault(slog.New(Handler(slog.Default().Handler())))
+}
+
+type redactHandler struct {
+	next slog.Handler
+}
+
+func (h redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h redactHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		redacted = append(redacted, redactAttr(attr))
+	}
+	return redactHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{next: h.next.WithGroup(name)}
+}
+
+// redactAttr redacts the strings of the attribute. Other values holding a secret, like errors, are replaced by their
+// redacted text.
+func redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, 0, len(group))
+		for _, member := range group {
+			redacted = append(redacted, redactAttr(member))
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		text := fmt.Sprint(value.Any())
+		if redacted := Redact(text); redacted != text {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
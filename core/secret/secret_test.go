@@ -0,0 +1,85 @@
+package secret_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benji-bou/lugh/core/secret"
+)
+
+func TestRedact(t *testing.T) {
+	secret.Register("api-key", `tok"en`)
+	if res := secret.Redact("key=api-key, json={\"t\":\"tok\\\"en\"}"); strings.Contains(res, "api-key") || strings.Contains(res, "tok") {
+		t.Errorf("expected the secrets and their JSON form to be redacted, got %s", res)
+	}
+	out := &bytes.Buffer{}
+	logger := slog.New(secret.Handler(slog.NewTextHandler(out, nil)))
+	logger.With("config", "api-key").Info("loading api-key",
+		"error", fmt.Errorf("invalid api-key"), slog.Group("plugin", "key", "api-key"), "count", 1,
+	)
+	if strings.Contains(out.String(), "api-key") || !strings.Contains(out.String(), "count=1") {
+		t.Errorf("expected the log to be redacted, got %s", out)
+	}
+	out.Reset()
+	if _, err := fmt.Fprint(secret.Writer(out), "plugin config api-key"); err != nil || out.String() != "plugin config "+secret.Mask {
+		t.Errorf("expected the writer to redact, got %s: %v", out, err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	store, err := secret.OpenStore(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("shodan", "stored-value")
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := os.ReadFile(path); bytes.Contains(raw, []byte("stored-value")) {
+		t.Errorf("the store should be encrypted")
+	}
+	if _, err := secret.OpenStore(path, "wrong"); !errors.Is(err, secret.ErrWrongPassphrase) {
+		t.Errorf("expected a wrong passphrase error, got %v", err)
+	}
+	store, err = secret.OpenStore(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := secret.Source{Name: "shodan", Store: "shodan"}.Resolve(func() (*secret.Store, error) { return store, nil })
+	if err != nil || value != "stored-value" {
+		t.Errorf("expected the stored value, got %q: %v", value, err)
+	}
+	if res := secret.Redact("stored-value"); res != secret.Mask {
+		t.Errorf("a resolved secret should be redacted, got %s", res)
+	}
+}
+
+func TestSources(t *testing.T) {
+	t.Setenv("LUGH_TEST_SECRET", "from-env")
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	noStore := func() (*secret.Store, error) { return nil, secret.ErrNoPassphrase }
+	for source, expected := range map[secret.Source]string{
+		{Name: "env", Env: "LUGH_TEST_SECRET"}: "from-env",
+		{Name: "file", File: path}:             "from-file",
+	} {
+		if value, err := source.Resolve(noStore); err != nil || value != expected {
+			t.Errorf("expected %s, got %q: %v", expected, value, err)
+		}
+	}
+	if _, err := (secret.Source{Name: "both", Env: "A", File: path}).Resolve(noStore); !errors.Is(err, secret.ErrInvalidSource) {
+		t.Errorf("expected an invalid source, got %v", err)
+	}
+	if _, err := (secret.Source{Name: "missing", Env: "LUGH_TEST_MISSING"}).Resolve(noStore); !errors.Is(err, secret.ErrNotFound) {
+		t.Errorf("expected a missing secret, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/benji-bou/lugh/helper"
+)
+
+var ErrInvalidSource = errors.New("invalid secret source")
+
+// Source is where the value of a secret is read: the environment variable `env`, the file `file`, trailing new lines
+// trimmed, or the entry `store` of a secret store.
+type Source struct {
+	Name  string `yaml:"name" json:"name"`
+	Env   string `yaml:"env,omitempty" json:"env,omitempty"`
+	File  string `yaml:"file,omitempty" json:"file,omitempty"`
+	Store string `yaml:"store,omitempty" json:"store,omitempty"`
+}
+
+// Resolve reads the secret and registers its value to be redacted. `store` is called only for the store sources.
+func (s Source) Resolve(store func() (*Store, error)) (string, error) {
+	value, err := s.read(store)
+	if err != nil {
+		return "", fmt.Errorf("secret %s: %w", s.Name, err)
+	}
+	Register(value)
+	return value, nil
+}
+
+func (s Source) read(store func() (*Store, error)) (string, error) {
+	set := 0
+	for _, field := range []string{s.Env, s.File, s.Store} {
+		if field != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return "", fmt.Errorf("%w: expected one of env, file or store", ErrInvalidSource)
+	}
+	switch {
+	case s.Env != "":
+		value, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", fmt.Errorf("%w: environment variable %s is not set", ErrNotFound, s.Env)
+		}
+		return value, nil
+	case s.File != "":
+		path, err := helper.ExpandHome(s.File)
+		if err != nil {
+			return "", err
+		}
+		raw, err := os.ReadFile(path) // #nosec G304
+		if err != nil {
+			return "", fmt.Errorf("read secret file: %w", err)
+		}
+		return strings.TrimRight(string(raw), "\r\n"), nil
+	default:
+		st, err := store()
+		if err != nil {
+			return "", err
+		}
+		return st.Get(s.Store)
+	}
+}
@@ -0,0 +1,177 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/benji-bou/lugh/helper"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// PassphraseEnv is the environment variable holding the passphrase of the default store.
+	PassphraseEnv = "LUGH_SECRETS_PASSPHRASE"
+	// StoreEnv is the environment variable holding the path of the default store, `DefaultStorePath` if empty.
+	StoreEnv = "LUGH_SECRETS_FILE"
+	// DefaultStorePath is the path of the default store.
+	DefaultStorePath = "~/.lugh/secrets.enc"
+)
+
+const (
+	saltSize = 16
+	keySize  = 32
+	// scrypt cost parameters recommended for interactive logins.
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	ErrNoPassphrase    = errors.New("no passphrase for the secret store")
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted secret store")
+	ErrNotFound        = errors.New("secret not found")
+)
+
+// Store is a local file holding secrets, encrypted with AES-GCM by a key derived from a passphrase with scrypt.
+// A missing file is an empty store, it is created by `Save`.
+type Store struct {
+	path       string
+	passphrase string
+	secrets    map[string]string
+}
+
+// storeFile is the content of the store file.
+type storeFile struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// OpenStore decrypts the store at `path` with `passphrase`.
+func OpenStore(path, passphrase string) (*Store, error) {
+	if passphrase == "" {
+		return nil, ErrNoPassphrase
+	}
+	path, err := helper.ExpandHome(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{path: path, passphrase: passphrase, secrets: map[string]string{}}
+	raw, err := os.ReadFile(path) // #nosec G304
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read secret store: %w", err)
+	}
+	var file storeFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWrongPassphrase, err)
+	}
+	aead, err := newAEAD(passphrase, file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if err := json.Unmarshal(plaintext, &s.secrets); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWrongPassphrase, err)
+	}
+	return s, nil
+}
+
+// DefaultStore opens the store of `StoreEnv` with the passphrase of `PassphraseEnv`.
+func DefaultStore() (*Store, error) {
+	path := os.Getenv(StoreEnv)
+	if path == "" {
+		path = DefaultStorePath
+	}
+	passphrase := os.Getenv(PassphraseEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("%w, set %s", ErrNoPassphrase, PassphraseEnv)
+	}
+	return OpenStore(path, passphrase)
+}
+
+// Get returns the secret `name`.
+func (s *Store) Get(name string) (string, error) {
+	value, ok := s.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s in %s", ErrNotFound, name, s.path)
+	}
+	return value, nil
+}
+
+// Set sets the secret `name`, it is written by `Save`.
+func (s *Store) Set(name, value string) {
+	s.secrets[name] = value
+}
+
+// Delete removes the secret `name`, it is written by `Save`.
+func (s *Store) Delete(name string) error {
+	if _, ok := s.secrets[name]; !ok {
+		return fmt.Errorf("%w: %s in %s", ErrNotFound, name, s.path)
+	}
+	delete(s.secrets, name)
+	return nil
+}
+
+// Names returns the names of the secrets, sorted.
+func (s *Store) Names() []string {
+	return slices.Sorted(maps.Keys(s.secrets))
+}
+
+// Save encrypts the secrets with a new salt and replaces the store file.
+func (s *Store) Save() error {
+	plaintext, err := json.Marshal(s.secrets)
+	if err != nil {
+		return fmt.Errorf("encode secrets: %w", err)
+	}
+	file := storeFile{Salt: make([]byte, saltSize)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return fmt.Errorf("generate salt: %w", err)
+	}
+	aead, err := newAEAD(s.passphrase, file.Salt)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return fmt.Errorf("generate nonce: %w", err)
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, nil)
+	raw, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("encode secret store: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil { //nolint:mnd // private to the user
+		return fmt.Errorf("create secret store: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil { //nolint:mnd // private to the user
+		return fmt.Errorf("write secret store: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("derive secret store key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("secret store cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,250 @@
+package template
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// InputType is the type of the value of a template input.
+type InputType string
+
+const (
+	InputString InputType = "string"
+	InputInt    InputType = "int"
+	InputBool   InputType = "bool"
+	InputList   InputType = "list"
+	InputMap    InputType = "map"
+)
+
+var (
+	ErrUnknownInputType = errors.New("unknown input type")
+	ErrInvalidInputs    = errors.New("invalid template inputs")
+)
+
+func ParseInputType(inputType string) (InputType, error) {
+	switch t := InputType(inputType); t {
+	case InputString, InputInt, InputBool, InputList, InputMap:
+		return t, nil
+	case "":
+		return InputString, nil
+	default:
+		return "", fmt.Errorf("%w: %s, expected string, int, bool, list or map", ErrUnknownInputType, inputType)
+	}
+}
+
+// Input declares a variable of a template. An input that is neither required nor given takes its `default`,
+// the zero value of its type if none, so that the template never renders a missing value.
+type Input struct {
+	Name        string `yaml:"name" json:"name"`
+	Type        string `yaml:"type,omitempty" json:"type,omitempty"`
+	Default     any    `yaml:"default,omitempty" json:"default,omitempty"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Required    bool   `yaml:"required,omitempty" json:"required,omitempty"`
+}
+
+// Inputs are the inputs declared in the `inputs` section of a template.
+type Inputs []Input
+
+// ParseInputs returns the inputs declared by the raw template. The section is read before the variables are
+// interpolated in the template, it can't use them.
+func ParseInputs(raw []byte) (Inputs, error) {
+	declared := struct {
+		Inputs Inputs `yaml:"inputs"`
+	}{}
+	if err := parseSection(raw, "inputs", &declared); err != nil {
+		return nil, fmt.Errorf("parse inputs: %w", err)
+	}
+	return declared.Inputs, nil
+}
+
+// parseSection decodes in `out` the top level `key` section of the raw template. The rest of the template may not be
+// valid YAML before its variables are interpolated: when the whole template can't be parsed, the section is cut from
+// the lines starting with `key:` up to the next top level entry and decoded alone.
+func parseSection(raw []byte, key string, out any) error {
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(raw, &doc); err != nil || !isMapping(doc) {
+		doc = yaml.Node{}
+		if err := yaml.Unmarshal(cutSection(raw, key), &doc); err != nil {
+			return err
+		}
+	}
+	if !isMapping(doc) {
+		return nil
+	}
+	return doc.Decode(out)
+}
+
+func isMapping(doc yaml.Node) bool {
+	return len(doc.Content) == 1 && doc.Content[0].Kind == yaml.MappingNode
+}
+
+// cutSection returns the lines of the top level `key` entry of the raw template, comments and flow style included.
+func cutSection(raw []byte, key string) []byte {
+	header := regexp.MustCompile(`^` + regexp.QuoteMeta(key) + `\s*:(\s|$)`)
+	section := &bytes.Buffer{}
+	inSection := false
+	for _, line := range strings.Split(string(raw), "\n") {
+		switch {
+		case header.MatchString(line):
+			inSection = true
+		case !inSection:
+			continue
+		case line != "" && !strings.ContainsAny(line[:1], " \t#-]}"):
+			// A new top level entry, the closing bracket of a flow collection still belongs to the section.
+			inSection = false
+			continue
+		}
+		section.WriteString(line + "\n")
+	}
+	return section.Bytes()
+}
+
+// ReadInputs returns the inputs declared by the template at `path`.
+func ReadInputs(path string) (Inputs, error) {
+	raw, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+	return ParseInputs(raw)
+}
+
+// Resolve returns the variables with the declared inputs converted to their type, the missing ones set to their
+// default. The variables that are not declared are kept as is. Every missing or mistyped input is reported.
+func (inputs Inputs) Resolve(variables map[string]any) (map[string]any, error) {
+	res := maps.Clone(variables)
+	if res == nil {
+		res = map[string]any{}
+	}
+	problems := []string{}
+	for _, input := range inputs {
+		inputType, err := ParseInputType(input.Type)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("input %s: %s", input.Name, err))
+			continue
+		}
+		value, given := variables[input.Name]
+		switch {
+		case given:
+		case input.Required:
+			problems = append(problems, fmt.Sprintf("missing required input %s", input.Name))
+			continue
+		case input.Default != nil:
+			value = input.Default
+		default:
+			res[input.Name] = inputType.zero()
+			continue
+		}
+		converted, err := inputType.convert(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("input %s: %s", input.Name, err))
+			continue
+		}
+		res[input.Name] = converted
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidInputs, strings.Join(problems, "; "))
+	}
+	return res, nil
+}
+
+func (t InputType) zero() any {
+	switch t {
+	case InputInt:
+		return 0
+	case InputBool:
+		return false
+	case InputList:
+		return []any{}
+	case InputMap:
+		return map[string]any{}
+	default:
+		return ""
+	}
+}
+
+// convert checks that `value` is of type `t`. Strings, like the variables given on the command line, are parsed,
+// in YAML for lists and maps. Scalars are accepted as strings.
+func (t InputType) convert(value any) (any, error) {
+	if raw, ok := value.(string); ok && t != InputString {
+		return t.parse(raw)
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		if t == InputBool {
+			return v, nil
+		}
+		if t == InputString {
+			return strconv.FormatBool(v), nil
+		}
+	case int:
+		if t == InputInt {
+			return v, nil
+		}
+		if t == InputString {
+			return strconv.Itoa(v), nil
+		}
+	case int64:
+		if t == InputInt {
+			return int(v), nil
+		}
+		if t == InputString {
+			return strconv.FormatInt(v, 10), nil
+		}
+	case float64:
+		if t == InputInt && v == math.Trunc(v) {
+			return int(v), nil
+		}
+		if t == InputString {
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+	case []any:
+		if t == InputList {
+			return v, nil
+		}
+	case map[string]any:
+		if t == InputMap {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("expected %s, got %v", t, value)
+}
+
+func (t InputType) parse(raw string) (any, error) {
+	switch t {
+	case InputInt:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected int, got %q", raw)
+		}
+		return v, nil
+	case InputBool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected bool, got %q", raw)
+		}
+		return v, nil
+	default:
+		if raw == "" {
+			return t.zero(), nil
+		}
+		var v any
+		if err := yaml.Unmarshal([]byte(raw), &v); err != nil {
+			return nil, fmt.Errorf("expected %s, got %q: %w", t, raw, err)
+		}
+		if _, ok := v.(string); ok {
+			return nil, fmt.Errorf("expected %s, got %q", t, raw)
+		}
+		return t.convert(v)
+	}
+}
@@ -0,0 +1,96 @@
+package template_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benji-bou/lugh/core/template"
+)
+
+const declaringInputs = `name: inputs
+inputs:
+  - name: target
+    required: true
+  - name: threads
+    type: int
+    default: 10
+  - name: ports
+    type: list
+  - name: verbose
+    type: bool
+stages:
+{{ range .ports }}
+  port_{{ . }}:
+    plugin: forward
+{{- end }}
+`
+
+func TestInputs(t *testing.T) {
+	inputs, err := template.ParseInputs([]byte(declaringInputs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 4 || inputs[1].Name != "threads" || inputs[1].Default != 10 {
+		t.Fatalf("unexpected inputs %+v", inputs)
+	}
+	variables, err := inputs.Resolve(map[string]any{"target": "example.com", "ports": "[80, 443]", "other": "kept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{"target": "example.com", "threads": 10, "ports": []any{80, 443}, "verbose": false, "other": "kept"}
+	if !reflect.DeepEqual(variables, expected) {
+		t.Errorf("expected %v, got %v", expected, variables)
+	}
+	_, err = inputs.Resolve(map[string]any{"threads": "many", "verbose": "yes please"})
+	if !errors.Is(err, template.ErrInvalidInputs) {
+		t.Fatalf("expected invalid inputs, got %v", err)
+	}
+	for _, problem := range []string{"missing required input target", "input threads: expected int", "input verbose: expected bool"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q to be reported in %q", problem, err)
+		}
+	}
+}
+
+func TestParseInputsFlowStyle(t *testing.T) {
+	raw := `name: flow
+inputs: [{name: target, required: true}, # the scanned host
+  {name: threads, type: int}
+] # no other input
+stages:
+  scan:
+    plugin: forward
+    config:
+      target: {{ .target }}
+`
+	inputs, err := template.ParseInputs([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 2 || inputs[0].Name != "target" || !inputs[0].Required || inputs[1].Type != "int" {
+		t.Errorf("unexpected inputs %+v", inputs)
+	}
+}
+
+func TestNewTemplateInputs(t *testing.T) {
+	tpl, err := template.NewTemplate[template.Stage]([]byte(declaringInputs),
+		template.WithVariables(map[string]any{"target": "example.com", "ports": "[80, 443]"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tpl.Stages["port_443"]; len(tpl.Stages) != 2 || !ok {
+		t.Errorf("expected a stage per port, got %v", tpl.Stages)
+	}
+	if _, err := template.NewTemplate[template.Stage]([]byte(declaringInputs)); !errors.Is(err, template.ErrInvalidInputs) {
+		t.Errorf("expected the missing target to fail the template, got %v", err)
+	}
+	misspelled := strings.Replace(declaringInputs, "port_{{ . }}", "port_{{ $.port }}", 1)
+	if _, err := template.NewTemplate[template.Stage]([]byte(misspelled),
+		template.WithVariables(map[string]any{"target": "example.com", "ports": "[80]"}),
+	); !errors.Is(err, template.ErrInvalidInputs) || !strings.Contains(err.Error(), "undeclared variables port") {
+		t.Errorf("expected the undeclared variable to fail the template, got %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template/parse"
+
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
+	"github.com/benji-bou/lugh/core/plugins/load"
+	"github.com/benji-bou/lugh/helper"
+)
+
+// DefaultPluginPath is where the plugins are looked for when no plugin path is given, like the GRPC loader does.
+const DefaultPluginPath = "~/.lugh/plugins"
+
+var ErrUnresolvedPlugins = errors.New("unresolved plugins")
+
+// ResolvePlugins checks, without starting any plugin, that the plugin of every stage is registered in the loader or
+// found in its plugin path, and that the included templates exist. Every unresolved stage is reported.
+func ResolvePlugins(tpl Template[Stage]) error {
+	problems := []string{}
+	for _, name := range slices.Sorted(maps.Keys(tpl.Stages)) {
+		if err := tpl.Stages[name].resolvePlugin(tpl.config); err != nil {
+			problems = append(problems, fmt.Sprintf("stage %s: %s", name, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("template %s: %w: %s", tpl.Name, ErrUnresolvedPlugins, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (st Stage) resolvePlugin(templateConfig TemplateConfig) error {
+	st, err := st.expandInclude()
+	if err != nil {
+		return err
+	}
+	switch {
+	case st.Plugin == "":
+		return errors.New("no plugin")
+	case st.Plugin == includePlugin:
+		config, err := includeConfig(st.Config, templateConfig)
+		if err != nil {
+			return err
+		}
+		path, _ := config["filepath"].(string)
+		if !isFile(path) {
+			return fmt.Errorf("included template %s not found", path)
+		}
+		return resolveInclude(path, config, templateConfig)
+	case load.IsRegistered(st.Plugin):
+		return nil
+	}
+	pluginPath := st.PluginPath
+	if pluginPath == "" {
+		pluginPath = templateConfig.PluginPath
+	}
+	if pluginPath == "" {
+		pluginPath = DefaultPluginPath
+	}
+	dir, err := helper.ExpandHome(pluginPath)
+	if err != nil {
+		return err
+	}
+	if !isFile(filepath.Join(dir, st.Plugin)) {
+		return fmt.Errorf("plugin %s is neither a static plugin nor found in %s", st.Plugin, pluginPath)
+	}
+	return nil
+}
+
+// resolveInclude loads the included template at `path` with the variables of its include `config`, as the include
+// plugin does, and resolves its plugins.
+func resolveInclude(path string, config map[string]any, templateConfig TemplateConfig) error {
+	variables, _ := config["variables"].(map[string]any)
+	variables = maps.Clone(variables)
+	if variables == nil {
+		variables = map[string]any{}
+	}
+	variables["is_included"] = true
+	included, err := NewTemplateFromFile[Stage](path, WithLoader(templateConfig.Loader), WithPluginPath(templateConfig.PluginPath),
+		WithVariables(variables), func(t *TemplateConfig) { t.SecretStore = templateConfig.SecretStore })
+	if err != nil {
+		return fmt.Errorf("included template %s: %w", path, err)
+	}
+	return ResolvePlugins(included)
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// Warning is a problem of a template which does not prevent it from running. `Stage` is empty when the warning is
+// about the whole template.
+type Warning struct {
+	Stage   string
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Stage == "" {
+		return w.Message
+	}
+	return fmt.Sprintf("stage %s: %s", w.Stage, w.Message)
+}
+
+// Lint warns about the stages connected to no other stage, the leaf stages whose outputs are read by no one, the
+// variables the template does not use and the deprecated plugin names. `vertices` are the loaded stages of the
+// template, telling the kind of their plugin.
+func Lint(tpl Template[Stage], vertices []graph.IOWorkerVertex[message.Message]) []Warning {
+	warnings := []Warning{}
+	read := map[string]bool{}
+	for _, stage := range tpl.Stages {
+		for _, ref := range stage.GetParents() {
+			parent, _ := graph.ParseParent(ref)
+			read[parent] = true
+		}
+		if deadLetter := stage.GetDeadLetter(); deadLetter != "" {
+			read[deadLetter] = true
+		}
+	}
+	for _, vertex := range slices.SortedFunc(slices.Values(vertices), func(a, b graph.IOWorkerVertex[message.Message]) int {
+		return strings.Compare(a.GetName(), b.GetName())
+	}) {
+		name := vertex.GetName()
+		switch {
+		case read[name]:
+		case len(vertex.GetParents()) == 0 && len(tpl.Stages) > 1:
+			warnings = append(warnings, Warning{Stage: name, Message: "not connected to any other stage"})
+		case slices.Contains([]graph.Kind{graph.KindProducer, graph.KindWorker, graph.KindRunner}, vertex.Kind()):
+			warnings = append(warnings, Warning{Stage: name, Message: fmt.Sprintf("leaf %s stage, its outputs are read by no stage", vertex.Kind())})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(tpl.Stages)) {
+		stage, err := tpl.Stages[name].expandInclude()
+		if err != nil {
+			continue
+		}
+		for _, plugin := range stage.pluginNames() {
+			if replacement, ok := load.Deprecated(plugin); ok {
+				warnings = append(warnings, Warning{Stage: name, Message: fmt.Sprintf("plugin %s is deprecated, use %s", plugin, replacement)})
+			}
+		}
+	}
+	return append(warnings, unusedVariables(tpl)...)
+}
+
+// pluginNames returns the plugin of the stage and the plugins of its pipe.
+func (st Stage) pluginNames() []string {
+	names := []string{st.Plugin}
+	if _, ok := load.Deprecated(st.Plugin); st.Plugin != "pipe" && !ok {
+		return names
+	}
+	steps, _ := st.Config.([]any)
+	for _, step := range steps {
+		if plugins, ok := step.(map[string]any); ok {
+			names = append(names, slices.Sorted(maps.Keys(plugins))...)
+		}
+	}
+	return names
+}
+
+// unusedVariables warns about the declared inputs and the given variables the template never refers to.
+func unusedVariables(tpl Template[Stage]) []Warning {
+	tree := parse.New("lint")
+	tree.Mode = parse.SkipFuncCheck
+	if _, err := tree.Parse(string(tpl.source), "", "", map[string]*parse.Tree{}); err != nil || tree.Root == nil {
+		return nil
+	}
+	used, all := map[string]bool{}, false
+	walkFields(tree.Root, false, func(field string, _ bool) {
+		if field == "" {
+			all = true
+		}
+		used[field] = true
+	})
+	if all {
+		return nil
+	}
+	warnings := []Warning{}
+	for _, name := range slices.Sorted(maps.Keys(tpl.config.Variables)) {
+		if used[name] {
+			continue
+		}
+		if slices.ContainsFunc(tpl.Inputs, func(input Input) bool { return input.Name == name }) {
+			warnings = append(warnings, Warning{Message: fmt.Sprintf("input %s is declared but not used", name)})
+			continue
+		}
+		warnings = append(warnings, Warning{Message: fmt.Sprintf("variable %s is given but not used", name)})
+	}
+	return warnings
+}
+
+// undeclaredVariables returns the variables the raw template refers to at the top level of its data, like `.name`
+// outside of any `with` or `range` block or `$.name`, which are missing from `variables`. The fields of the values
+// of `with` and `range` blocks are not variables, like the fields `| default` completes.
+func undeclaredVariables(raw []byte, variables map[string]any) []string {
+	tree := parse.New("variables")
+	tree.Mode = parse.SkipFuncCheck
+	if _, err := tree.Parse(string(raw), "", "", map[string]*parse.Tree{}); err != nil || tree.Root == nil {
+		// The parse error is reported by the interpolation.
+		return nil
+	}
+	undeclared := []string{}
+	walkFields(tree.Root, false, func(field string, nested bool) {
+		if _, ok := variables[field]; !ok && field != "" && !nested && !slices.Contains(undeclared, field) {
+			undeclared = append(undeclared, field)
+		}
+	})
+	return undeclared
+}
+
+// walkFields calls `field` with the first field of each reference to the data of the template, like `name` for
+// `.name.first` or `$.name`, and with an empty string when the whole data is passed, like in `toJson .`.
+// Inside `with` and `range` blocks, `nested`, the dot is another value: its fields are still reported, which is
+// conservative, with `nested` set, but the dot itself is not.
+func walkFields(node parse.Node, nested bool, field func(name string, nested bool)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkFields(child, nested, field)
+		}
+	case *parse.ActionNode:
+		walkFields(n.Pipe, nested, field)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, nested, nested, field)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, nested, true, field)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, nested, true, field)
+	case *parse.TemplateNode:
+		walkFields(n.Pipe, nested, field)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walkFields(cmd, nested, field)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkFields(arg, nested, field)
+		}
+	case *parse.ChainNode:
+		walkFields(n.Node, nested, field)
+	case *parse.FieldNode:
+		field(n.Ident[0], nested)
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" {
+			if len(n.Ident) > 1 {
+				field(n.Ident[1], false)
+			} else {
+				field("", false)
+			}
+		}
+	case *parse.DotNode:
+		if !nested {
+			field("", false)
+		}
+	}
+}
+
+// walkBranch walks the pipe and the else branch of a block in the current dot, its body in `nestedBody`.
+func walkBranch(n *parse.BranchNode, nested, nestedBody bool, field func(name string, nested bool)) {
+	walkFields(n.Pipe, nested, field)
+	walkFields(n.List, nestedBody, field)
+	walkFields(n.ElseList, nested, field)
+}
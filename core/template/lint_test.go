@@ -0,0 +1,150 @@
+package template_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/benji-bou/lugh/core/plugins"
+	"github.com/benji-bou/lugh/core/template"
+)
+
+const linted = `name: linted
+inputs:
+  - name: sep
+    default: ","
+  - name: unused
+    default: x
+stages:
+  split:
+    plugin: split
+    config:
+      sep: {{ .sep | quote }}
+  leaf:
+    plugin: goTemplate
+    parents: [split]
+    config:
+      format: "{{ "{{ range . }}{{ . }}{{ end }}" }}"
+  lonely:
+    plugin: forward
+  piped:
+    plugin: transform
+    parents: [split]
+    config:
+      - goTemplate:
+          format: x
+  out:
+    plugin: output
+    parents: [piped]
+`
+
+func TestResolvePlugins(t *testing.T) {
+	plugins.InitLoader()
+	tpl, err := template.NewTemplate[template.Stage]([]byte(`name: unresolved
+stages:
+  known:
+    plugin: forward
+  unknown:
+    plugin: nowhere
+  included:
+    include: ./missing.yml
+`), template.WithPluginPath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = template.ResolvePlugins(tpl)
+	if !errors.Is(err, template.ErrUnresolvedPlugins) {
+		t.Fatalf("expected unresolved plugins, got %v", err)
+	}
+	for _, problem := range []string{"stage unknown: plugin nowhere", "stage included: included template missing.yml not found"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q to be reported in %q", problem, err)
+		}
+	}
+	if strings.Contains(err.Error(), "stage known") {
+		t.Errorf("expected the static plugin to resolve, got %v", err)
+	}
+}
+
+func TestLint(t *testing.T) {
+	plugins.InitLoader()
+	tpl, err := template.NewTemplate[template.Stage]([]byte(linted), template.WithVariables(map[string]any{"extra": "1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := template.ResolvePlugins(tpl); err != nil {
+		t.Fatal(err)
+	}
+	vertices, err := tpl.WorkerVertexIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings := []string{}
+	for _, warning := range template.Lint(tpl, vertices) {
+		warnings = append(warnings, warning.String())
+	}
+	expected := []string{
+		"stage leaf: leaf worker stage, its outputs are read by no stage",
+		"stage lonely: not connected to any other stage",
+		"stage leaf: plugin goTemplate is deprecated, use template",
+		"stage piped: plugin transform is deprecated, use pipe",
+		"stage piped: plugin goTemplate is deprecated, use template",
+		"variable extra is given but not used",
+		"input unused is declared but not used",
+	}
+	if !slices.Equal(warnings, expected) {
+		t.Errorf("expected warnings\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(warnings, "\n"))
+	}
+}
+
+func TestResolveShippedTemplates(t *testing.T) {
+	plugins.InitLoader()
+	paths, err := filepath.Glob("../../templates/*/*.yml")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("expected the shipped templates, got %v: %v", paths, err)
+	}
+	// The gRPC plugins are built from the directories of the plugins tree, named after them, and only resolved by name.
+	pluginPath := t.TempDir()
+	err = filepath.WalkDir("../../plugins", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return err
+		}
+		return os.WriteFile(filepath.Join(pluginPath, entry.Name()), nil, 0o600)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		tpl, err := template.NewTemplateFromFile[template.Stage](path, template.WithPluginPath(pluginPath))
+		if err != nil {
+			t.Errorf("template %s: %v", path, err)
+			continue
+		}
+		if err := template.ResolvePlugins(tpl); err != nil {
+			t.Errorf("template %s: %v", path, err)
+		}
+	}
+}
+
+func TestValidateStageNames(t *testing.T) {
+	tpl, err := template.NewTemplate[template.Stage]([]byte(`name: dotted
+stages:
+  a:
+    plugin: forward
+  a.b:
+    plugin: forward
+  c:
+    plugin: output
+    parents: [a.b]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tpl.Validate(); !errors.Is(err, template.ErrInvalidStageName) || !strings.Contains(err.Error(), "a.b") {
+		t.Errorf("expected the dotted stage name to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package template
+
+import (
+	"fmt"
+
+	"github.com/benji-bou/lugh/core/secret"
+)
+
+// ParseSecrets returns the secrets declared in the `secrets` section of the raw template. Like the inputs, the
+// section is read before the variables are interpolated.
+func ParseSecrets(raw []byte) ([]secret.Source, error) {
+	declared := struct {
+		Secrets []secret.Source `yaml:"secrets"`
+	}{}
+	if err := parseSection(raw, "secrets", &declared); err != nil {
+		return nil, fmt.Errorf("parse secrets: %w", err)
+	}
+	return declared.Secrets, nil
+}
+
+// resolveSecrets reads the declared secrets, their values are redacted from then on.
+func resolveSecrets(sources []secret.Source, store func() (*secret.Store, error)) (map[string]string, error) {
+	secrets := make(map[string]string, len(sources))
+	for _, source := range sources {
+		value, err := source.Resolve(store)
+		if err != nil {
+			return nil, err
+		}
+		secrets[source.Name] = value
+	}
+	return secrets, nil
+}
+
+// secretFunc is the `secret` function of the templates, returning the value of a declared secret.
+func secretFunc(secrets map[string]string) func(name string) (string, error) {
+	return func(name string) (string, error) {
+		value, ok := secrets[name]
+		if !ok {
+			return "", fmt.Errorf("secret %s is not declared in the secrets section", name)
+		}
+		return value, nil
+	}
+}
@@ -0,0 +1,46 @@
+package template_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benji-bou/lugh/core/plugins"
+	"github.com/benji-bou/lugh/core/template"
+)
+
+const usingSecrets = `name: secrets
+secrets:
+  - name: key
+    env: LUGH_TEST_API_KEY
+stages:
+  auth:
+    plugin: insert
+    config:
+      content: "{{ secret "key" }}"
+`
+
+func TestSecrets(t *testing.T) {
+	plugins.InitLoader()
+	t.Setenv("LUGH_TEST_API_KEY", "template-api-key")
+	tpl, err := template.NewTemplate[template.Stage]([]byte(usingSecrets))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config, _ := tpl.Stages["auth"].Config.(map[string]any); config["content"] != "template-api-key" {
+		t.Errorf("expected the secret in the config, got %v", tpl.Stages["auth"].Config)
+	}
+	vertex, err := tpl.StageVertex("auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := vertex.Describe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(desc.Config, "template-api-key") {
+		t.Errorf("expected the secret to be redacted from the description, got %s", desc.Config)
+	}
+	if _, err := template.NewTemplate[template.Stage]([]byte(`content: "{{ secret "undeclared" }}"`)); err == nil {
+		t.Errorf("expected an undeclared secret to fail the template")
+	}
+}
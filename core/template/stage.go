@@ -1,26 +1,320 @@
 package template
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
 	"github.com/benji-bou/lugh/core/plugins/load"
+	"github.com/benji-bou/lugh/core/secret"
 )
 
+// Stage is a step of a template pipeline.
+// `parents` are the stages read by the stage, `stage.port` reads a named output of the stage instead of the default one.
+// `concurrency` is the number of inputs processed in parallel by the stage, only Worker plugins support it.
+// `ordered` emits the outputs in the order of their inputs when processed concurrently.
+// `onError` decides what happens to the inputs the stage fails to process.
+// `fanout` decides which children receive each output of the stage.
+// `rateLimit` throttles the inputs of the stage and `timeout` bounds the time spent on each of them,
+// a timed out input goes through `onError`. Producers support neither, and in-process runners no timeout: the gRPC
+// plugins forward it to their server, which bounds each input.
+// `include` runs the template at this path, relative to the including template, as the stage. Its `variables` are
+// merged over the variables of the including template. It is a shorthand for the `include` plugin.
 type Stage struct {
-	PluginPath string   `yaml:"pluginPath"`
-	Plugin     string   `yaml:"plugin"`
-	Config     any      `yaml:"config"`
-	Parents    []string `yaml:"parents"`
+	PluginPath  string         `yaml:"pluginPath"`
+	Plugin      string         `yaml:"plugin"`
+	Config      any            `yaml:"config"`
+	Include     string         `yaml:"include,omitempty"`
+	Variables   map[string]any `yaml:"variables,omitempty"`
+	Parents     []string       `yaml:"parents"`
+	Buffer      Buffer         `yaml:"buffer"`
+	Concurrency int            `yaml:"concurrency"`
+	Ordered     bool           `yaml:"ordered"`
+	OnError     OnError        `yaml:"onError"`
+	Fanout      Fanout         `yaml:"fanout"`
+	RateLimit   RateLimit      `yaml:"rateLimit"`
+	Timeout     time.Duration  `yaml:"timeout"`
 }
 
-func (st Stage) LoadPlugin(name string, templateConfig TemplateConfig) (graph.IOWorkerVertex[[]byte], error) {
+// includePlugin is the plugin running an included template.
+const includePlugin = "include"
+
+// expandInclude turns the `include` and `variables` fields into the config of the include plugin.
+func (st Stage) expandInclude() (Stage, error) {
+	if st.Include == "" {
+		if st.Variables != nil {
+			return st, errors.New("variables require include")
+		}
+		return st, nil
+	}
+	if st.Plugin != "" && st.Plugin != includePlugin {
+		return st, fmt.Errorf("include can't be used with plugin %s", st.Plugin)
+	}
+	if st.Config != nil {
+		return st, errors.New("include can't be used with config")
+	}
+	st.Plugin = includePlugin
+	config := map[string]any{"filepath": st.Include}
+	if st.Variables != nil {
+		config["variables"] = st.Variables
+	}
+	st.Config = config
+	return st, nil
+}
+
+// includeConfig completes the config of the include plugin: the path is resolved relative to the template directory
+// and the variables of the template are passed to the included one, overridden by the variables of the stage.
+func includeConfig(config any, templateConfig TemplateConfig) (map[string]any, error) {
+	stageConfig, ok := config.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid include config type. Expected map got %T", config)
+	}
+	res := maps.Clone(stageConfig)
+	if path, ok := res["filepath"].(string); ok && path != "" && !filepath.IsAbs(path) {
+		res["filepath"] = filepath.Join(templateConfig.Dir, path)
+	}
+	variables := maps.Clone(templateConfig.Variables)
+	if variables == nil {
+		variables = map[string]any{}
+	}
+	if includesVar, ok := res["variables"]; ok && includesVar != nil {
+		includes, ok := includesVar.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid include variables type. Expected map got %T", includesVar)
+		}
+		maps.Copy(variables, includes)
+	}
+	res["variables"] = variables
+	res["pluginpath"] = templateConfig.PluginPath
+	res["runid"] = templateConfig.RunID
+	return res, nil
+}
+
+// RateLimit is the number of inputs a stage processes per second, `burst` inputs may be processed at once.
+type RateLimit struct {
+	PerSecond float64 `yaml:"perSecond"`
+	Burst     int     `yaml:"burst"`
+}
+
+// Buffer configures the queue between the stage output and each of its children.
+// Policy is one of `block` (default), `dropOldest`, `dropNewest`, `spill` or `disk`.
+// SpillDir is where the `spill` and `disk` policies store their queue, the system temporary directory if empty,
+// in segment files of `segmentSize` bytes. The buffer of the template applies to the stages without one.
+// The queues of a checkpointed run are kept in `<spillDir>/<runID>/<stage>`, `lugh-queues` of the system temporary
+// directory if empty, and reopened on resume. See `graph.BufferConfig`.
+type Buffer struct {
+	Size        int    `yaml:"size,omitempty"`
+	Policy      string `yaml:"policy,omitempty"`
+	SpillDir    string `yaml:"spillDir,omitempty"`
+	SegmentSize int64  `yaml:"segmentSize,omitempty"`
+}
+
+func (b Buffer) graphBuffer() (graph.BufferConfig[message.Message], error) {
+	policy, err := graph.ParseBufferPolicy(b.Policy)
+	if err != nil {
+		return graph.BufferConfig[message.Message]{}, err
+	}
+	return graph.BufferConfig[message.Message]{
+		Size:        b.Size,
+		Policy:      policy,
+		SpillDir:    b.SpillDir,
+		SegmentSize: b.SegmentSize,
+		Codec:       message.Codec{},
+	}, nil
+}
+
+// persistentBuffer keeps the disk queues of `stage` in a directory of `buffer.SpillDir` named after the run,
+// to be reopened on resume.
+func persistentBuffer(buffer graph.BufferConfig[message.Message], runID, stage string) graph.BufferConfig[message.Message] {
+	if buffer.SpillDir == "" {
+		buffer.SpillDir = filepath.Join(os.TempDir(), "lugh-queues")
+	}
+	buffer.QueueDir = filepath.Join(buffer.SpillDir, url.PathEscape(runID), url.PathEscape(stage))
+	return buffer
+}
+
+// Fanout configures how the outputs of a stage are shared between its children.
+// Mode is one of `broadcast` (default), `roundRobin`, `hash` or `firstAvailable`.
+// Key is the message field partitioning the outputs in `hash` mode, the payload if empty. See `message.Field`.
+type Fanout struct {
+	Mode string `yaml:"mode"`
+	Key  string `yaml:"key"`
+}
+
+func (f Fanout) graphFanout() (graph.FanoutConfig[message.Message], error) {
+	mode, err := graph.ParseFanoutMode(f.Mode)
+	if err != nil {
+		return graph.FanoutConfig[message.Message]{}, err
+	}
+	if f.Key != "" && mode != graph.FanoutHash {
+		return graph.FanoutConfig[message.Message]{}, fmt.Errorf("key is only used by the %s mode", graph.FanoutHash)
+	}
+	key, err := message.Field(f.Key)
+	if err != nil {
+		return graph.FanoutConfig[message.Message]{}, err
+	}
+	return graph.FanoutConfig[message.Message]{Mode: mode, Key: key}, nil
+}
+
+// OnError configures the error policy of a stage.
+// A failing input is retried `retry` times, waiting `backoff` (doubled after each attempt, up to `maxBackoff`).
+// Then `action` applies: `report` (default) sends the error to the run, `skip` drops the input,
+// `fail` cancels the whole run and `deadLetter` sends the input with its error to the `deadLetter` stage.
+// Setting `deadLetter` alone implies the `deadLetter` action.
+type OnError struct {
+	Retry      int           `yaml:"retry"`
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"maxBackoff"`
+	Action     string        `yaml:"action"`
+	DeadLetter string        `yaml:"deadLetter"`
+}
+
+func (oe OnError) graphErrorPolicy(stage string) (graph.ErrorPolicy[message.Message], error) {
+	if oe.Action == "" && oe.DeadLetter != "" {
+		oe.Action = string(graph.ErrorDeadLetter)
+	}
+	action, err := graph.ParseErrorAction(oe.Action)
+	if err != nil {
+		return graph.ErrorPolicy[message.Message]{}, err
+	}
+	if action == graph.ErrorDeadLetter && oe.DeadLetter == "" {
+		return graph.ErrorPolicy[message.Message]{}, errors.New("deadLetter action requires a deadLetter stage")
+	}
+	return graph.ErrorPolicy[message.Message]{
+		RetryPolicy:    graph.RetryPolicy{Retry: oe.Retry, Backoff: oe.Backoff, MaxBackoff: oe.MaxBackoff},
+		Action:         action,
+		DeadLetter:     oe.DeadLetter,
+		DeadLetterItem: message.DeadLetter(stage),
+	}, nil
+}
+
+func (st Stage) GetParents() []string {
+	return st.Parents
+}
+
+// setCheckpoint records the inputs processed by the stage. Producers, included templates and the runners unable to
+// tell when they are done with an input run again on resume, see `graph.CheckpointConfigurer`.
+func (st Stage) setCheckpoint(name string, secplugin any, store CheckpointStore) error {
+	if graph.KindOf(secplugin) == graph.KindProducer {
+		return nil
+	}
+	checkpoint, err := store.Stage(name)
+	if err != nil {
+		return fmt.Errorf("stage %s checkpoint: %w", name, err)
+	}
+	if err := graph.SetCheckpoint(secplugin, checkpoint); errors.Is(err, graph.ErrCheckpointNotSupported) {
+		slog.Warn("stage inputs are not checkpointed, they are processed again on resume", "stage", name, "plugin", st.Plugin)
+	} else if err != nil {
+		return fmt.Errorf("stage %s plugin %s checkpoint: %w", name, st.Plugin, err)
+	}
+	return nil
+}
+
+// GetDeadLetter returns the stage receiving the inputs this stage fails to process, if any.
+func (st Stage) GetDeadLetter() string {
+	return st.OnError.DeadLetter
+}
+
+func (st Stage) LoadPlugin(name string, templateConfig TemplateConfig) (graph.IOWorkerVertex[message.Message], error) {
+	st, err := st.expandInclude()
+	if err != nil {
+		return graph.IOWorkerVertex[message.Message]{}, fmt.Errorf("stage %s include: %w", name, err)
+	}
 	if st.PluginPath == "" {
 		st.PluginPath = templateConfig.PluginPath
 	}
-	secplugin, err := load.Worker(st.Plugin, st.PluginPath, st.Config)
+	if st.Buffer == (Buffer{}) {
+		st.Buffer = templateConfig.Buffer
+	}
+	buffer, err := st.Buffer.graphBuffer()
 	if err != nil {
-		return graph.IOWorkerVertex[[]byte]{}, fmt.Errorf("stage %s loading plugin %s: %w", name, st.Plugin, err)
+		return graph.IOWorkerVertex[message.Message]{}, fmt.Errorf("stage %s buffer: %w", name, err)
+	}
+	if templateConfig.Checkpoints != nil {
+		buffer = persistentBuffer(buffer, templateConfig.RunID, name)
+	}
+	fanout, err := st.Fanout.graphFanout()
+	if err != nil {
+		return graph.IOWorkerVertex[message.Message]{}, fmt.Errorf("stage %s fanout: %w", name, err)
+	}
+	errorPolicy, err := st.OnError.graphErrorPolicy(name)
+	if err != nil {
+		return graph.IOWorkerVertex[message.Message]{}, fmt.Errorf("stage %s onError: %w", name, err)
+	}
+	// The summary is made before completing the config, with the entries written in the template only.
+	config := summarizeConfig(st.Config)
+	pluginConfig := st.Config
+	if st.Plugin == includePlugin {
+		if pluginConfig, err = includeConfig(st.Config, templateConfig); err != nil {
+			return graph.IOWorkerVertex[message.Message]{}, fmt.Errorf("stage %s include: %w", name, err)
+		}
+	}
+	secplugin, err := load.Worker(st.Plugin, st.PluginPath, pluginConfig)
+	if err != nil {
+		return graph.IOWorkerVertex[message.Message]{}, fmt.Errorf("stage %s loading plugin %s: %w", name, st.Plugin, err)
+	}
+	if st.Concurrency > 1 || st.Ordered {
+		if err := graph.SetConcurrency(secplugin, max(st.Concurrency, 1), st.Ordered); err != nil {
+			return graph.IOWorkerVertex[message.Message]{}, fmt.Errorf("stage %s plugin %s concurrency: %w", name, st.Plugin, err)
+		}
+	}
+	if err := graph.SetErrorPolicy(secplugin, errorPolicy); err != nil {
+		return graph.IOWorkerVertex[message.Message]{}, fmt.Errorf("stage %s plugin %s onError: %w", name, st.Plugin, err)
+	}
+	if err := graph.SetRateLimit(secplugin, graph.RateLimit(st.RateLimit)); err != nil {
+		return graph.IOWorkerVertex[message.Message]{}, fmt.Errorf("stage %s plugin %s rateLimit: %w", name, st.Plugin, err)
+	}
+	if err := graph.SetTimeout(secplugin, st.Timeout); err != nil {
+		return graph.IOWorkerVertex[message.Message]{}, fmt.Errorf("stage %s plugin %s timeout: %w", name, st.Plugin, err)
+	}
+	if templateConfig.Checkpoints != nil {
+		if err := st.setCheckpoint(name, secplugin, templateConfig.Checkpoints); err != nil {
+			return graph.IOWorkerVertex[message.Message]{}, err
+		}
+	}
+	pluginPath := ""
+	if !load.IsRegistered(st.Plugin) {
+		pluginPath = st.PluginPath
+	}
+	return graph.NewIOWorkerVertex(name, st.Parents, secplugin,
+		graph.WithDeadLetter[message.Message](errorPolicy.DeadLetter),
+		graph.WithPluginName[message.Message](st.Plugin),
+		graph.WithPluginPath[message.Message](pluginPath),
+		graph.WithConfigSummary[message.Message](config),
+		graph.WithVertexBuffer(buffer),
+		graph.WithVertexFanout(fanout),
+		graph.WithVertexOutputMap(message.Stamp(name, templateConfig.RunID)),
+		graph.WithVertexPorts(message.Port),
+	), nil
+}
+
+// configSummaryLength is the maximum length of the config summary describing a stage.
+const configSummaryLength = 80
+
+// summarizeConfig returns the config in compact JSON, truncated to `configSummaryLength`. Secrets are redacted.
+func summarizeConfig(config any) string {
+	if config == nil {
+		return ""
+	}
+	raw := &bytes.Buffer{}
+	encoder := json.NewEncoder(raw)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(config); err != nil {
+		return secret.Redact(fmt.Sprintf("%v", config))
+	}
+	summary := []rune(secret.Redact(strings.TrimSpace(raw.String())))
+	if len(summary) <= configSummaryLength {
+		return string(summary)
 	}
-	return graph.NewIOWorkerVertex(name, st.Parents, secplugin), nil
+	return string(summary[:configSummaryLength-1]) + "…"
 }
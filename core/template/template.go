@@ -2,43 +2,48 @@ package template
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"log/slog"
 	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/Masterminds/sprig/v3"
 	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
 	"github.com/benji-bou/lugh/core/plugins/load"
+	"github.com/benji-bou/lugh/core/secret"
 	"github.com/benji-bou/lugh/helper"
+	gr "github.com/dominikbraun/graph"
+	"github.com/google/uuid"
 	"gopkg.in/yaml.v3"
 )
 
+// TemplateConfig is shared by all the stages of a template.
+// `RunID` is set in the headers of every message emitted by the stages, a new one is generated when empty.
+// `Checkpoints` records the inputs processed by each stage when set.
+// `Buffer` is used by the stages without a buffer of their own.
+// `Dir` is the directory the included templates are resolved from, the working directory if empty.
+// `SecretStore` opens the store of the secrets read from a store, `secret.DefaultStore` if nil.
 type TemplateConfig struct {
-	PluginPath string
-	Variables  map[string]interface{}
-	Loader     *load.Loader
-}
-
-// wrapTemplatePlugin wraps the `templates plugins“ loader to pass template config to plugins.
-// This is useful for plugins that need to know the template config, like the `include` plugin.
-func (tc *TemplateConfig) wrapTemplatePlugin() {
-	tc.Loader.WrapLoader("include", func(next load.Loadable) load.Loadable {
-		return load.ConfigAsMap(func(name string, path string, config map[string]any) (any, error) {
-			if includesVar, ok := config["variables"]; !ok {
-				config["variables"] = tc.Variables
-			} else if includes, ok := includesVar.(map[string]any); !ok {
-				return nil, fmt.Errorf("invalid include variables type. Expected map got %T", includesVar)
-			} else {
-				variables := make(map[string]any)
-				maps.Copy(variables, tc.Variables)
-				maps.Copy(variables, includes)
-				config["variables"] = variables
-			}
-			config["pluginpath"] = tc.PluginPath
-			return next.Load(name, path, config)
-		})
-	})
+	PluginPath  string
+	Variables   map[string]interface{}
+	Loader      *load.Loader
+	RunID       string
+	Checkpoints CheckpointStore
+	Buffer      Buffer
+	Dir         string
+	SecretStore func() (*secret.Store, error)
+}
+
+// CheckpointStore opens the checkpoint of each stage of a run. See `graph.Checkpoint`.
+type CheckpointStore interface {
+	Stage(name string) (graph.Checkpoint[message.Message], error)
 }
 
 type TemplateOption = helper.Option[TemplateConfig]
@@ -46,7 +51,6 @@ type TemplateOption = helper.Option[TemplateConfig]
 func WithLoader(loader *load.Loader) TemplateOption {
 	return func(t *TemplateConfig) {
 		t.Loader = loader
-		t.wrapTemplatePlugin()
 	}
 }
 
@@ -66,19 +70,73 @@ func WithPluginPath(path string) TemplateOption {
 	}
 }
 
+// WithDir sets the directory the included templates are resolved from.
+func WithDir(dir string) TemplateOption {
+	return func(t *TemplateConfig) {
+		t.Dir = dir
+	}
+}
+
+// WithSecretStore reads the secrets of the template stored in `store`.
+func WithSecretStore(store *secret.Store) TemplateOption {
+	return func(t *TemplateConfig) {
+		t.SecretStore = func() (*secret.Store, error) { return store, nil }
+	}
+}
+
+// WithRunID sets the run id of the messages emitted by the template stages.
+func WithRunID(runID string) TemplateOption {
+	return func(t *TemplateConfig) {
+		t.RunID = runID
+	}
+}
+
+// WithCheckpoints records the inputs processed by each stage in `store`. The inputs already recorded are skipped.
+func WithCheckpoints(store CheckpointStore) TemplateOption {
+	return func(t *TemplateConfig) {
+		t.Checkpoints = store
+	}
+}
+
 type (
 	PluginLoader interface {
-		LoadPlugin(name string, tplCtx TemplateConfig) (graph.IOWorkerVertex[[]byte], error)
+		LoadPlugin(name string, tplCtx TemplateConfig) (graph.IOWorkerVertex[message.Message], error)
+		GetParents() []string
+		GetDeadLetter() string
 	}
 )
 
+// stageVertex is the lightweight description of a stage used to validate the template graph without loading any plugin.
+type stageVertex struct {
+	name       string
+	parents    []string
+	deadLetter string
+}
+
+func (sv stageVertex) GetName() string {
+	return sv.name
+}
+
+func (sv stageVertex) GetParents() []string {
+	return sv.parents
+}
+
+func (sv stageVertex) GetDeadLetter() string {
+	return sv.deadLetter
+}
+
 type Template[S PluginLoader] struct {
-	Name        string       `yaml:"name" json:"name"`
-	Description string       `yaml:"description" json:"description"`
-	Version     string       `yaml:"version" json:"version"`
-	Author      string       `yaml:"author" json:"author"`
-	Stages      map[string]S `yaml:"stages" json:"stages"`
+	Name        string          `yaml:"name" json:"name"`
+	Description string          `yaml:"description" json:"description"`
+	Version     string          `yaml:"version" json:"version"`
+	Author      string          `yaml:"author" json:"author"`
+	Inputs      Inputs          `yaml:"inputs,omitempty" json:"inputs,omitempty"`
+	Secrets     []secret.Source `yaml:"secrets,omitempty" json:"secrets,omitempty"`
+	Buffer      Buffer          `yaml:"buffer,omitempty" json:"buffer,omitempty"`
+	Stages      map[string]S    `yaml:"stages" json:"stages"`
 	config      TemplateConfig
+	// source is the template before its variables are interpolated.
+	source []byte
 }
 
 func (t Template[S]) Raw() ([]byte, error) {
@@ -93,12 +151,13 @@ func NewFile[S PluginLoader](path string, opt ...TemplateOption) (Template[S], e
 	return NewTemplateFromFile[S](path, opt...)
 }
 
+// NewTemplateFromFile reads the template at `path`, its includes are resolved from the directory of the file.
 func NewTemplateFromFile[S PluginLoader](path string, opt ...TemplateOption) (Template[S], error) {
 	content, err := os.ReadFile(path) // #nosec G304
 	if err != nil {
 		return Template[S]{}, err
 	}
-	return NewTemplate[S](content, opt...)
+	return NewTemplate[S](content, append([]TemplateOption{WithDir(filepath.Dir(path))}, opt...)...)
 }
 
 func New[S PluginLoader](raw []byte, opt ...TemplateOption) (Template[S], error) {
@@ -110,17 +169,53 @@ func NewTemplate[S PluginLoader](raw []byte, opt ...TemplateOption) (Template[S]
 	defaultOptions = append(defaultOptions, WithDefaultLoader())
 	defaultOptions = append(defaultOptions, opt...)
 	tplConfig := helper.Configure(TemplateConfig{Variables: map[string]interface{}{}}, defaultOptions...)
-	raw, err := InterpolateVariable(raw, tplConfig.Variables)
+	if tplConfig.RunID == "" {
+		tplConfig.RunID = uuid.NewString()
+	}
+	source := raw
+	inputs, err := ParseInputs(raw)
+	if err != nil {
+		return Template[S]{}, fmt.Errorf("parsing template, %w", err)
+	}
+	if tplConfig.Variables, err = inputs.Resolve(tplConfig.Variables); err != nil {
+		return Template[S]{}, err
+	}
+	sources, err := ParseSecrets(raw)
 	if err != nil {
 		return Template[S]{}, fmt.Errorf("parsing template, %w", err)
 	}
-	tpl := Template[S]{config: tplConfig}
-	err = yaml.Unmarshal(raw, &tpl)
-	return tpl, err
+	if tplConfig.SecretStore == nil {
+		tplConfig.SecretStore = sync.OnceValues(secret.DefaultStore)
+	}
+	secrets, err := resolveSecrets(sources, tplConfig.SecretStore)
+	if err != nil {
+		return Template[S]{}, err
+	}
+	// The variables of a template declaring its inputs are all known, a misspelled one is an error.
+	if undeclared := undeclaredVariables(raw, tplConfig.Variables); inputs != nil && len(undeclared) > 0 {
+		return Template[S]{}, fmt.Errorf("%w: undeclared variables %s", ErrInvalidInputs, strings.Join(undeclared, ", "))
+	}
+	raw, err = interpolate(raw, tplConfig.Variables, secrets)
+	if err != nil {
+		return Template[S]{}, fmt.Errorf("parsing template, %w", err)
+	}
+	tpl := Template[S]{config: tplConfig, source: source}
+	if err := yaml.Unmarshal(raw, &tpl); err != nil {
+		return tpl, err
+	}
+	tpl.config.Buffer = tpl.Buffer
+	return tpl, nil
 }
 
 func InterpolateVariable(raw []byte, variables map[string]interface{}) ([]byte, error) {
-	goTpl, err := template.New("TemplateInterpolation").Funcs(sprig.FuncMap()).Parse(string(raw))
+	return interpolate(raw, variables, nil)
+}
+
+// interpolate renders the raw template with `variables`, `secrets` are read with the `secret` function.
+func interpolate(raw []byte, variables map[string]any, secrets map[string]string) ([]byte, error) {
+	funcs := sprig.FuncMap()
+	funcs["secret"] = secretFunc(secrets)
+	goTpl, err := template.New("TemplateInterpolation").Funcs(funcs).Parse(string(raw))
 	if err != nil {
 		return raw, fmt.Errorf("as go template failed, %w", err)
 	}
@@ -129,13 +224,72 @@ func InterpolateVariable(raw []byte, variables map[string]interface{}) ([]byte,
 	if err != nil {
 		return nil, fmt.Errorf("executing variable interpolation, %w", err)
 	}
-	res := interpolatedTemplate.Bytes()
-	fmt.Printf("raw: %s", string(res))
-	return res, nil
+	return interpolatedTemplate.Bytes(), nil
+}
+
+// ErrInvalidStageName is returned for a stage name containing a dot, which separates a stage from its port in
+// the parents of a stage.
+var ErrInvalidStageName = errors.New("stage names can't contain dots")
+
+// Validate checks the stage names, then the stages graph (cycles, unknown parents, self loops, duplicates and
+// orphans) using `graph.Validate`. No plugin is loaded, so it is safe to call before spawning any plugin process.
+func (t Template[S]) Validate() error {
+	for _, name := range slices.Sorted(maps.Keys(t.Stages)) {
+		if strings.Contains(name, ".") {
+			return fmt.Errorf("template %s is invalid: %w: %s", t.Name, ErrInvalidStageName, name)
+		}
+	}
+	g := graph.NewSelfDescribed[string, stageVertex](func(sv stageVertex) string { return sv.name }, gr.Directed())
+	vertices := make([]stageVertex, 0, len(t.Stages))
+	for name, stage := range t.Stages {
+		parents := make([]string, 0, len(stage.GetParents()))
+		for _, ref := range stage.GetParents() {
+			if parent, _ := graph.ParseParent(ref); !slices.Contains(parents, parent) {
+				parents = append(parents, parent)
+			}
+		}
+		vertices = append(vertices, stageVertex{name: name, parents: parents, deadLetter: stage.GetDeadLetter()})
+	}
+	err := g.AddVertices(vertices)
+	if err != nil {
+		slog.Debug("template validation: adding stages", "template", t.Name, "error", err)
+	}
+	if err := g.Validate(); err != nil {
+		return fmt.Errorf("template %s is invalid: %w", t.Name, err)
+	}
+	return nil
+}
+
+// RunID returns the run id set in the headers of the messages emitted by the template stages.
+func (t Template[S]) RunID() string {
+	return t.config.RunID
+}
+
+// StageVertex loads the plugin of the stage `name` alone, e.g. to replay its recorded inputs.
+func (t Template[S]) StageVertex(name string) (graph.IOWorkerVertex[message.Message], error) {
+	stage, ok := t.Stages[name]
+	if !ok {
+		return graph.IOWorkerVertex[message.Message]{}, fmt.Errorf("template %s has no stage %s", t.Name, name)
+	}
+	return stage.LoadPlugin(name, t.config)
+}
+
+// ReadPorts returns the ports of the stage `name` read by the other stages, the default one included.
+func (t Template[S]) ReadPorts(name string) []string {
+	ports := []string{graph.PortDefault}
+	for _, stage := range t.Stages {
+		for _, ref := range stage.GetParents() {
+			if parent, port := graph.ParseParent(ref); parent == name && !slices.Contains(ports, port) {
+				ports = append(ports, port)
+			}
+		}
+	}
+	slices.Sort(ports)
+	return ports
 }
 
-func (t Template[S]) WorkerVertexIterator() ([]graph.IOWorkerVertex[[]byte], error) {
-	workerVertices := make([]graph.IOWorkerVertex[[]byte], 0, len(t.Stages))
+func (t Template[S]) WorkerVertexIterator() ([]graph.IOWorkerVertex[message.Message], error) {
+	workerVertices := make([]graph.IOWorkerVertex[message.Message], 0, len(t.Stages))
 	for name, rawStage := range t.Stages {
 		worker, err := rawStage.LoadPlugin(name, t.config)
 		if err != nil {
@@ -0,0 +1,404 @@
+// Package templatetest runs a template end to end on declarative test cases.
+//
+// A suite is a YAML file naming a template, relative to the suite file, and its cases:
+//
+//	template: pipeline.yml
+//	cases:
+//	  - name: reports the errors
+//	    variables: {target: example.com}
+//	    inputs:            # items sent to root stages, the other roots receive nothing
+//	      split: ['{"status":200};{"status":500}']
+//	    mocks:             # stages replaced with fixtures, their plugin is not loaded
+//	      crawler: ['{"status":404}']
+//	    expect:            # outputs of stages, usually the leaves
+//	      out:
+//	        - '{"status":500}ERR '   # exact payload
+//	        - contains: "200"
+//	        - regex: '^\{'
+//
+// The outputs of a stage which never emits, like `output`, are the items it reads. A mocked stage reads all its
+// inputs then emits its fixtures. The expected outputs may come in any order unless the case is `ordered`, a stage
+// emitting more outputs than expected fails. The cases fail on any stage error and after `timeout`, 30s by default.
+package templatetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benji-bou/diwo"
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/graph/graphtest"
+	"github.com/benji-bou/lugh/core/message"
+	"github.com/benji-bou/lugh/core/template"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// Suite is a file of test cases.
+type Suite struct {
+	Template string `yaml:"template"`
+	Cases    []Case `yaml:"cases"`
+}
+
+// Case runs `Template`, the template of its suite when empty, with `Variables`. `Inputs` are the items sent to root
+// stages and `Mocks` the fixtures emitted by the mocked stages. `Expect` are the expected outputs of stages.
+type Case struct {
+	Name      string                   `yaml:"name"`
+	Template  string                   `yaml:"template"`
+	Variables map[string]any           `yaml:"variables"`
+	Inputs    map[string][]string      `yaml:"inputs"`
+	Mocks     map[string][]string      `yaml:"mocks"`
+	Expect    map[string][]Expectation `yaml:"expect"`
+	Ordered   bool                     `yaml:"ordered"`
+	Timeout   time.Duration            `yaml:"timeout"`
+}
+
+// Expectation is an expected output payload: `exact`, or containing `contains`, or matching the regex `regex`.
+// A plain string is an exact expectation.
+type Expectation struct {
+	Exact    *string `yaml:"exact"`
+	Contains string  `yaml:"contains"`
+	Regex    string  `yaml:"regex"`
+}
+
+func (e *Expectation) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		e.Exact = &node.Value
+		return nil
+	}
+	type plain Expectation
+	return node.Decode((*plain)(e))
+}
+
+func (e Expectation) matcher() (graphtest.Matcher[string], error) {
+	switch {
+	case e.Exact != nil && e.Contains == "" && e.Regex == "":
+		return graphtest.Equal(*e.Exact), nil
+	case e.Exact == nil && e.Contains != "" && e.Regex == "":
+		return graphtest.Contains(e.Contains), nil
+	case e.Exact == nil && e.Contains == "" && e.Regex != "":
+		return graphtest.Regex(e.Regex)
+	default:
+		return nil, errors.New("an expectation requires exactly one of exact, contains or regex")
+	}
+}
+
+// Load reads the suite `path`. The template paths are resolved from the directory of the suite.
+func Load(path string) (Suite, error) {
+	raw, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return Suite{}, fmt.Errorf("read test suite: %w", err)
+	}
+	suite := Suite{}
+	if err := yaml.Unmarshal(raw, &suite); err != nil {
+		return Suite{}, fmt.Errorf("parse test suite %s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	for index, c := range suite.Cases {
+		if c.Template == "" {
+			c.Template = suite.Template
+		}
+		if c.Template == "" {
+			return Suite{}, fmt.Errorf("test suite %s: case %q has no template", path, c.Name)
+		}
+		if !filepath.IsAbs(c.Template) {
+			c.Template = filepath.Join(dir, c.Template)
+		}
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("case %d", index+1)
+		}
+		suite.Cases[index] = c
+	}
+	return suite, nil
+}
+
+// Result is the outcome of a case, it passed when `Failures` is empty.
+type Result struct {
+	Name     string
+	Failures []string
+	Duration time.Duration
+}
+
+func (r Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// Run runs every case of the suite.
+func (s Suite) Run(ctx context.Context, opt ...template.TemplateOption) []Result {
+	results := make([]Result, 0, len(s.Cases))
+	for _, c := range s.Cases {
+		results = append(results, c.Run(ctx, opt...))
+	}
+	return results
+}
+
+// Run builds the template of the case with `opt`, runs it and checks its outputs.
+func (c Case) Run(ctx context.Context, opt ...template.TemplateOption) Result {
+	started := time.Now()
+	r := &reporter{}
+	c.run(ctx, r, opt)
+	return Result{Name: c.Name, Failures: r.failures, Duration: time.Since(started)}
+}
+
+func (c Case) run(ctx context.Context, r *reporter, opt []template.TemplateOption) {
+	tpl, err := template.NewFile[template.Stage](c.Template, append(opt, template.WithVariables(c.Variables))...)
+	if err != nil {
+		r.Errorf("load template %s: %v", c.Template, err)
+		return
+	}
+	if err := tpl.Validate(); err != nil {
+		r.Error(err)
+		return
+	}
+	matchers, ok := c.check(tpl, r)
+	if !ok {
+		return
+	}
+	vertices := make([]graph.IOWorkerVertex[message.Message], 0, len(tpl.Stages))
+	for name, stage := range tpl.Stages {
+		if fixtures, ok := c.Mocks[name]; ok {
+			vertices = append(vertices, mockVertex(name, stage, tpl.RunID(), fixtures))
+			continue
+		}
+		vertex, err := tpl.StageVertex(name)
+		if err != nil {
+			r.Error(err)
+			return
+		}
+		vertices = append(vertices, vertex)
+	}
+	t := newTap(c.Inputs, tpl.RunID(), slices.Collect(maps.Keys(c.Expect)))
+	g := graph.NewIO(graph.WithVertices(vertices), graph.WithMiddleware(t.middleware))
+	if err := g.Validate(); err != nil {
+		r.Error(err)
+		return
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	g.SetInput(diwo.Empty[message.Message]())
+	outputC := g.Output()
+	syncCtx := graph.NewContext(runCtx)
+	errC := g.Run(syncCtx)
+	syncCtx.Synchronize()
+	go func() {
+		for range outputC {
+		}
+	}()
+	for err := range errC {
+		r.Errorf("stage error: %v", err)
+	}
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		r.Errorf("timed out after %s", timeout)
+	}
+	for _, stage := range slices.Sorted(maps.Keys(matchers)) {
+		graphtest.AssertMatch(r.stage(stage), matchers[stage], t.outputs(stage), c.Ordered)
+	}
+}
+
+// check verifies that the case names existing stages, the inputs go to root stages.
+// It returns the matchers of the expected outputs of each stage.
+func (c Case) check(tpl template.Template[template.Stage], r *reporter) (map[string][]graphtest.Matcher[string], bool) {
+	ok := true
+	unknown := func(kind string, stages map[string]bool) {
+		for _, name := range slices.Sorted(maps.Keys(stages)) {
+			if _, exists := tpl.Stages[name]; !exists {
+				r.Errorf("%s of unknown stage %s", kind, name)
+				ok = false
+			}
+		}
+	}
+	unknown("inputs", keys(c.Inputs))
+	unknown("mock", keys(c.Mocks))
+	unknown("expected outputs", keys(c.Expect))
+	for name := range c.Inputs {
+		if stage, exists := tpl.Stages[name]; exists && len(stage.GetParents()) > 0 {
+			r.Errorf("inputs of stage %s which is not a root stage", name)
+			ok = false
+		}
+	}
+	matchers := make(map[string][]graphtest.Matcher[string], len(c.Expect))
+	for name, expectations := range c.Expect {
+		for _, expectation := range expectations {
+			matcher, err := expectation.matcher()
+			if err != nil {
+				r.Errorf("expected outputs of stage %s: %v", name, err)
+				ok = false
+				continue
+			}
+			matchers[name] = append(matchers[name], matcher)
+		}
+		if len(expectations) == 0 {
+			matchers[name] = []graphtest.Matcher[string]{}
+		}
+	}
+	return matchers, ok
+}
+
+func keys[V any](m map[string]V) map[string]bool {
+	res := make(map[string]bool, len(m))
+	for key := range m {
+		res[key] = true
+	}
+	return res
+}
+
+// mockVertex replaces the plugin of `stage` with a runner emitting `fixtures` once it read all its inputs.
+func mockVertex(name string, stage template.Stage, runID string, fixtures []string) graph.IOWorkerVertex[message.Message] {
+	mock := graph.NewIOWorkerFromRunner(graph.RunnerFunc[message.Message](
+		func(ctx context.Context, input <-chan message.Message, yield func(elem message.Message, err error) error) error {
+			for range input {
+			}
+			for _, fixture := range fixtures {
+				if err := yield(message.New([]byte(fixture), message.WithRunID(runID)), nil); err != nil {
+					return err
+				}
+			}
+			return ctx.Err()
+		},
+	))
+	return graph.NewIOWorkerVertex(name, stage.Parents, mock,
+		graph.WithDeadLetter[message.Message](stage.GetDeadLetter()),
+		graph.WithPluginName[message.Message]("mock"),
+		graph.WithVertexOutputMap(message.Stamp(name, runID)),
+		graph.WithVertexPorts(message.Port),
+	)
+}
+
+// tap sends the inputs of the case to the root stages and records the outputs of the expected stages.
+type tap struct {
+	inputs   map[string][]string
+	runID    string
+	expected []string
+	recorded map[string][]string
+	mux      sync.Mutex
+}
+
+func newTap(inputs map[string][]string, runID string, expected []string) *tap {
+	return &tap{inputs: inputs, runID: runID, expected: expected, recorded: map[string][]string{}}
+}
+
+func (t *tap) middleware(vertex graph.IOWorkerVertex[message.Message], worker graph.IOWorker[message.Message]) graph.IOWorker[message.Message] {
+	name := vertex.GetName()
+	_, hasInputs := t.inputs[name]
+	if !hasInputs && !slices.Contains(t.expected, name) {
+		return worker
+	}
+	w := &tapWorker{IOWorker: worker, tap: t, name: name, root: hasInputs, consumer: !graph.KindOf(worker).HasOutput()}
+	w.output = sync.OnceValue(func() <-chan message.Message {
+		outputC := w.IOWorker.Output()
+		if w.consumer || !slices.Contains(t.expected, name) {
+			return outputC
+		}
+		return diwo.Map(outputC, func(elem message.Message) message.Message {
+			t.record(name, elem)
+			return elem
+		})
+	})
+	return w
+}
+
+func (t *tap) record(stage string, elem message.Message) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.recorded[stage] = append(t.recorded[stage], string(elem.Payload))
+}
+
+func (t *tap) outputs(stage string) []string {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.recorded[stage]
+}
+
+type tapWorker struct {
+	graph.IOWorker[message.Message]
+	tap      *tap
+	name     string
+	root     bool
+	consumer bool
+	output   func() <-chan message.Message
+}
+
+func (w *tapWorker) SetInput(input <-chan message.Message) {
+	if w.root {
+		inputs := make([]message.Message, 0, len(w.tap.inputs[w.name]))
+		for _, payload := range w.tap.inputs[w.name] {
+			inputs = append(inputs, message.New([]byte(payload), message.WithSource("test"), message.WithRunID(w.tap.runID)))
+		}
+		input = diwo.FromSlice(inputs)
+	}
+	if w.consumer && input != nil && slices.Contains(w.tap.expected, w.name) {
+		input = diwo.Map(input, func(elem message.Message) message.Message {
+			w.tap.record(w.name, elem)
+			return elem
+		})
+	}
+	w.IOWorker.SetInput(input)
+}
+
+func (w *tapWorker) Output() <-chan message.Message {
+	return w.output()
+}
+
+func (w *tapWorker) Kind() graph.Kind {
+	return graph.KindOf(w.IOWorker)
+}
+
+func (w *tapWorker) NotifyDone(fn func(input message.Message, err error)) error {
+	return graph.NotifyDone(w.IOWorker, fn)
+}
+
+func (w *tapWorker) Restarts() uint64 {
+	return graph.RestartsOf(w.IOWorker)
+}
+
+func (w *tapWorker) DeadLetters() <-chan message.Message {
+	if emitter, ok := w.IOWorker.(graph.DeadLetterEmitter[message.Message]); ok {
+		return emitter.DeadLetters()
+	}
+	return nil
+}
+
+// reporter collects the failures of a case, it implements `graphtest.T`.
+type reporter struct {
+	prefix   string
+	parent   *reporter
+	failures []string
+	mux      sync.Mutex
+}
+
+func (r *reporter) stage(name string) *reporter {
+	return &reporter{prefix: "stage " + name + ": ", parent: r}
+}
+
+func (*reporter) Helper() {}
+
+func (r *reporter) Error(args ...any) {
+	r.fail(fmt.Sprint(args...))
+}
+
+func (r *reporter) Errorf(format string, args ...any) {
+	r.fail(fmt.Sprintf(format, args...))
+}
+
+func (r *reporter) fail(failure string) {
+	if r.parent != nil {
+		r.parent.fail(r.prefix + failure)
+		return
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.failures = append(r.failures, strings.TrimSpace(failure))
+}
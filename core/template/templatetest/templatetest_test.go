@@ -0,0 +1,153 @@
+package templatetest_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benji-bou/lugh/core/plugins"
+	"github.com/benji-bou/lugh/core/template/templatetest"
+)
+
+const pipeline = `name: routes
+stages:
+  split:
+    plugin: split
+    config:
+      sep: ";"
+  route:
+    plugin: route
+    parents: [split]
+    config:
+      routes:
+        - port: errors
+          expr: .status >= 400
+      unmatched: ok
+  errs:
+    plugin: insert
+    parents: [route.errors]
+    config:
+      content: "ERR "
+  out:
+    plugin: output
+    parents: [errs, route.ok]
+`
+
+const suite = `template: pipeline.yml
+cases:
+  - name: routes the errors
+    inputs:
+      split: ['{"status":200};{"status":500};{"status":404}']
+    expect:
+      errs:
+        - contains: '500'
+        - regex: '404\}ERR $'
+      out:
+        - '{"status":200}'
+        - contains: ERR
+        - contains: ERR
+  - name: mocks a stage
+    mocks:
+      split: ['{"status":503}']
+    expect:
+      out: ['{"status":503}ERR ']
+  - name: reports a diff
+    inputs:
+      split: ['{"status":200}']
+    expect:
+      out: ['{"status":500}']
+  - name: checks the stages
+    inputs:
+      route: ['{}']
+    expect:
+      missing: []
+`
+
+const including = `name: including
+stages:
+  split:
+    plugin: split
+    config:
+      sep: ";"
+  suffix:
+    include: ./helper/suffix.yml
+    parents: [split]
+    variables:
+      b: "!"
+  out:
+    plugin: output
+    parents: [suffix]
+`
+
+const included = `name: suffix
+stages:
+  insert:
+    plugin: insert
+    config:
+      content: "{{ .a }}{{ .b }}"
+`
+
+const includeSuite = `template: including.yml
+cases:
+  - name: merges the variables
+    variables:
+      a: "-"
+      b: "?"
+    inputs:
+      split: ['1;2']
+    expect:
+      out: ['1-!', '2-!']
+`
+
+// load writes `files` in a temporary directory and loads the suite `suite.yml`.
+func load(t *testing.T, files map[string]string) templatetest.Suite {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := templatetest.Load(filepath.Join(dir, "suite.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSuite(t *testing.T) {
+	plugins.InitLoader()
+	results := load(t, map[string]string{"pipeline.yml": pipeline, "suite.yml": suite}).Run(context.Background())
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+	for _, result := range results[:2] {
+		if !result.Passed() {
+			t.Errorf("case %q failed: %v", result.Name, result.Failures)
+		}
+	}
+	failures := strings.Join(results[2].Failures, "\n")
+	if results[2].Passed() || !strings.Contains(failures, `- equal "{\"status\":500}"`) || !strings.Contains(failures, `+ {"status":200}`) {
+		t.Errorf("expected a diff of the outputs of out, got %q", failures)
+	}
+	failures = strings.Join(results[3].Failures, "\n")
+	if !strings.Contains(failures, "unknown stage missing") || !strings.Contains(failures, "route which is not a root stage") {
+		t.Errorf("expected the unknown and non root stages to be reported, got %q", failures)
+	}
+}
+
+func TestInclude(t *testing.T) {
+	plugins.InitLoader()
+	files := map[string]string{"including.yml": including, "helper/suffix.yml": included, "suite.yml": includeSuite}
+	for _, result := range load(t, files).Run(context.Background()) {
+		if !result.Passed() {
+			t.Errorf("case %q failed: %v", result.Name, result.Failures)
+		}
+	}
+}
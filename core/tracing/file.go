@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// fileClient writes each batch of spans as an OTLP JSON export request on its own line,
+// the format read by the `otlpjsonfile` receiver of the OpenTelemetry collector.
+type fileClient struct {
+	path string
+	file *os.File
+	mux  sync.Mutex
+}
+
+func newFileClient(path string) *fileClient {
+	return &fileClient{path: path}
+}
+
+func (fc *fileClient) Start(_ context.Context) error {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+	file, err := os.OpenFile(fc.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:mnd // traces are only for the user
+	if err != nil {
+		return fmt.Errorf("open trace file: %w", err)
+	}
+	fc.file = file
+	return nil
+}
+
+func (fc *fileClient) Stop(_ context.Context) error {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+	if fc.file == nil {
+		return nil
+	}
+	err := fc.file.Close()
+	fc.file = nil
+	return err
+}
+
+func (fc *fileClient) UploadTraces(_ context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	raw, err := marshalOTLP(&coltracepb.ExportTraceServiceRequest{ResourceSpans: protoSpans})
+	if err != nil {
+		return fmt.Errorf("encode spans: %w", err)
+	}
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+	if fc.file == nil {
+		return fmt.Errorf("trace file %s is closed", fc.path)
+	}
+	if _, err := fc.file.Write(append(raw, '\n')); err != nil {
+		return fmt.Errorf("write spans: %w", err)
+	}
+	return nil
+}
+
+// idFields are the bytes fields the OTLP JSON encoding writes in hexadecimal instead of base64.
+var idFields = map[string]bool{"traceId": true, "spanId": true, "parentSpanId": true}
+
+// marshalOTLP encodes `request` in OTLP JSON: the protobuf JSON mapping, except for the trace and span ids.
+func marshalOTLP(request *coltracepb.ExportTraceServiceRequest) ([]byte, error) {
+	raw, err := protojson.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if err := hexIDs(doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func hexIDs(node any) error {
+	switch n := node.(type) {
+	case map[string]any:
+		for key, value := range n {
+			if id, ok := value.(string); ok && idFields[key] {
+				decoded, err := base64.StdEncoding.DecodeString(id)
+				if err != nil {
+					return fmt.Errorf("decode %s: %w", key, err)
+				}
+				n[key] = hex.EncodeToString(decoded)
+				continue
+			}
+			if err := hexIDs(value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, value := range n {
+			if err := hexIDs(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
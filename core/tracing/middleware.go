@@ -0,0 +1,165 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"github.com/benji-bou/diwo"
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName       = "github.com/benji-bou/lugh/core/tracing"
+	attributeStage   = "lugh.stage"
+	attributePlugin  = "lugh.plugin"
+	attributeRunID   = "lugh.run_id"
+	attributeSource  = "lugh.source"
+	attributePayload = "lugh.payload_size"
+)
+
+// Middleware starts a span each time a stage reads a message. The span of the input is recorded in its headers
+// before the plugin reads it, so the outputs derived from it are its children.
+// The span ends once the stage is done with the input and sent its outputs, see `graph.DoneNotifier`. The stages unable
+// to tell it, like most runners, end it right away: their spans only record when each input is read.
+func Middleware(provider trace.TracerProvider) graph.Middleware[message.Message] {
+	tracer := provider.Tracer(tracerName)
+	return func(vertex graph.IOWorkerVertex[message.Message], worker graph.IOWorker[message.Message]) graph.IOWorker[message.Message] {
+		w := &ioWorker{
+			worker: worker,
+			tracer: tracer,
+			attributes: []attribute.KeyValue{
+				attribute.String(attributeStage, vertex.GetName()),
+				attribute.String(attributePlugin, vertex.GetPlugin()),
+			},
+			name:  vertex.GetName(),
+			spans: make(map[string]trace.Span),
+		}
+		w.tracked = graph.NotifyDone(worker, w.done) == nil
+		w.output = sync.OnceValue(func() <-chan message.Message {
+			return diwo.Map(w.worker.Output(), w.emitted)
+		})
+		return w
+	}
+}
+
+type ioWorker struct {
+	worker     graph.IOWorker[message.Message]
+	tracer     trace.Tracer
+	name       string
+	attributes []attribute.KeyValue
+	output     func() <-chan message.Message
+	// spans holds the span of each input the stage is not done with yet, by span id.
+	spans   map[string]trace.Span
+	tracked bool
+	mux     sync.Mutex
+}
+
+func (w *ioWorker) start(ctx context.Context, elem message.Message) (context.Context, trace.Span) {
+	return w.tracer.Start(ContextWithMessage(ctx, elem), w.name, trace.WithAttributes(w.attributes...), trace.WithAttributes(
+		attribute.String(attributeRunID, elem.Headers.RunID),
+		attribute.String(attributeSource, elem.Headers.Source),
+		attribute.Int(attributePayload, len(elem.Payload)),
+	))
+}
+
+// read starts the span of the input, ended by `done`.
+func (w *ioWorker) read(elem message.Message) message.Message {
+	_, span := w.start(context.Background(), elem)
+	elem = withSpan(elem, span)
+	if !w.tracked {
+		span.End()
+		return elem
+	}
+	w.mux.Lock()
+	w.spans[elem.Headers.SpanID] = span
+	w.mux.Unlock()
+	return elem
+}
+
+// done ends the span of the input, with the error it failed with.
+func (w *ioWorker) done(input message.Message, err error) {
+	w.mux.Lock()
+	span, ok := w.spans[input.Headers.SpanID]
+	delete(w.spans, input.Headers.SpanID)
+	w.mux.Unlock()
+	if !ok {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// emitted records the outputs which do not descend from an input, like the outputs of a producer, in a span of their own.
+func (w *ioWorker) emitted(elem message.Message) message.Message {
+	if elem.Headers.SpanID != "" {
+		return elem
+	}
+	_, span := w.start(context.Background(), elem)
+	defer span.End()
+	return withSpan(elem, span)
+}
+
+// endAll ends the spans of the inputs left when the stage stops, like when the run is canceled.
+func (w *ioWorker) endAll() {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	for id, span := range w.spans {
+		span.End()
+		delete(w.spans, id)
+	}
+}
+
+func withSpan(elem message.Message, span trace.Span) message.Message {
+	elem.Headers = elem.Headers.Clone()
+	elem.Headers.TraceID = span.SpanContext().TraceID().String()
+	elem.Headers.SpanID = span.SpanContext().SpanID().String()
+	return elem
+}
+
+func (w *ioWorker) SetInput(input <-chan message.Message) {
+	if input == nil {
+		w.worker.SetInput(nil)
+		return
+	}
+	w.worker.SetInput(diwo.Map(input, w.read))
+}
+
+func (w *ioWorker) Output() <-chan message.Message {
+	return w.output()
+}
+
+func (w *ioWorker) Run(ctx graph.SyncContext) <-chan error {
+	errC := w.worker.Run(ctx)
+	return diwo.New(func(c chan<- error) {
+		defer w.endAll()
+		for err := range errC {
+			c <- err
+		}
+	})
+}
+
+func (w *ioWorker) Kind() graph.Kind {
+	return graph.KindOf(w.worker)
+}
+
+func (w *ioWorker) NotifyDone(fn func(input message.Message, err error)) error {
+	return graph.NotifyDone(w.worker, fn)
+}
+
+func (w *ioWorker) Restarts() uint64 {
+	return graph.RestartsOf(w.worker)
+}
+
+func (w *ioWorker) DeadLetters() <-chan message.Message {
+	if emitter, ok := w.worker.(graph.DeadLetterEmitter[message.Message]); ok {
+		return emitter.DeadLetters()
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Package tracing records a span per item handled by each stage of a pipeline.
+// The span of a stage is the parent of the spans of the stages handling the items it emitted, so a trace is the
+// fan-out tree of an input. The trace context travels in the headers of the messages, see `message.Headers`,
+// and therefore across the gRPC plugin boundary.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/benji-bou/lugh/core/message"
+	"github.com/benji-bou/lugh/helper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const serviceName = "lugh"
+
+var ErrNoExporter = errors.New("no trace exporter configured")
+
+type ProviderConfig struct {
+	file      string
+	endpoint  string
+	runID     string
+	exporters []sdktrace.SpanExporter
+}
+
+type ProviderOption = helper.Option[ProviderConfig]
+
+// WithFile exports the spans to `path`, one OTLP JSON export request per line.
+func WithFile(path string) ProviderOption {
+	return func(configure *ProviderConfig) {
+		configure.file = path
+	}
+}
+
+// WithEndpoint exports the spans to the OTLP/HTTP collector listening on `endpoint`, e.g. localhost:4318.
+func WithEndpoint(endpoint string) ProviderOption {
+	return func(configure *ProviderConfig) {
+		configure.endpoint = endpoint
+	}
+}
+
+// WithExporter exports the spans to `exporter`, in addition to the file and the collector.
+func WithExporter(exporter sdktrace.SpanExporter) ProviderOption {
+	return func(configure *ProviderConfig) {
+		configure.exporters = append(configure.exporters, exporter)
+	}
+}
+
+// WithRunID records the run id on the resource of the spans.
+func WithRunID(runID string) ProviderOption {
+	return func(configure *ProviderConfig) {
+		configure.runID = runID
+	}
+}
+
+// NewProvider creates a tracer provider exporting to the configured file and collector.
+// Its `Shutdown` must be called to flush the spans.
+func NewProvider(ctx context.Context, opt ...ProviderOption) (*sdktrace.TracerProvider, error) {
+	config := helper.Configure(ProviderConfig{}, opt...)
+	options := []sdktrace.TracerProviderOption{sdktrace.WithIDGenerator(idGenerator{})}
+	if config.file != "" {
+		exporter, err := otlptrace.New(ctx, newFileClient(config.file))
+		if err != nil {
+			return nil, fmt.Errorf("create trace file exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+	if config.endpoint != "" {
+		exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(config.endpoint), otlptracehttp.WithInsecure())
+		if err != nil {
+			return nil, fmt.Errorf("create trace collector exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+	for _, exporter := range config.exporters {
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+	if len(options) == 1 {
+		return nil, ErrNoExporter
+	}
+	attributes := []attribute.KeyValue{attribute.String("service.name", serviceName)}
+	if config.runID != "" {
+		attributes = append(attributes, attribute.String(attributeRunID, config.runID))
+	}
+	options = append(options, sdktrace.WithResource(resource.NewSchemaless(attributes...)))
+	return sdktrace.NewTracerProvider(options...), nil
+}
+
+// ContextWithMessage returns a context whose span is the span recorded in the headers of `msg`.
+// Plugins use it to start their own spans as children of the stage span.
+func ContextWithMessage(ctx context.Context, msg message.Message) context.Context {
+	traceID, err := trace.TraceIDFromHex(msg.Headers.TraceID)
+	if err != nil {
+		return ctx
+	}
+	spanID, err := trace.SpanIDFromHex(msg.Headers.SpanID)
+	if err != nil {
+		// The message starts a trace, its span keeps its trace id. See `idGenerator`.
+		return context.WithValue(ctx, traceIDKey{}, traceID)
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+}
+
+type traceIDKey struct{}
+
+// idGenerator generates random ids. A root span reuses the trace id of the message which started it,
+// so that the trace id of a message is the id of its trace.
+type idGenerator struct{}
+
+func (idGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	traceID, ok := ctx.Value(traceIDKey{}).(trace.TraceID)
+	if !ok {
+		_, _ = rand.Read(traceID[:])
+	}
+	return traceID, idGenerator{}.NewSpanID(ctx, traceID)
+}
+
+func (idGenerator) NewSpanID(_ context.Context, _ trace.TraceID) trace.SpanID {
+	spanID := trace.SpanID{}
+	_, _ = rand.Read(spanID[:])
+	return spanID
+}
@@ -0,0 +1,188 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benji-bou/diwo"
+	"github.com/benji-bou/lugh/core/graph"
+	"github.com/benji-bou/lugh/core/message"
+	"github.com/benji-bou/lugh/core/tracing"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func split() graph.IOWorker[message.Message] {
+	return graph.NewIOWorkerFromWorker(graph.WorkerFunc[message.Message](func(_ context.Context, input message.Message, yield func(elem message.Message) error) error {
+		for _, part := range bytes.Split(input.Payload, []byte(",")) {
+			if err := yield(input.Derive(part)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+func upper() graph.IOWorker[message.Message] {
+	return graph.NewIOWorkerFromWorker(graph.WorkerFunc[message.Message](func(_ context.Context, input message.Message, yield func(elem message.Message) error) error {
+		return yield(input.Derive(bytes.ToUpper(input.Payload)))
+	}))
+}
+
+func run(t *testing.T, provider trace.TracerProvider, input message.Message) []message.Message {
+	t.Helper()
+	g := graph.NewIO(
+		graph.WithVertices([]graph.IOWorkerVertex[message.Message]{
+			graph.NewIOWorkerVertex("split", []string{}, split()),
+			graph.NewIOWorkerVertex("upper", []string{"split"}, upper()),
+		}),
+		graph.WithMiddleware(tracing.Middleware(provider)),
+	)
+	g.SetInput(diwo.Once(input))
+	outputC := g.Output()
+	ctx := graph.NewContext(context.Background())
+	errC := g.Run(ctx)
+	ctx.Synchronize()
+	go func() {
+		for err := range errC {
+			t.Errorf("unexpected error %v", err)
+		}
+	}()
+	outputs := []message.Message{}
+	for output := range outputC {
+		outputs = append(outputs, output)
+	}
+	return outputs
+}
+
+func TestMiddleware(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider, err := tracing.NewProvider(context.Background(), tracing.WithExporter(exporter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := message.New([]byte("a,b"))
+	outputs := run(t, provider, input)
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 2 {
+		t.Fatalf("expected 2 outputs, got %d", len(outputs))
+	}
+	spans := exporter.GetSpans()
+	spansByID := map[string]tracetest.SpanStub{}
+	for _, span := range spans {
+		spansByID[span.SpanContext.SpanID().String()] = span
+		if span.SpanContext.TraceID().String() != input.Headers.TraceID {
+			t.Errorf("span %s is not in the trace of the input", span.Name)
+		}
+	}
+	if len(spans) != 3 {
+		t.Fatalf("expected a span for the input of split and for each input of upper, got %d", len(spans))
+	}
+	for _, output := range outputs {
+		span, ok := spansByID[output.Headers.SpanID]
+		if !ok || span.Name != "upper" {
+			t.Fatalf("output %s is not linked to an upper span", output.Payload)
+		}
+		parent, ok := spansByID[span.Parent.SpanID().String()]
+		if !ok || parent.Name != "split" || parent.Parent.IsValid() {
+			t.Errorf("upper span of %s is not a child of the root split span", output.Payload)
+		}
+	}
+}
+
+func TestFileExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	provider, err := tracing.NewProvider(context.Background(), tracing.WithFile(path), tracing.WithRunID("run"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := message.New([]byte("a,b"))
+	run(t, provider, input)
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(raw, []byte(`{"resourceSpans":`)) || !bytes.Contains(raw, []byte(`"name":"upper"`)) ||
+		!bytes.Contains(raw, []byte(`"traceId":"`+input.Headers.TraceID+`"`)) || !bytes.HasSuffix(raw, []byte("\n")) {
+		t.Errorf("unexpected OTLP JSON export %s", raw)
+	}
+}
+
+// TestMiddlewareConcurrency checks each span ends with its own input, whatever the order the inputs are done in.
+func TestMiddlewareConcurrency(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	exported := func(spanID string) bool {
+		for _, span := range exporter.GetSpans() {
+			if span.SpanContext.SpanID().String() == spanID {
+				return true
+			}
+		}
+		return false
+	}
+	fastSpanC := make(chan string, 1)
+	worker := graph.NewIOWorkerFromWorker(graph.WorkerFunc[message.Message](func(_ context.Context, input message.Message, yield func(elem message.Message) error) error {
+		if string(input.Payload) == "fast" {
+			fastSpanC <- input.Headers.SpanID
+			return yield(input.Derive([]byte("FAST")))
+		}
+		// The slow input is read first and done once the span of the fast one ended.
+		fastSpan := <-fastSpanC
+		deadline := time.Now().Add(5 * time.Second)
+		for !exported(fastSpan) {
+			if time.Now().After(deadline) {
+				return errors.New("the span of the fast input did not end")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if exported(input.Headers.SpanID) {
+			return errors.New("the span of the slow input ended before it is done")
+		}
+		return yield(input.Derive([]byte("SLOW")))
+	}))
+	if err := graph.SetConcurrency(worker, 2, false); err != nil {
+		t.Fatal(err)
+	}
+	g := graph.NewIO(
+		graph.WithVertices([]graph.IOWorkerVertex[message.Message]{graph.NewIOWorkerVertex("work", []string{}, worker)}),
+		graph.WithMiddleware(tracing.Middleware(provider)),
+	)
+	g.SetInput(diwo.FromSlice([]message.Message{message.New([]byte("slow")), message.New([]byte("fast"))}))
+	outputC := g.Output()
+	ctx := graph.NewContext(context.Background())
+	errC := g.Run(ctx)
+	ctx.Synchronize()
+	go func() {
+		for err := range errC {
+			t.Errorf("unexpected error %v", err)
+		}
+	}()
+	outputs := 0
+	for range outputC {
+		outputs++
+	}
+	if outputs != 2 {
+		t.Fatalf("expected 2 outputs, got %d", outputs)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected a span per input, got %d", len(spans))
+	}
+	for _, span := range spans {
+		if span.Status.Code != codes.Unset {
+			t.Errorf("span %s failed: %s", span.SpanContext.SpanID(), span.Status.Description)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/benji-bou/lugh/core/plugins/grpc"
+	"github.com/benji-bou/lugh/core/plugins/pluginapi"
 	"github.com/benji-bou/lugh/helper"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
@@ -46,7 +47,7 @@ func (wh *Docker) Config(config []byte) error {
 }
 
 func (Docker) GetInputSchema() ([]byte, error) {
-	return nil, nil
+	return pluginapi.Schema(ConfigDocker{})
 }
 
 func (wh Docker) Work(ctx context.Context, input []byte, yield func([]byte) error) error {
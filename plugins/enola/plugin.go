@@ -9,6 +9,7 @@ import (
 
 	"github.com/benji-bou/enola"
 	"github.com/benji-bou/lugh/core/plugins/grpc"
+	"github.com/benji-bou/lugh/core/plugins/pluginapi"
 	"github.com/benji-bou/lugh/helper"
 )
 
@@ -65,7 +66,7 @@ func NewEnola(opt ...EnolaOption) *Enola {
 }
 
 func (wh Enola) GetInputSchema() ([]byte, error) {
-	return nil, nil
+	return pluginapi.Schema(ConfigEnola{})
 }
 
 func main() {
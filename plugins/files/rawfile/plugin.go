@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 
 	"github.com/benji-bou/lugh/core/plugins/grpc"
+	"github.com/benji-bou/lugh/core/plugins/pluginapi"
 	"github.com/benji-bou/lugh/helper"
 )
 
@@ -27,7 +28,7 @@ func NewRawFile(opt ...RawFileOption) *RawFile {
 }
 
 func (*RawFile) GetInputSchema() ([]byte, error) {
-	return nil, nil
+	return pluginapi.Schema(ConfigRawFile{})
 }
 
 func (mp *RawFile) Config(config []byte) error {
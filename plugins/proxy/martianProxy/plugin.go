@@ -6,14 +6,13 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"os"
 	"time"
 
 	"github.com/benji-bou/lugh/core/plugins/grpc"
+	"github.com/benji-bou/lugh/core/plugins/pluginapi"
 	"github.com/benji-bou/lugh/helper"
 
 	martian "github.com/benji-bou/lugh/plugins/proxy/martianProxy/martian"
-	"github.com/swaggest/jsonschema-go"
 )
 
 type YieldWriter func(elem []byte) error
@@ -28,33 +27,20 @@ func (y YieldWriter) Write(data []byte) (int, error) {
 
 type MartianInputConfig struct {
 	Modifier json.RawMessage `json:"modifier"`
-	Cert     string
-	Key      string
+	Cert     string          `json:"cert"`
+	Key      string          `json:"key"`
 }
 
 type MartianPlugin struct {
-	inputFormat []byte
-	config      MartianInputConfig
+	config MartianInputConfig
 }
 
 func NewMartianPlugin() *MartianPlugin {
-	r := jsonschema.Reflector{}
-	schema, err := r.Reflect(MartianInputConfig{})
-	if err != nil {
-		slog.Error(grpc.ErrJSONSchemaConvertion.Error(), "plugin", "MartianHttpProxy", "type", "MartianInputConfig", "errors", err)
-		os.Exit(-1)
-	}
-	j, err := json.Marshal(schema)
-	if err != nil {
-		slog.Error(grpc.ErrJSONConvertion.Error(), "plugin", "MartianHttpProxy", "type", "MartianInputConfig", "errors", err)
-		os.Exit(-1)
-	}
-	return &MartianPlugin{inputFormat: j}
+	return &MartianPlugin{}
 }
 
 func (mp *MartianPlugin) GetInputSchema() ([]byte, error) {
-	slog.Info("MartianPlugin GetInputSchema")
-	return mp.inputFormat, nil
+	return pluginapi.Schema(MartianInputConfig{})
 }
 
 func (mp *MartianPlugin) Config(config []byte) error {
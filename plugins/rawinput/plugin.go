@@ -7,6 +7,7 @@ import (
 	"log/slog"
 
 	"github.com/benji-bou/lugh/core/plugins/grpc"
+	"github.com/benji-bou/lugh/core/plugins/pluginapi"
 	"github.com/benji-bou/lugh/helper"
 )
 
@@ -35,7 +36,7 @@ func NewRawInput(opt ...RawInputOption) *RawInput {
 }
 
 func (RawInput) GetInputSchema() ([]byte, error) {
-	return nil, nil
+	return pluginapi.Schema(ConfigRawInput{})
 }
 
 func (ri RawInput) Produce(_ context.Context, yield func(elem []byte) error) error {
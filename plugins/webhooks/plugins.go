@@ -8,6 +8,7 @@ import (
 	"log/slog"
 
 	"github.com/benji-bou/lugh/core/plugins/grpc"
+	"github.com/benji-bou/lugh/core/plugins/pluginapi"
 	"github.com/benji-bou/lugh/helper"
 	"github.com/labstack/echo/v4"
 )
@@ -38,7 +39,7 @@ func (wh *Webhook) Config(config []byte) error {
 }
 
 func (*Webhook) GetInputSchema() ([]byte, error) {
-	return nil, nil
+	return pluginapi.Schema(ConfigWebhook{})
 }
 
 func (wh *Webhook) Produce(ctx context.Context, yield func(elem []byte) error) error {